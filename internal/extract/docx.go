@@ -0,0 +1,124 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Docx extracts plain text from a Word document.
+// About maxChars characters are extracted (0 means unlimited).
+func Docx(data []byte, maxChars int) (Result, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Result{}, errors.Wrap(err, "not a docx document")
+	}
+
+	var (
+		text      string
+		title     string
+		truncated bool
+	)
+	for _, file := range archive.File {
+		switch file.Name {
+		case "word/document.xml":
+			text, err = readDocxXML(file, func(decoder *xml.Decoder) (string, error) {
+				body, cut, err := docxBodyText(decoder, textBudget(maxChars))
+				truncated = cut
+				return body, err
+			})
+		case "docProps/core.xml":
+			title, err = readDocxXML(file, docxTitle)
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	if text == "" && title == "" {
+		return Result{}, errors.New("docx document has no text")
+	}
+
+	return Result{Title: title, Text: text, Truncated: truncated}, nil
+}
+
+func readDocxXML(file *zip.File, fn func(decoder *xml.Decoder) (string, error)) (string, error) {
+	r, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = r.Close() }()
+
+	return fn(xml.NewDecoder(r))
+}
+
+// docxBodyText collects text runs of word/document.xml, up to maxText bytes (0 means unlimited).
+// It reports whether the text has been cut.
+func docxBodyText(decoder *xml.Decoder, maxText int) (string, bool, error) {
+	var (
+		sb     strings.Builder
+		inText bool
+	)
+	for {
+		if maxText > 0 && sb.Len() >= maxText {
+			return sb.String(), true, nil
+		}
+
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return sb.String(), false, nil
+		}
+		if err != nil {
+			return "", false, errors.Wrap(err, "malformed docx document")
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteByte('\t')
+			case "br", "cr":
+				sb.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+}
+
+// docxTitle reads document title from docProps/core.xml.
+func docxTitle(decoder *xml.Decoder) (string, error) {
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return "", nil
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "malformed docx document")
+		}
+
+		if t, ok := token.(xml.StartElement); ok && t.Name.Local == "title" {
+			var title string
+			err = decoder.DecodeElement(&title, &t)
+			if err != nil {
+				return "", errors.Wrap(err, "malformed docx document")
+			}
+			return strings.TrimSpace(title), nil
+		}
+	}
+}
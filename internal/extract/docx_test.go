@@ -0,0 +1,122 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testDocx builds a zip archive of the given files.
+func testDocx(files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, _ := w.Create(name)
+		_, _ = f.Write([]byte(content))
+	}
+	_ = w.Close()
+
+	return buf.Bytes()
+}
+
+// testDocxBody returns word/document.xml with the given body.
+func testDocxBody(body string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		body +
+		`</w:body></w:document>`
+}
+
+func TestDocx(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		maxChars      int
+		wantTitle     string
+		wantText      string
+		wantTruncated bool
+		wantErr       bool
+	}{
+		{
+			name: "paragraphs",
+			data: testDocx(map[string]string{
+				"word/document.xml": testDocxBody(`<w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:tab/><w:t>world</w:t></w:r></w:p>` +
+					`<w:p><w:r><w:t>Second</w:t><w:br/><w:t>line</w:t></w:r></w:p>`),
+			}),
+			wantText: "Hello\tworld\nSecond\nline\n",
+		},
+		{
+			name: "title",
+			data: testDocx(map[string]string{
+				"word/document.xml": testDocxBody(`<w:p><w:r><w:t>Body</w:t></w:r></w:p>`),
+				"docProps/core.xml": `<cp:coreProperties xmlns:cp="cp" xmlns:dc="dc"><dc:title> Report </dc:title></cp:coreProperties>`,
+			}),
+			wantTitle: "Report",
+			wantText:  "Body\n",
+		},
+		{
+			name: "nested table",
+			data: testDocx(map[string]string{
+				"word/document.xml": testDocxBody(`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Cell</w:t></w:r></w:p>` +
+					`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Inner</w:t></w:r></w:p></w:tc></w:tr></w:tbl>` +
+					`</w:tc></w:tr></w:tbl>`),
+			}),
+			wantText: "Cell\nInner\n",
+		},
+		{
+			name: "instructions are skipped",
+			data: testDocx(map[string]string{
+				"word/document.xml": testDocxBody(`<w:p><w:r><w:instrText>PAGE</w:instrText><w:t>Text</w:t></w:r></w:p>`),
+			}),
+			wantText: "Text\n",
+		},
+		{
+			name: "max chars",
+			data: testDocx(map[string]string{
+				"word/document.xml": testDocxBody(strings.Repeat(`<w:p><w:r><w:t>Paragraph</w:t></w:r></w:p>`, 100)),
+			}),
+			maxChars:      3,
+			wantText:      "Paragraph\nParagraph",
+			wantTruncated: true,
+		},
+		{name: "not a zip", data: []byte("hello"), wantErr: true},
+		{
+			name:    "malformed xml",
+			data:    testDocx(map[string]string{"word/document.xml": testDocxBody(`<w:p><w:t>Text</w:p>`)}),
+			wantErr: true,
+		},
+		{
+			name:    "no text",
+			data:    testDocx(map[string]string{"word/document.xml": testDocxBody("")}),
+			wantErr: true,
+		},
+		{
+			name:    "no document",
+			data:    testDocx(map[string]string{"word/styles.xml": `<w:styles/>`}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Docx(tt.data, tt.maxChars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Docx() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if result.Title != tt.wantTitle {
+				t.Errorf("Docx() title = %q, want %q", result.Title, tt.wantTitle)
+			}
+			if result.Text != tt.wantText {
+				t.Errorf("Docx() text = %q, want %q", result.Text, tt.wantText)
+			}
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("Docx() truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
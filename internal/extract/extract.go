@@ -0,0 +1,108 @@
+package extract
+
+import (
+	"mime"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnsupported is returned for documents of unsupported types.
+var ErrUnsupported = errors.New("unsupported document type")
+
+// Supported MIME types.
+const (
+	MIMEText     = "text/plain"
+	MIMEMarkdown = "text/markdown"
+	MIMEHTML     = "text/html"
+	MIMEPDF      = "application/pdf"
+	MIMEDocx     = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+// Request is a text extraction request.
+type Request struct {
+	Data     []byte // Document content.
+	MIMEType string // Document MIME type (with an optional charset), optional if Filename has a known extension.
+	Filename string // Document file name, optional.
+	MaxPages int    // Max number of pages to extract (for paged documents), 0 means unlimited.
+	MaxChars int    // Max number of characters to extract, 0 means unlimited.
+}
+
+// Result is a text extraction result.
+type Result struct {
+	Title     string // Document title, if known.
+	Text      string // Extracted text.
+	Truncated bool   // True if the text has been cut to fit the limits.
+}
+
+// Text extracts plain text from a document.
+func Text(req Request) (Result, error) {
+	var (
+		result Result
+		err    error
+	)
+	switch DetectType(req.MIMEType, req.Filename) {
+	case MIMEText, MIMEMarkdown:
+		result.Text, err = decodeText(req.Data, req.MIMEType, false)
+	case MIMEHTML:
+		var text string
+		text, err = decodeText(req.Data, req.MIMEType, true)
+		result = HTML([]byte(text))
+	case MIMEPDF:
+		result, err = PDF(req.Data, req.MaxPages, req.MaxChars)
+	case MIMEDocx:
+		result, err = Docx(req.Data, req.MaxChars)
+	default:
+		return Result{}, ErrUnsupported
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	result.Text = strings.TrimSpace(result.Text)
+	if req.MaxChars > 0 {
+		if runes := []rune(result.Text); len(runes) > req.MaxChars {
+			result.Text = string(runes[:req.MaxChars])
+			result.Truncated = true
+		}
+	}
+
+	return result, nil
+}
+
+// textBudget returns the size in bytes of raw text to collect for maxChars characters (0 means unlimited).
+// Raw text is collected with some headroom, as whitespace is squeezed and the result is cut precisely later.
+func textBudget(maxChars int) int {
+	return maxChars * utf8.UTFMax
+}
+
+// DetectType returns a supported MIME type of a document or an empty string.
+func DetectType(mimeType, filename string) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		switch mediaType {
+		case MIMEText, MIMEMarkdown, MIMEHTML, MIMEPDF, MIMEDocx:
+			return mediaType
+		case "text/x-markdown":
+			return MIMEMarkdown
+		case "application/xhtml+xml":
+			return MIMEHTML
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".txt", ".text", ".log":
+		return MIMEText
+	case ".md", ".markdown":
+		return MIMEMarkdown
+	case ".html", ".htm", ".xhtml":
+		return MIMEHTML
+	case ".pdf":
+		return MIMEPDF
+	case ".docx":
+		return MIMEDocx
+	}
+
+	return ""
+}
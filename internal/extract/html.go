@@ -0,0 +1,284 @@
+package extract
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// HTML extracts readable text from an HTML page.
+// If the page has an <article> (or <main>) element, only its content is extracted;
+// otherwise navigation, headers, footers and forms are skipped.
+func HTML(data []byte) Result {
+	const minArticleLength = 200
+
+	p := htmlParser{src: string(data)}
+	p.parse()
+
+	text := p.body.String()
+	for _, candidate := range []*strings.Builder{&p.article, &p.main} {
+		if len(candidate.String()) >= minArticleLength {
+			text = candidate.String()
+			break
+		}
+	}
+
+	title := normalizeText(p.title.String())
+	if title == "" {
+		title = normalizeText(p.heading.String())
+	}
+
+	return Result{Title: title, Text: normalizeText(text)}
+}
+
+// Tags whose content is never extracted.
+var htmlSkipTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true, "iframe": true,
+}
+
+// Tags whose content is extracted only if nothing better is found.
+var htmlBoilerplateTags = map[string]bool{
+	"nav": true, "header": true, "footer": true, "aside": true, "form": true, "button": true, "menu": true,
+}
+
+// Tags that start a new line.
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true, "article": true, "main": true,
+	"blockquote": true, "pre": true, "ul": true, "ol": true, "table": true, "hr": true, "dd": true, "dt": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "figcaption": true, "header": true,
+	"footer": true,
+}
+
+type htmlParser struct {
+	src string
+	pos int
+
+	inHead        bool
+	inTitle       bool
+	inHeading     bool
+	articleDepth  int
+	mainDepth     int
+	boilerplate   int
+	preDepth      int
+	headingLocked bool // The first heading is already collected.
+
+	title   strings.Builder
+	heading strings.Builder
+	article strings.Builder
+	main    strings.Builder
+	body    strings.Builder
+}
+
+func (p *htmlParser) parse() {
+	for p.pos < len(p.src) {
+		i := strings.IndexByte(p.src[p.pos:], '<')
+		if i < 0 {
+			p.text(p.src[p.pos:])
+			return
+		}
+
+		p.text(p.src[p.pos : p.pos+i])
+		p.pos += i
+		p.tag()
+	}
+}
+
+// tag parses a tag (or a comment) at the current position.
+func (p *htmlParser) tag() {
+	rest := p.src[p.pos:]
+
+	if len(rest) < 2 || !(isASCIILetter(rest[1]) || strings.ContainsRune("/!?", rune(rest[1]))) {
+		// Not a tag, just a "<" character.
+		p.text("<")
+		p.pos++
+		return
+	}
+
+	if strings.HasPrefix(rest, "<!--") {
+		p.skipPast("-->")
+		return
+	}
+
+	if strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?") {
+		p.skipPast(">")
+		return
+	}
+
+	end := strings.IndexByte(rest, '>')
+	if end < 0 {
+		p.pos = len(p.src)
+		return
+	}
+
+	raw := rest[1:end]
+	p.pos += end + 1
+
+	closing := strings.HasPrefix(raw, "/")
+	raw = strings.TrimPrefix(raw, "/")
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '/'
+	})
+	if len(fields) == 0 {
+		return
+	}
+
+	name := strings.ToLower(fields[0])
+
+	if closing {
+		p.closeTag(name)
+	} else {
+		p.openTag(name, strings.HasSuffix(raw, "/"))
+	}
+}
+
+func (p *htmlParser) openTag(name string, selfClosing bool) {
+	if name == "title" && !selfClosing {
+		p.inTitle = true
+		return
+	}
+
+	if htmlSkipTags[name] && !selfClosing {
+		// Content of these tags is either raw text or not worth extracting, so it's skipped entirely.
+		p.skipPastTag(name)
+		return
+	}
+
+	if htmlBlockTags[name] {
+		p.write("\n")
+	}
+
+	switch name {
+	case "head":
+		p.inHead = true
+	case "body":
+		p.inHead = false
+	case "li":
+		p.write("- ")
+	case "article":
+		p.articleDepth++
+	case "main":
+		p.mainDepth++
+	case "pre":
+		p.preDepth++
+	case "h1":
+		p.inHeading = !p.headingLocked
+	}
+
+	if htmlBoilerplateTags[name] && !selfClosing {
+		p.boilerplate++
+	}
+}
+
+func (p *htmlParser) closeTag(name string) {
+	switch name {
+	case "head":
+		p.inHead = false
+	case "title":
+		p.inTitle = false
+	case "article":
+		p.articleDepth = max(p.articleDepth-1, 0)
+	case "main":
+		p.mainDepth = max(p.mainDepth-1, 0)
+	case "pre":
+		p.preDepth = max(p.preDepth-1, 0)
+	case "h1":
+		if p.inHeading {
+			p.inHeading = false
+			p.headingLocked = true
+		}
+	}
+
+	if htmlBoilerplateTags[name] {
+		p.boilerplate = max(p.boilerplate-1, 0)
+	}
+
+	if htmlBlockTags[name] {
+		p.write("\n")
+	}
+}
+
+func (p *htmlParser) text(raw string) {
+	if raw == "" {
+		return
+	}
+
+	text := html.UnescapeString(raw)
+	if p.preDepth == 0 {
+		text = collapseSpaces(text)
+	}
+
+	if p.inTitle {
+		p.title.WriteString(text)
+		return
+	}
+
+	if p.inHeading {
+		p.heading.WriteString(text)
+	}
+
+	p.write(text)
+}
+
+func (p *htmlParser) write(text string) {
+	if p.inHead {
+		return
+	}
+
+	if p.articleDepth > 0 {
+		p.article.WriteString(text)
+	}
+
+	if p.mainDepth > 0 {
+		p.main.WriteString(text)
+	}
+
+	if p.boilerplate == 0 {
+		p.body.WriteString(text)
+	}
+}
+
+func (p *htmlParser) skipPast(marker string) {
+	i := strings.Index(p.src[p.pos:], marker)
+	if i < 0 {
+		p.pos = len(p.src)
+		return
+	}
+
+	p.pos += i + len(marker)
+}
+
+func (p *htmlParser) skipPastTag(name string) {
+	i := strings.Index(strings.ToLower(p.src[p.pos:]), "</"+name)
+	if i < 0 {
+		p.pos = len(p.src)
+		return
+	}
+
+	p.pos += i
+	p.skipPast(">")
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+var spacesRegexp = regexp.MustCompile(`\s+`)
+
+func collapseSpaces(text string) string {
+	return spacesRegexp.ReplaceAllString(text, " ")
+}
+
+var blankLinesRegexp = regexp.MustCompile(`\n{3,}`)
+
+// normalizeText trims lines and removes repeated blank lines.
+func normalizeText(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimFunc(line, unicode.IsSpace)
+	}
+
+	text = strings.Join(lines, "\n")
+	text = blankLinesRegexp.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,109 @@
+package extract
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	article := strings.Repeat("Article text. ", 20)
+
+	tests := []struct {
+		name      string
+		data      string
+		wantTitle string
+		wantText  string
+	}{
+		{
+			name:      "page",
+			data:      `<html><head><title>Page title</title><style>p { color: red }</style></head><body><p>Hello,   world!</p></body></html>`,
+			wantTitle: "Page title",
+			wantText:  "Hello, world!",
+		},
+		{
+			name:      "heading as title",
+			data:      `<body><h1>Heading</h1><h1>Other</h1><p>Text</p></body>`,
+			wantTitle: "Heading",
+			wantText:  "Heading\n\nOther\n\nText",
+		},
+		{
+			name:     "boilerplate is skipped",
+			data:     `<body><nav><a href="/">Home</a></nav><header>Site</header><p>Content</p><footer>Copyright</footer></body>`,
+			wantText: "Content",
+		},
+		{
+			name:     "scripts are skipped",
+			data:     `<body><script>if (a < b) { document.write("</p>") }</script><p>Text</p><noscript>Enable JS</noscript></body>`,
+			wantText: "Text",
+		},
+		{
+			name:     "article is preferred",
+			data:     `<body><div>Sidebar</div><article><p>` + article + `</p></article></body>`,
+			wantText: strings.TrimSpace(article),
+		},
+		{
+			name:     "short article is ignored",
+			data:     `<body><div>Intro</div><article>Short</article></body>`,
+			wantText: "Intro\n\nShort",
+		},
+		{
+			name:     "nested lists",
+			data:     `<ul><li>One<ul><li>Nested</li></ul></li><li>Two</li></ul>`,
+			wantText: "- One\n\n- Nested\n\n- Two",
+		},
+		{
+			name:     "preformatted text",
+			data:     "<pre>a  b\n  c</pre>",
+			wantText: "a  b\nc",
+		},
+		{
+			name:     "entities",
+			data:     `<p>Fish &amp; chips &lt;3 &#8212; &quot;yum&quot;</p>`,
+			wantText: `Fish & chips <3 — "yum"`,
+		},
+		{
+			name:     "comments and doctype",
+			data:     `<!DOCTYPE html><!-- <p>hidden</p> --><p>Shown</p>`,
+			wantText: "Shown",
+		},
+		{
+			name:     "stray angle brackets",
+			data:     `<p>1 < 2 and 3 > 2</p>`,
+			wantText: "1 < 2 and 3 > 2",
+		},
+		{
+			name:     "unclosed tags",
+			data:     `<div><p>Unclosed <b>bold <i>text`,
+			wantText: "Unclosed bold text",
+		},
+		{
+			name:     "unterminated tag",
+			data:     `<p>Text</p><a href="`,
+			wantText: "Text",
+		},
+		{
+			name:     "unterminated script",
+			data:     `<p>Text</p><script>var a = 1;`,
+			wantText: "Text",
+		},
+		{
+			name:     "unbalanced closing tags",
+			data:     `</article></main></nav><p>Text</p>`,
+			wantText: "Text",
+		},
+		{name: "empty", data: "", wantText: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := HTML([]byte(tt.data))
+
+			if result.Title != tt.wantTitle {
+				t.Errorf("HTML() title = %q, want %q", result.Title, tt.wantTitle)
+			}
+			if result.Text != tt.wantText {
+				t.Errorf("HTML() text = %q, want %q", result.Text, tt.wantText)
+			}
+		})
+	}
+}
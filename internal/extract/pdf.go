@@ -0,0 +1,1106 @@
+package extract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// PDF extracts plain text from a PDF document.
+// It's a best-effort extractor: only text drawn directly on pages is extracted,
+// and fonts without Unicode mappings are decoded as Latin-1.
+// At most maxPages pages and about maxChars characters are extracted (0 means unlimited).
+func PDF(data []byte, maxPages, maxChars int) (Result, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return Result{}, errors.New("not a pdf document")
+	}
+
+	doc := newPDFDocument(data)
+	if doc.encrypted() {
+		return Result{}, errors.New("encrypted pdf documents are not supported")
+	}
+
+	doc.maxText = textBudget(maxChars)
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return Result{}, errors.New("pdf document has no pages")
+	}
+
+	var result Result
+	if maxPages > 0 && len(pages) > maxPages {
+		pages = pages[:maxPages]
+		result.Truncated = true
+	}
+
+	var sb strings.Builder
+	for _, page := range pages {
+		if doc.textFull(&sb) || doc.decodeFull() {
+			result.Truncated = true
+			break
+		}
+
+		doc.pageText(&sb, page)
+		sb.WriteString("\n\n")
+	}
+
+	result.Text = normalizeText(sb.String())
+	if info, ok := doc.resolve(doc.trailerInfo()).(pdfDict); ok {
+		if title, ok := info["Title"].(pdfString); ok {
+			result.Title = decodePDFTextString(title)
+		}
+	}
+
+	if result.Text == "" {
+		if doc.decodeFull() {
+			return Result{}, errPDFTooLarge
+		}
+		return Result{}, errors.New("pdf document has no extractable text")
+	}
+
+	return result, nil
+}
+
+// PDF object types.
+type (
+	pdfName    string
+	pdfString  []byte
+	pdfKeyword string
+	pdfDict    map[pdfName]any
+	pdfRef     struct{ Num, Gen int }
+	pdfStream  struct {
+		Dict pdfDict
+		Data []byte
+	}
+)
+
+// Limits that keep malformed or hostile documents from exhausting the stack and memory.
+const (
+	maxPDFDepth       = 64        // Max nesting of arrays and dictionaries.
+	maxPDFStreamSize  = 32 << 20  // Max size of a decoded stream.
+	maxPDFDecodedSize = 128 << 20 // Max total size of decoded streams of a document.
+)
+
+var (
+	errPDFTooDeep        = errors.New("pdf objects are nested too deeply")
+	errPDFStreamTooLarge = errors.New("pdf stream is too large")
+	errPDFTooLarge       = errors.New("pdf document decodes to too much data")
+)
+
+// pdfLexer reads PDF objects from a byte stream.
+type pdfLexer struct {
+	data  []byte
+	pos   int
+	depth int // Nesting level of arrays and dictionaries being read.
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next reads the next object; io.EOF is returned at the end of data.
+// Closing delimiters are returned as keywords.
+func (l *pdfLexer) next() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		return pdfName(l.readRegular()), nil
+
+	case c == '(':
+		l.pos++
+		return l.readLiteralString(), nil
+
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return l.readDict()
+
+	case c == '<':
+		l.pos++
+		return l.readHexString(), nil
+
+	case c == '[':
+		l.pos++
+		return l.readArray()
+
+	case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return pdfKeyword(">>"), nil
+
+	case c == ']' || c == ')' || c == '>' || c == '{' || c == '}':
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	}
+
+	token := l.readRegular()
+	if token == "" {
+		// An unexpected delimiter, skip it.
+		l.pos++
+		return pdfKeyword(""), nil
+	}
+
+	if v, err := strconv.ParseFloat(token, 64); err == nil && (c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9')) {
+		return v, nil
+	}
+
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	return pdfKeyword(token), nil
+}
+
+// value reads the next object, combining "num gen R" into a reference.
+func (l *pdfLexer) value() (any, error) {
+	v, err := l.next()
+	if err != nil {
+		return nil, err
+	}
+
+	num, ok := v.(float64)
+	if !ok {
+		return v, nil
+	}
+
+	// Look ahead for "gen R".
+	saved := l.pos
+	gen, err := l.next()
+	if g, ok := gen.(float64); ok && err == nil {
+		if r, err := l.next(); err == nil && r == pdfKeyword("R") {
+			return pdfRef{Num: int(num), Gen: int(g)}, nil
+		}
+	}
+	l.pos = saved
+
+	return num, nil
+}
+
+func (l *pdfLexer) readRegular() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+
+	token := string(l.data[start:l.pos])
+	if strings.Contains(token, "#") {
+		// Names may contain #xx escapes.
+		token = pdfNameEscapeRegexp.ReplaceAllStringFunc(token, func(s string) string {
+			b, _ := hex.DecodeString(s[1:])
+			return string(b)
+		})
+	}
+
+	return token
+}
+
+var pdfNameEscapeRegexp = regexp.MustCompile(`#[0-9A-Fa-f]{2}`)
+
+func (l *pdfLexer) readLiteralString() pdfString {
+	var (
+		buf   []byte
+		depth = 1
+	)
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return buf
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return buf
+			}
+
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+
+		buf = append(buf, c)
+	}
+
+	return buf
+}
+
+func (l *pdfLexer) readHexString() pdfString {
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	buf := make([]byte, len(digits)/2)
+	_, _ = hex.Decode(buf, digits)
+	return buf
+}
+
+func (l *pdfLexer) readDict() (pdfDict, error) {
+	if err := l.enter(); err != nil {
+		return nil, err
+	}
+	defer l.leave()
+
+	dict := make(pdfDict)
+	for {
+		key, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+
+		if key == pdfKeyword(">>") {
+			return dict, nil
+		}
+
+		name, ok := key.(pdfName)
+		if !ok {
+			continue
+		}
+
+		value, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+
+		if value == pdfKeyword(">>") {
+			return dict, nil
+		}
+
+		dict[name] = value
+	}
+}
+
+func (l *pdfLexer) readArray() ([]any, error) {
+	if err := l.enter(); err != nil {
+		return nil, err
+	}
+	defer l.leave()
+
+	var array []any
+	for {
+		value, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+
+		if value == pdfKeyword("]") {
+			return array, nil
+		}
+
+		array = append(array, value)
+	}
+}
+
+// enter starts reading a nested array or dictionary.
+func (l *pdfLexer) enter() error {
+	if l.depth >= maxPDFDepth {
+		return errPDFTooDeep
+	}
+
+	l.depth++
+	return nil
+}
+
+func (l *pdfLexer) leave() {
+	l.depth--
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// pdfDocument is a parsed PDF document.
+type pdfDocument struct {
+	data    []byte
+	objects map[int]any
+	cmaps   map[pdfRef]*pdfCMap
+	maxText int      // Max size of extracted text in bytes, 0 means unlimited.
+	decoded int      // Total size of decoded streams, limited by maxPDFDecodedSize.
+	streams [][2]int // Locations of stream data in data.
+}
+
+var pdfObjectRegexp = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+func newPDFDocument(data []byte) *pdfDocument {
+	doc := &pdfDocument{
+		data:    data,
+		objects: make(map[int]any),
+		cmaps:   make(map[pdfRef]*pdfCMap),
+	}
+
+	// Objects are located by scanning rather than via the xref table, which makes the parser tolerant to damaged files.
+	// Later objects override earlier ones, just like incremental updates do.
+	var objectStreams []pdfStream
+	for _, match := range pdfObjectRegexp.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+
+		value, ok := doc.parseObject(match[1])
+		if !ok {
+			continue
+		}
+
+		doc.objects[num] = value
+		if stream, ok := value.(pdfStream); ok && stream.Dict["Type"] == pdfName("ObjStm") {
+			objectStreams = append(objectStreams, stream)
+		}
+	}
+
+	for _, stream := range objectStreams {
+		doc.loadObjectStream(stream)
+	}
+
+	return doc
+}
+
+// parseObject parses an object body (and its stream, if any) that starts at pos.
+func (doc *pdfDocument) parseObject(pos int) (any, bool) {
+	l := &pdfLexer{data: doc.data, pos: pos}
+	value, err := l.value()
+	if err != nil {
+		return nil, false
+	}
+
+	dict, ok := value.(pdfDict)
+	if !ok {
+		return value, true
+	}
+
+	saved := l.pos
+	if keyword, err := l.next(); err != nil || keyword != pdfKeyword("stream") {
+		l.pos = saved
+		return dict, true
+	}
+
+	// Stream data starts after an EOL marker.
+	start := l.pos
+	if start < len(doc.data) && doc.data[start] == '\r' {
+		start++
+	}
+	if start < len(doc.data) && doc.data[start] == '\n' {
+		start++
+	}
+
+	end := -1
+	if length, ok := dict["Length"].(float64); ok && start+int(length) <= len(doc.data) {
+		tail := bytes.TrimLeft(doc.data[start+int(length):], " \t\r\n")
+		if bytes.HasPrefix(tail, []byte("endstream")) {
+			end = start + int(length)
+		}
+	}
+	if end < 0 {
+		i := bytes.Index(doc.data[start:], []byte("endstream"))
+		if i < 0 {
+			return nil, false
+		}
+		end = start + i
+		for end > start && (doc.data[end-1] == '\n' || doc.data[end-1] == '\r') {
+			end--
+		}
+	}
+
+	doc.streams = append(doc.streams, [2]int{start, end})
+	return pdfStream{Dict: dict, Data: doc.data[start:end]}, true
+}
+
+// loadObjectStream loads objects compressed into an object stream.
+func (doc *pdfDocument) loadObjectStream(stream pdfStream) {
+	data, err := doc.decodeStream(stream)
+	if err != nil {
+		return
+	}
+
+	n, _ := stream.Dict["N"].(float64)
+	first, _ := stream.Dict["First"].(float64)
+
+	header := &pdfLexer{data: data}
+	for i := 0; i < int(n); i++ {
+		numValue, _ := header.next()
+		offsetValue, _ := header.next()
+
+		num, ok1 := numValue.(float64)
+		offset, ok2 := offsetValue.(float64)
+		if !ok1 || !ok2 {
+			return
+		}
+
+		if _, exists := doc.objects[int(num)]; exists {
+			continue
+		}
+
+		l := &pdfLexer{data: data, pos: int(first) + int(offset)}
+		if l.pos >= len(data) {
+			continue
+		}
+
+		if value, err := l.value(); err == nil {
+			doc.objects[int(num)] = value
+		}
+	}
+}
+
+// resolve dereferences an indirect reference.
+func (doc *pdfDocument) resolve(value any) any {
+	for i := 0; i < 32; i++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		value = doc.objects[ref.Num]
+	}
+
+	return nil
+}
+
+func (doc *pdfDocument) dict(value any) pdfDict {
+	switch v := doc.resolve(value).(type) {
+	case pdfDict:
+		return v
+	case pdfStream:
+		return v.Dict
+	}
+
+	return nil
+}
+
+// trailers returns trailer dictionaries of the document, the latest ones first.
+func (doc *pdfDocument) trailers() []pdfDict {
+	var trailers []pdfDict
+
+	keyword := []byte("trailer")
+	for end := len(doc.data); end > 0; {
+		i := bytes.LastIndex(doc.data[:end], keyword)
+		if i < 0 {
+			break
+		}
+		end = i
+
+		// The keyword must stand on its own rather than be a part of a name or stream data.
+		if i > 0 && !isPDFSpace(doc.data[i-1]) || doc.inStream(i) {
+			continue
+		}
+		l := &pdfLexer{data: doc.data, pos: i + len(keyword)}
+		if trailer, err := l.value(); err == nil {
+			if dict, ok := trailer.(pdfDict); ok {
+				trailers = append(trailers, dict)
+			}
+		}
+	}
+
+	// Cross-reference streams carry trailer entries in their dictionaries.
+	for _, object := range doc.objects {
+		if stream, ok := object.(pdfStream); ok && stream.Dict["Type"] == pdfName("XRef") {
+			trailers = append(trailers, stream.Dict)
+		}
+	}
+
+	return trailers
+}
+
+// inStream reports whether pos is within stream data.
+func (doc *pdfDocument) inStream(pos int) bool {
+	for _, r := range doc.streams {
+		if pos >= r[0] && pos < r[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// trailerInfo returns a reference to the document information dictionary.
+func (doc *pdfDocument) trailerInfo() any {
+	for _, trailer := range doc.trailers() {
+		if trailer["Info"] != nil {
+			return trailer["Info"]
+		}
+	}
+
+	return nil
+}
+
+// encrypted reports whether the document is encrypted.
+func (doc *pdfDocument) encrypted() bool {
+	for _, trailer := range doc.trailers() {
+		if trailer["Encrypt"] != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pdfPage is a page with its (possibly inherited) resources.
+type pdfPage struct {
+	Dict      pdfDict
+	Resources pdfDict
+}
+
+// pages returns document pages in order.
+func (doc *pdfDocument) pages() []pdfPage {
+	var pages []pdfPage
+
+	for _, object := range doc.objects {
+		catalog, ok := object.(pdfDict)
+		if !ok || catalog["Type"] != pdfName("Catalog") {
+			continue
+		}
+
+		visited := make(map[pdfRef]bool)
+		doc.walkPages(catalog["Pages"], nil, visited, &pages)
+		if len(pages) > 0 {
+			return pages
+		}
+	}
+
+	// No usable page tree, fall back to all page objects in order of their numbers.
+	var nums []int
+	for num, object := range doc.objects {
+		if dict, ok := object.(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+
+	for _, num := range nums {
+		dict := doc.objects[num].(pdfDict)
+		pages = append(pages, pdfPage{Dict: dict, Resources: doc.dict(dict["Resources"])})
+	}
+
+	return pages
+}
+
+func (doc *pdfDocument) walkPages(node any, resources pdfDict, visited map[pdfRef]bool, pages *[]pdfPage) {
+	if ref, ok := node.(pdfRef); ok {
+		if visited[ref] {
+			return
+		}
+		visited[ref] = true
+	}
+
+	dict := doc.dict(node)
+	if dict == nil {
+		return
+	}
+
+	if r := doc.dict(dict["Resources"]); r != nil {
+		resources = r
+	}
+
+	if dict["Type"] == pdfName("Page") {
+		*pages = append(*pages, pdfPage{Dict: dict, Resources: resources})
+		return
+	}
+
+	kids, _ := doc.resolve(dict["Kids"]).([]any)
+	for _, kid := range kids {
+		doc.walkPages(kid, resources, visited, pages)
+	}
+}
+
+// pageText writes text of a page into sb.
+func (doc *pdfDocument) pageText(sb *strings.Builder, page pdfPage) {
+	var content []byte
+	switch v := doc.resolve(page.Dict["Contents"]).(type) {
+	case pdfStream:
+		content, _ = doc.decodeStream(v)
+	case []any:
+		for _, item := range v {
+			if stream, ok := doc.resolve(item).(pdfStream); ok && len(content) < maxPDFStreamSize {
+				data, err := doc.decodeStream(stream)
+				if err == nil {
+					content = append(content, data...)
+					content = append(content, '\n')
+				}
+			}
+		}
+	}
+
+	fonts := doc.dict(page.Resources["Font"])
+	doc.contentText(sb, content, fonts)
+}
+
+// textFull reports whether sb has reached the text size limit.
+func (doc *pdfDocument) textFull(sb *strings.Builder) bool {
+	return doc.maxText > 0 && sb.Len() >= doc.maxText
+}
+
+// contentText interprets text operators of a content stream.
+func (doc *pdfDocument) contentText(sb *strings.Builder, content []byte, fonts pdfDict) {
+	var (
+		l        = &pdfLexer{data: content}
+		operands []any
+		font     *pdfFont
+		lastY    float64
+	)
+
+	newLine := func() {
+		sb.WriteByte('\n')
+	}
+
+	show := func(s pdfString) {
+		sb.WriteString(font.decode(s))
+	}
+
+	for !doc.textFull(sb) {
+		value, err := l.next()
+		if err != nil {
+			return
+		}
+
+		op, ok := value.(pdfKeyword)
+		if !ok {
+			operands = append(operands, value)
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					font = doc.font(fonts[name])
+				}
+			}
+
+		case "Tj":
+			if len(operands) >= 1 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					show(s)
+				}
+			}
+
+		case "'", "\"":
+			newLine()
+			if len(operands) >= 1 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					show(s)
+				}
+			}
+
+		case "TJ":
+			if len(operands) >= 1 {
+				array, _ := operands[len(operands)-1].([]any)
+				for _, item := range array {
+					switch v := item.(type) {
+					case pdfString:
+						show(v)
+					case float64:
+						// Large negative kerning is a word gap.
+						if v < -150 {
+							sb.WriteByte(' ')
+						}
+					}
+				}
+			}
+
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, ok := operands[len(operands)-1].(float64); ok && ty != 0 {
+					newLine()
+				} else {
+					sb.WriteByte(' ')
+				}
+			}
+
+		case "Tm":
+			if len(operands) >= 6 {
+				if y, ok := operands[len(operands)-1].(float64); ok && y != lastY {
+					lastY = y
+					newLine()
+				}
+			}
+
+		case "T*":
+			newLine()
+
+		case "ET":
+			sb.WriteByte(' ')
+
+		case "ID":
+			// Inline image data is binary, skip it up to the "EI" operator.
+			i := bytes.Index(content[l.pos:], []byte("EI"))
+			for i >= 0 {
+				end := l.pos + i + 2
+				if end >= len(content) || isPDFSpace(content[end]) {
+					break
+				}
+				next := bytes.Index(content[end:], []byte("EI"))
+				if next < 0 {
+					i = -1
+					break
+				}
+				i = end - l.pos + next
+			}
+			if i < 0 {
+				return
+			}
+			l.pos += i + 2
+		}
+
+		operands = operands[:0]
+	}
+}
+
+// pdfFont decodes strings shown with a font.
+type pdfFont struct {
+	cmap      *pdfCMap
+	composite bool // Type0 fonts use multibyte codes that are meaningless without a CMap.
+}
+
+func (doc *pdfDocument) font(value any) *pdfFont {
+	dict := doc.dict(value)
+	if dict == nil {
+		return nil
+	}
+
+	font := &pdfFont{composite: dict["Subtype"] == pdfName("Type0")}
+	if ref, ok := dict["ToUnicode"].(pdfRef); ok {
+		cmap, exists := doc.cmaps[ref]
+		if !exists {
+			if stream, ok := doc.resolve(ref).(pdfStream); ok {
+				if data, err := doc.decodeStream(stream); err == nil {
+					cmap = parsePDFCMap(data)
+				}
+			}
+			doc.cmaps[ref] = cmap
+		}
+		font.cmap = cmap
+	}
+
+	return font
+}
+
+func (f *pdfFont) decode(s pdfString) string {
+	if f != nil && f.cmap != nil {
+		return f.cmap.decode(s)
+	}
+
+	if f != nil && f.composite {
+		return ""
+	}
+
+	// Simple fonts mostly use Latin-based encodings.
+	runes := make([]rune, len(s))
+	for i, c := range s {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// pdfCMap maps character codes to Unicode text.
+type pdfCMap struct {
+	codeLength int
+	mapping    map[string]string
+}
+
+func parsePDFCMap(data []byte) *pdfCMap {
+	cmap := &pdfCMap{codeLength: 1, mapping: make(map[string]string)}
+
+	var (
+		l        = &pdfLexer{data: data}
+		operands []any
+	)
+	for {
+		value, err := l.value()
+		if err != nil {
+			break
+		}
+
+		keyword, ok := value.(pdfKeyword)
+		if !ok {
+			operands = append(operands, value)
+			continue
+		}
+
+		switch keyword {
+		case "endcodespacerange":
+			if len(operands) >= 1 {
+				if lo, ok := operands[0].(pdfString); ok && len(lo) > 0 {
+					cmap.codeLength = len(lo)
+				}
+			}
+
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					cmap.mapping[string(src)] = decodeUTF16BE(dst)
+				}
+			}
+
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) {
+					continue
+				}
+				cmap.addRange(lo, hi, operands[i+2])
+			}
+		}
+
+		operands = operands[:0]
+	}
+
+	return cmap
+}
+
+func (c *pdfCMap) addRange(lo, hi pdfString, dst any) {
+	const maxRange = 1 << 16
+
+	from, to := codeValue(lo), codeValue(hi)
+	if to < from || to-from > maxRange {
+		return
+	}
+
+	for code := from; code <= to; code++ {
+		src := codeBytes(code, len(lo))
+		i := code - from
+
+		switch d := dst.(type) {
+		case pdfString:
+			// The last byte of destination is incremented through the range.
+			units := utf16Units(d)
+			if len(units) == 0 {
+				continue
+			}
+			units[len(units)-1] += uint16(i)
+			c.mapping[string(src)] = string(utf16.Decode(units))
+		case []any:
+			if i < len(d) {
+				if s, ok := d[i].(pdfString); ok {
+					c.mapping[string(src)] = decodeUTF16BE(s)
+				}
+			}
+		}
+	}
+}
+
+func (c *pdfCMap) decode(s pdfString) string {
+	var sb strings.Builder
+	for i := 0; i+c.codeLength <= len(s); i += c.codeLength {
+		if text, ok := c.mapping[string(s[i:i+c.codeLength])]; ok {
+			sb.WriteString(text)
+		}
+	}
+
+	return sb.String()
+}
+
+func codeValue(b []byte) int {
+	v := 0
+	for _, c := range b {
+		v = v<<8 | int(c)
+	}
+	return v
+}
+
+func codeBytes(v, length int) []byte {
+	b := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b
+}
+
+func utf16Units(b []byte) []uint16 {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return units
+}
+
+func decodeUTF16BE(b []byte) string {
+	return string(utf16.Decode(utf16Units(b)))
+}
+
+// decodePDFTextString decodes a text string that's either UTF-16BE (with BOM) or PDFDocEncoding.
+func decodePDFTextString(s pdfString) string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		return strings.TrimSpace(decodeUTF16BE(s[2:]))
+	}
+
+	runes := make([]rune, len(s))
+	for i, c := range s {
+		runes[i] = rune(c)
+	}
+	return strings.TrimSpace(string(runes))
+}
+
+// decodeStream decodes stream data within the document budget of decoded data.
+func (doc *pdfDocument) decodeStream(stream pdfStream) ([]byte, error) {
+	if doc.decodeFull() {
+		return nil, errPDFTooLarge
+	}
+
+	limit := min(maxPDFStreamSize, maxPDFDecodedSize-doc.decoded)
+	data, err := decodePDFStream(stream, limit)
+	if err == errPDFStreamTooLarge && limit < maxPDFStreamSize {
+		// The stream would be fine on its own, but the document has used up the budget.
+		doc.decoded = maxPDFDecodedSize
+		return nil, errPDFTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc.decoded += len(data)
+	return data, nil
+}
+
+// decodeFull reports whether the document has used up the budget of decoded data.
+func (doc *pdfDocument) decodeFull() bool {
+	return doc.decoded >= maxPDFDecodedSize
+}
+
+// decodePDFStream applies stream filters.
+// Data decoded by any of the filters is limited to limit bytes.
+func decodePDFStream(stream pdfStream, limit int) ([]byte, error) {
+	var filters []any
+	switch f := stream.Dict["Filter"].(type) {
+	case pdfName:
+		filters = []any{f}
+	case []any:
+		filters = f
+	}
+
+	data := stream.Data
+	for _, filter := range filters {
+		var err error
+		switch filter {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			data, err = inflate(data, limit)
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			data, err = decodeASCII85(data)
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			// Data may be a part of the document buffer, so it's copied rather than appended to in place.
+			l := &pdfLexer{data: append(bytes.Clone(data), '>')}
+			data = l.readHexString()
+		default:
+			err = errors.Errorf("unsupported pdf filter %v", filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > limit {
+			return nil, errPDFStreamTooLarge
+		}
+	}
+
+	return data, nil
+}
+
+func inflate(data []byte, limit int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		// Some producers write raw deflate data without zlib header.
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+	defer func() { _ = r.Close() }()
+
+	result, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if len(result) > limit {
+		return nil, errPDFStreamTooLarge
+	}
+	if err != nil && len(result) == 0 {
+		return nil, err
+	}
+
+	// Truncated streams are common, so partial data is better than nothing.
+	return result, nil
+}
+
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+
+	// "z" stands for four zero bytes, so the output may be up to four times larger than the input.
+	result := make([]byte, 4*len(data))
+	n, _, err := ascii85.Decode(result, data, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return result[:n], nil
+}
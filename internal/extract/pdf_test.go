@@ -0,0 +1,365 @@
+package extract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testPDF builds a PDF document of numbered objects (starting at 1) without a cross-reference table.
+func testPDF(trailer string, objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, object := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	fmt.Fprintf(&buf, "trailer\n%s\n%%%%EOF\n", trailer)
+
+	return buf.Bytes()
+}
+
+// testPDFStream returns a stream object body.
+func testPDFStream(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+// testPDFPages builds a document with a page per stream object.
+func testPDFPages(streams ...string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // The page tree, filled in below.
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var kids []string
+	for _, stream := range streams {
+		page := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", page+1),
+			stream,
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(streams))
+
+	return testPDF("<< /Root 1 0 R >>", objects...)
+}
+
+// testPDFText returns a content stream that shows text.
+func testPDFText(text string) []byte {
+	return []byte("BT /F1 12 Tf 72 720 Td (" + text + ") Tj ET")
+}
+
+func testZlib(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, _ = w.Write(data)
+	_ = w.Close()
+
+	return buf.Bytes()
+}
+
+func testDeflate(data []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	_, _ = w.Write(data)
+	_ = w.Close()
+
+	return buf.Bytes()
+}
+
+func TestPDF(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		maxPages      int
+		maxChars      int
+		wantTitle     string
+		wantText      string
+		wantTruncated bool
+		wantErr       bool
+	}{
+		{
+			name:     "plain",
+			data:     testPDFPages(testPDFStream("", testPDFText("Hello world"))),
+			wantText: "Hello world",
+		},
+		{
+			name: "title",
+			data: testPDF("<< /Root 1 0 R /Info 5 0 R >>",
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+				testPDFStream("", testPDFText("Body")),
+				"<< /Title (Annual report) >>",
+			),
+			wantTitle: "Annual report",
+			wantText:  "Body",
+		},
+		{
+			name:     "flate",
+			data:     testPDFPages(testPDFStream("/Filter /FlateDecode", testZlib(testPDFText("Compressed text")))),
+			wantText: "Compressed text",
+		},
+		{
+			name:     "raw deflate",
+			data:     testPDFPages(testPDFStream("/Filter /FlateDecode", testDeflate(testPDFText("Raw deflate")))),
+			wantText: "Raw deflate",
+		},
+		{
+			name: "filter chain",
+			data: testPDFPages(testPDFStream("/Filter [/ASCIIHexDecode /FlateDecode]",
+				[]byte(hex.EncodeToString(testZlib(testPDFText("Hex and flate")))+">"))),
+			wantText: "Hex and flate",
+		},
+		{
+			name: "nested arrays and dictionaries",
+			data: testPDFPages(testPDFStream("/Extra [[1 [2 [3]]] << /A << /B [4] >> >>]",
+				[]byte("BT /F1 12 Tf [(Nested) -300 (kerning)] TJ ET"))),
+			wantText: "Nested kerning",
+		},
+		{
+			name: "deeply nested content",
+			data: testPDFPages(testPDFStream("",
+				append(testPDFText("Before"), bytes.Repeat([]byte("["), 3<<20)...))),
+			wantText: "Before",
+		},
+		{
+			name: "deeply nested object",
+			data: testPDF("<< /Root 1 0 R >>",
+				"<< /Type /Page /Contents 2 0 R >>",
+				testPDFStream("", testPDFText("Page")),
+				strings.Repeat("<<", 1<<20),
+			),
+			wantText: "Page",
+		},
+		{
+			name:     "wrong stream length",
+			data:     bytes.Replace(testPDFPages(testPDFStream("", testPDFText("Length"))), []byte("/Length "), []byte("/Length 1"), 1),
+			wantText: "Length",
+		},
+		{
+			name: "max pages",
+			data: testPDFPages(
+				testPDFStream("", testPDFText("First")),
+				testPDFStream("", testPDFText("Second")),
+			),
+			maxPages:      1,
+			wantText:      "First",
+			wantTruncated: true,
+		},
+		{
+			name: "max chars",
+			data: testPDFPages(
+				testPDFStream("", testPDFText("First page")),
+				testPDFStream("", testPDFText("Second page")),
+			),
+			maxChars:      1,
+			wantText:      "First page",
+			wantTruncated: true,
+		},
+		{name: "not a pdf", data: []byte("hello"), wantErr: true},
+		{
+			name:    "encrypted",
+			data:    testPDF("<< /Root 1 0 R /Encrypt 2 0 R >>", "<< /Type /Catalog >>", "<< /Filter /Standard >>"),
+			wantErr: true,
+		},
+		{
+			name: "encrypted with xref stream",
+			data: testPDF("<< >>",
+				"<< /Type /Page /Contents 2 0 R >>",
+				testPDFStream("", testPDFText("Secret")),
+				testPDFStream("/Type /XRef /Encrypt 4 0 R", nil),
+				"<< /Filter /Standard >>",
+			),
+			wantErr: true,
+		},
+		{
+			name:     "encrypt in text",
+			data:     testPDFPages(testPDFStream("", testPDFText("See /Encrypt and trailer << /Encrypt 1 0 R >>"))),
+			wantText: "See /Encrypt and trailer << /Encrypt 1 0 R >>",
+		},
+		{name: "no pages", data: testPDF("<< >>", "<< /Type /Catalog >>"), wantErr: true},
+		{
+			name:    "broken flate",
+			data:    testPDFPages(testPDFStream("/Filter /FlateDecode", []byte("not compressed"))),
+			wantErr: true,
+		},
+		{
+			name:    "unterminated stream",
+			data:    []byte("%PDF-1.4\n1 0 obj\n<< /Type /Page /Contents 2 0 R >>\nendobj\n2 0 obj\n<< /Length 100 >>\nstream\nBT (Text"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := PDF(tt.data, tt.maxPages, tt.maxChars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PDF() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if result.Title != tt.wantTitle {
+				t.Errorf("PDF() title = %q, want %q", result.Title, tt.wantTitle)
+			}
+			if result.Text != tt.wantText {
+				t.Errorf("PDF() text = %q, want %q", result.Text, tt.wantText)
+			}
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("PDF() truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestPDFKeepsData(t *testing.T) {
+	data := testPDFPages(testPDFStream("/Filter /ASCIIHexDecode", []byte(hex.EncodeToString(testPDFText("Hex")))))
+	original := bytes.Clone(data)
+
+	result, err := PDF(data, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Text != "Hex" {
+		t.Errorf("PDF() text = %q, want %q", result.Text, "Hex")
+	}
+	if !bytes.Equal(data, original) {
+		t.Error("PDF() has modified the document data")
+	}
+}
+
+func TestPDFDecodedSize(t *testing.T) {
+	// Every page decodes to 20 MiB, so the document exceeds the budget while each stream is within the limit.
+	padding := bytes.Repeat([]byte(" "), 20<<20)
+
+	tests := []struct {
+		name          string
+		text          bool
+		wantText      string
+		wantTruncated bool
+		wantErr       error
+	}{
+		{
+			name:          "text",
+			text:          true,
+			wantText:      "Page 1\n\nPage 2\n\nPage 3\n\nPage 4\n\nPage 5\n\nPage 6",
+			wantTruncated: true,
+		},
+		{name: "no text", wantErr: errPDFTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var streams []string
+			for i := range 8 {
+				var content []byte
+				if tt.text {
+					content = testPDFText(fmt.Sprintf("Page %d", i+1))
+				}
+				content = append(content, padding...)
+				streams = append(streams, testPDFStream("/Filter /FlateDecode", testZlib(content)))
+			}
+
+			result, err := PDF(testPDFPages(streams...), 0, 0)
+			if err != tt.wantErr {
+				t.Fatalf("PDF() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if result.Text != tt.wantText {
+				t.Errorf("PDF() text = %q, want %q", result.Text, tt.wantText)
+			}
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("PDF() truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestPDFLexerDepth(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr error
+	}{
+		{name: "max depth array", data: strings.Repeat("[", maxPDFDepth) + strings.Repeat("]", maxPDFDepth)},
+		{name: "max depth dict", data: strings.Repeat("<< /A ", maxPDFDepth) + "1" + strings.Repeat(" >>", maxPDFDepth)},
+		{name: "too deep array", data: strings.Repeat("[", maxPDFDepth+1) + strings.Repeat("]", maxPDFDepth+1), wantErr: errPDFTooDeep},
+		{name: "too deep dict", data: strings.Repeat("<< /A ", maxPDFDepth+1), wantErr: errPDFTooDeep},
+		{name: "huge nesting", data: strings.Repeat("[", 3<<20), wantErr: errPDFTooDeep},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &pdfLexer{data: []byte(tt.data)}
+			_, err := l.value()
+			if err != tt.wantErr {
+				t.Fatalf("value() error = %v, want %v", err, tt.wantErr)
+			}
+			if l.depth != 0 {
+				t.Errorf("depth = %d after value(), want 0", l.depth)
+			}
+		})
+	}
+}
+
+func TestDecodePDFStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  any
+		data    []byte
+		limit   int
+		want    []byte
+		wantErr bool
+	}{
+		{name: "no filter", data: []byte("plain"), want: []byte("plain")},
+		{name: "flate", filter: pdfName("FlateDecode"), data: testZlib([]byte("flate")), want: []byte("flate")},
+		{name: "short flate name", filter: pdfName("Fl"), data: testZlib([]byte("flate")), want: []byte("flate")},
+		{name: "hex", filter: pdfName("ASCIIHexDecode"), data: []byte("68 65 78>"), want: []byte("hex")},
+		{name: "hex without terminator", filter: pdfName("AHx"), data: []byte("686578"), want: []byte("hex")},
+		{name: "ascii85", filter: pdfName("ASCII85Decode"), data: []byte("<~BOu!rDZ~>"), want: []byte("hello")},
+		{name: "ascii85 zeros", filter: pdfName("A85"), data: []byte("zzzzz~>"), want: make([]byte, 20)},
+		{name: "bad ascii85", filter: pdfName("A85"), data: []byte("{}~>"), wantErr: true},
+		{name: "unsupported", filter: pdfName("DCTDecode"), data: []byte("jpeg"), wantErr: true},
+		{
+			name:    "decompression bomb",
+			filter:  pdfName("FlateDecode"),
+			data:    testZlib(make([]byte, maxPDFStreamSize+1)),
+			wantErr: true,
+		},
+		{name: "flate over limit", filter: pdfName("FlateDecode"), data: testZlib([]byte("flate")), limit: 4, wantErr: true},
+		{name: "hex over limit", filter: pdfName("AHx"), data: []byte("686578>"), limit: 2, wantErr: true},
+		{name: "within limit", filter: pdfName("AHx"), data: []byte("686578>"), limit: 3, want: []byte("hex")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := tt.limit
+			if limit == 0 {
+				limit = maxPDFStreamSize
+			}
+			stream := pdfStream{Dict: pdfDict{}, Data: tt.data}
+			if tt.filter != nil {
+				stream.Dict["Filter"] = tt.filter
+			}
+
+			got, err := decodePDFStream(stream, limit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodePDFStream() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, tt.want) {
+				t.Errorf("decodePDFStream() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package gpt
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// gptCacheConfig defines the response cache.
+// Zero values mean "use the default".
+type gptCacheConfig struct {
+	Path       string        `yaml:"path"`        // Directory of cached responses, empty disables the cache.
+	TTL        time.Duration `yaml:"ttl"`         // Max age of a cached response.
+	MaxEntries int           `yaml:"max_entries"` // Max number of cached responses.
+	MaxSize    int64         `yaml:"max_size"`    // Max total size of cached responses, in bytes.
+}
+
+// Default cache parameters.
+const (
+	defaultCacheTTL        = 24 * time.Hour
+	defaultCacheMaxEntries = 1000
+	defaultCacheMaxSize    = 100 << 20
+)
+
+// cacheKeyVersion changes whenever cache key or entry format changes.
+const cacheKeyVersion = 1
+
+func (c *gptCacheConfig) validate() error {
+	if c.TTL < 0 || c.MaxEntries < 0 || c.MaxSize < 0 {
+		return errors.New("cache values must be non-negative")
+	}
+
+	return nil
+}
+
+func (c *gptCacheConfig) ttl() time.Duration {
+	if c.TTL == 0 {
+		return defaultCacheTTL
+	}
+
+	return c.TTL
+}
+
+func (c *gptCacheConfig) maxEntries() int {
+	if c.MaxEntries == 0 {
+		return defaultCacheMaxEntries
+	}
+
+	return c.MaxEntries
+}
+
+func (c *gptCacheConfig) maxSize() int64 {
+	if c.MaxSize == 0 {
+		return defaultCacheMaxSize
+	}
+
+	return c.MaxSize
+}
+
+// responseCache is a content-addressed cache of responses to conversation-starting requests.
+// Each response is stored in a separate file named after the hash of its request.
+type responseCache struct {
+	api    api
+	mutex  sync.Mutex // Guards cache directory.
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func newResponseCache(api api) *responseCache {
+	return &responseCache{api: api}
+}
+
+// cacheEntry is a cached response.
+type cacheEntry struct {
+	Created  time.Time `json:"created"`
+	Response Response  `json:"response"`
+}
+
+// do returns a cached response to the request, or generates and caches a new one.
+// Only requests that start a conversation are cached, since follow-ups depend on the conversation.
+// Cached responses cost nothing, so their usage is zero.
+// A cached response belongs to the conversation it's been generated in,
+// so a hit starts a new conversation: the response ID isn't reused, and local history is built from the request.
+func (c *responseCache) do(cfg *gptConfig, req Request, generate func() (Response, error)) (Response, error) {
+	if cfg.Cache.Path == "" || req.PrevResponseID != "" || len(req.History) > 0 || req.Summary != "" {
+		return generate()
+	}
+
+	key := c.key(cfg, req)
+	if response, found := c.load(cfg, key); found {
+		c.hits.Add(1)
+		c.log(true, key)
+
+		response.ID = ""
+		response.History = nil
+		if cfg.Conversation.useLocalHistory(c.api) {
+			response.History = cfg.Conversation.trimHistory(req, response.Text)
+		}
+		return response, nil
+	}
+
+	c.misses.Add(1)
+	c.log(false, key)
+
+	response, err := generate()
+	if err != nil {
+		return response, err
+	}
+
+	if response.Compaction == nil {
+		c.store(cfg, key, response)
+	}
+	return response, nil
+}
+
+func (c *responseCache) log(hit bool, key string) {
+	log.Info().
+		Bool("hit", hit).
+		Str("key", key[:16]).
+		Int64("hits", c.hits.Load()).
+		Int64("misses", c.misses.Load()).
+		Msg("response cache")
+}
+
+// key returns a hash of everything a response to a conversation-starting request depends on.
+func (c *responseCache) key(cfg *gptConfig, req Request) string {
+	images := make([]string, 0, len(req.Images))
+	for _, image := range req.Images {
+		hash := sha256.Sum256(image.Data)
+		images = append(images, image.MIMEType+":"+hex.EncodeToString(hash[:]))
+	}
+
+	// Rendered prompt, since it may depend on the user and the time.
+	prompt := sha256.Sum256([]byte(cfg.prompt(req)))
+
+	data, _ := json.Marshal(struct {
+		Version     int
+		API         api
+		Message     string
+		Instruction string
+		Images      []string
+		Persona     string
+		Prompt      string
+		Model       gptModelConfig
+		Tools       []string
+	}{
+		Version:     cacheKeyVersion,
+		API:         c.api,
+		Message:     normalizeInput(req.Message),
+		Instruction: normalizeInput(req.Instruction),
+		Images:      images,
+		Persona:     req.Persona,
+		Prompt:      hex.EncodeToString(prompt[:]),
+		Model:       cfg.Model,
+		Tools:       cfg.Tools,
+	})
+
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// normalizeInput makes inputs that differ in whitespace only equal.
+func normalizeInput(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func (c *responseCache) load(cfg *gptConfig, key string) (Response, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	path := filepath.Join(cfg.Cache.Path, key+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Error().Err(err).Str("path", path).Msg("failed to read cached response")
+		}
+		return Response{}, false
+	}
+
+	var entry cacheEntry
+	err = json.Unmarshal(data, &entry)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("failed to parse cached response")
+		return Response{}, false
+	}
+
+	if time.Since(entry.Created) > cfg.Cache.ttl() {
+		_ = os.Remove(path)
+		return Response{}, false
+	}
+
+	response := entry.Response
+	response.Usage = Usage{}
+	response.Cost = 0
+	return response, true
+}
+
+func (c *responseCache) store(cfg *gptConfig, key string, response Response) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.write(cfg.Cache.Path, key, cacheEntry{Created: time.Now(), Response: response})
+	if err != nil {
+		log.Error().Err(err).Str("path", cfg.Cache.Path).Msg("failed to cache response")
+		return
+	}
+
+	err = c.prune(&cfg.Cache)
+	if err != nil {
+		log.Error().Err(err).Str("path", cfg.Cache.Path).Msg("failed to prune response cache")
+	}
+}
+
+func (c *responseCache) write(dir, key string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	// Write and rename, so that a crash doesn't leave a truncated entry.
+	tmp, err := os.CreateTemp(dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, key+".json"))
+}
+
+// prune removes expired responses, then the oldest ones until the cache fits its limits.
+func (c *responseCache) prune(cfg *gptCacheConfig) error {
+	entries, err := os.ReadDir(cfg.Path)
+	if err != nil {
+		return err
+	}
+
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var (
+		files []file
+		size  int64
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(cfg.Path, entry.Name())
+		if time.Since(info.ModTime()) > cfg.ttl() {
+			_ = os.Remove(path)
+			continue
+		}
+
+		files = append(files, file{path: path, size: info.Size(), modTime: info.ModTime()})
+		size += info.Size()
+	}
+
+	slices.SortFunc(files, func(a, b file) int {
+		return cmp.Compare(a.modTime.UnixNano(), b.modTime.UnixNano())
+	})
+
+	removed := 0
+	for len(files) > cfg.maxEntries() || size > cfg.maxSize() {
+		err = os.Remove(files[0].path)
+		if err != nil {
+			return fmt.Errorf("failed to evict cached response: %w", err)
+		}
+
+		size -= files[0].size
+		files = files[1:]
+		removed++
+	}
+
+	if removed > 0 {
+		log.Debug().Int("removed", removed).Int("entries", len(files)).Int64("size", size).Msg("response cache pruned")
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package gpt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestResponseCacheDo(t *testing.T) {
+	tests := []struct {
+		name      string
+		disabled  bool
+		local     bool
+		req       Request
+		response  Response
+		err       error
+		wantCalls int
+	}{
+		{name: "cached", req: Request{Message: "hello"}, wantCalls: 1},
+		{name: "local history", local: true, req: Request{Message: "hello", Instruction: "be brief"}, wantCalls: 1},
+		{name: "disabled", disabled: true, req: Request{Message: "hello"}, wantCalls: 2},
+		{name: "follow-up", req: Request{Message: "hello", PrevResponseID: "resp_1"}, wantCalls: 2},
+		{name: "history", req: Request{Message: "hello", History: []Message{{Participant: ParticipantUser, Text: "hi"}}}, wantCalls: 2},
+		{name: "summary", req: Request{Message: "hello", Summary: "earlier"}, wantCalls: 2},
+		{name: "compaction", req: Request{Message: "hello"}, response: Response{Compaction: &Compaction{}}, wantCalls: 2},
+		{name: "failure", req: Request{Message: "hello"}, err: errors.New("failed"), wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &gptConfig{Cache: gptCacheConfig{Path: filepath.Join(t.TempDir(), "cache")}}
+			if tt.disabled {
+				cfg.Cache.Path = ""
+			}
+			if tt.local {
+				cfg.Conversation.Mode = conversationModeLocal
+			}
+			c := newResponseCache(apiResponses)
+
+			calls := 0
+			generate := func() (Response, error) {
+				calls++
+				response := tt.response
+				response.Text = "reply"
+				response.Usage = Usage{TotalTokens: 10}
+				response.Cost = 0.01
+				// The response continues the conversation of the request that has generated it.
+				response.ID = fmt.Sprintf("resp_%d", calls)
+				if tt.local {
+					response.History = cfg.Conversation.trimHistory(tt.req, response.Text)
+				}
+				return response, tt.err
+			}
+
+			for i := range 2 {
+				before := calls
+				response, err := c.do(cfg, tt.req, generate)
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("call %d: do() error = %v, want %v", i+1, err, tt.err)
+				}
+				if err != nil {
+					continue
+				}
+
+				if response.Text != "reply" {
+					t.Errorf("call %d: text = %q, want reply", i+1, response.Text)
+				}
+				// Cached responses cost nothing.
+				hit := calls == before
+				if hit && (response.Usage != Usage{} || response.Cost != 0) {
+					t.Errorf("call %d: cached response has usage %+v and cost %v, want zero", i+1, response.Usage, response.Cost)
+				}
+				// Follow-ups to a cached response start a new conversation rather than continue someone else's one.
+				if hit && response.ID != "" {
+					t.Errorf("call %d: cached response has ID %q, want none", i+1, response.ID)
+				}
+				wantHistory := 0
+				if tt.local {
+					wantHistory = 2
+				}
+				if len(response.History) != wantHistory {
+					t.Errorf("call %d: history = %+v, want %d messages", i+1, response.History, wantHistory)
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("got %d generate calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	c := newResponseCache(apiResponses)
+	cfg := &gptConfig{Prompt: "prompt", Model: gptModelConfig{Name: "gpt-4o"}}
+	base := Request{Message: "Hello,  world!\n", Instruction: "be brief"}
+	key := c.key(cfg, base)
+
+	tests := []struct {
+		name     string
+		cfg      *gptConfig
+		api      api
+		req      Request
+		wantSame bool
+	}{
+		{name: "same", req: base, wantSame: true},
+		{name: "whitespace", req: Request{Message: " Hello, world! ", Instruction: "be\tbrief"}, wantSame: true},
+		{name: "message", req: Request{Message: "Hello, world", Instruction: "be brief"}},
+		{name: "instruction", req: Request{Message: base.Message}},
+		{name: "persona", req: Request{Message: base.Message, Instruction: base.Instruction, Persona: "pirate"}},
+		{name: "image", req: Request{Message: base.Message, Instruction: base.Instruction, Images: []Image{{MIMEType: "image/png", Data: []byte("a")}}}},
+		{name: "prompt", cfg: &gptConfig{Prompt: "other", Model: cfg.Model}, req: base},
+		{name: "model", cfg: &gptConfig{Prompt: cfg.Prompt, Model: gptModelConfig{Name: "o3"}}, req: base},
+		{name: "tools", cfg: &gptConfig{Prompt: cfg.Prompt, Model: cfg.Model, Tools: []string{"calc"}}, req: base},
+		{name: "api", api: apiChatCompletions, req: base},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			if tt.cfg != nil {
+				cfg = tt.cfg
+			}
+			c := newResponseCache(tt.api)
+
+			if got := c.key(cfg, tt.req); (got == key) != tt.wantSame {
+				t.Errorf("key() = %s, base key %s, want same %v", got, key, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestResponseCacheExpiry(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		age       time.Duration
+		wantFound bool
+	}{
+		{name: "fresh", age: time.Hour, wantFound: true},
+		{name: "expired", age: defaultCacheTTL + time.Minute},
+		{name: "custom ttl", ttl: time.Minute, age: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &gptConfig{Cache: gptCacheConfig{Path: t.TempDir(), TTL: tt.ttl}}
+			c := newResponseCache(apiResponses)
+
+			err := c.write(cfg.Cache.Path, "key", cacheEntry{Created: time.Now().Add(-tt.age), Response: Response{Text: "reply"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response, found := c.load(cfg, "key")
+			if found != tt.wantFound || (found && response.Text != "reply") {
+				t.Errorf("load() = %+v, %v, want found %v", response, found, tt.wantFound)
+			}
+
+			// Expired entries are removed on load.
+			_, err = os.Stat(filepath.Join(cfg.Cache.Path, "key.json"))
+			if exists := err == nil; exists != tt.wantFound {
+				t.Errorf("entry exists = %v, want %v", exists, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestResponseCachePrune(t *testing.T) {
+	now := time.Now()
+
+	// Files by age, from the newest to the oldest; each one is 10 bytes.
+	files := []struct {
+		name string
+		age  time.Duration
+	}{
+		{name: "a.json", age: time.Minute},
+		{name: "b.json", age: time.Hour},
+		{name: "c.json", age: 2 * time.Hour},
+		{name: "d.json", age: 25 * time.Hour},
+		{name: "e.tmp", age: 48 * time.Hour},
+	}
+
+	tests := []struct {
+		name  string
+		cache gptCacheConfig
+		want  []string
+	}{
+		{name: "defaults", want: []string{"a.json", "b.json", "c.json", "e.tmp"}},
+		{name: "ttl", cache: gptCacheConfig{TTL: 90 * time.Minute}, want: []string{"a.json", "b.json", "e.tmp"}},
+		{name: "max entries", cache: gptCacheConfig{MaxEntries: 2}, want: []string{"a.json", "b.json", "e.tmp"}},
+		{name: "max size", cache: gptCacheConfig{MaxSize: 15}, want: []string{"a.json", "e.tmp"}},
+		{name: "tight limits", cache: gptCacheConfig{MaxEntries: 1, MaxSize: 5}, want: []string{"e.tmp"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range files {
+				path := filepath.Join(dir, f.name)
+				err := os.WriteFile(path, []byte("0123456789"), 0o600)
+				if err == nil {
+					err = os.Chtimes(path, now.Add(-f.age), now.Add(-f.age))
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg := tt.cache
+			cfg.Path = dir
+			err := newResponseCache(apiResponses).prune(&cfg)
+			if err != nil {
+				t.Fatalf("prune() error = %v", err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			slices.Sort(got)
+
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("files after prune() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseCacheStore(t *testing.T) {
+	cfg := &gptConfig{Cache: gptCacheConfig{Path: filepath.Join(t.TempDir(), "cache"), MaxEntries: 2}}
+	c := newResponseCache(apiResponses)
+
+	keys := []string{"a", "b", "c"}
+	for i, key := range keys {
+		c.store(cfg, key, Response{Text: key, Usage: Usage{TotalTokens: 10}})
+
+		// Entries need distinct modification times to be evicted in order.
+		modTime := time.Now().Add(time.Duration(i-len(keys)) * time.Minute)
+		err := os.Chtimes(filepath.Join(cfg.Cache.Path, key+".json"), modTime, modTime)
+		if err != nil {
+			t.Fatalf("entry %s isn't stored: %v", key, err)
+		}
+	}
+
+	// The oldest entry is evicted once the cache is full.
+	if _, found := c.load(cfg, "a"); found {
+		t.Error("entry a is not evicted")
+	}
+	for _, key := range keys[1:] {
+		response, found := c.load(cfg, key)
+		if !found || response.Text != key || response.Usage != (Usage{}) {
+			t.Errorf("load(%s) = %+v, %v, want a cached response without usage", key, response, found)
+		}
+	}
+}
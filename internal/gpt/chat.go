@@ -0,0 +1,253 @@
+package gpt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/openai/openai-go/v3/shared"
+	"github.com/rs/zerolog/log"
+)
+
+// ChatCompletions is a text transformer backed by the Chat Completions API.
+// It's meant for self-hosted OpenAI-compatible servers (llama.cpp, vLLM, Ollama etc.)
+// that don't support the Responses API, so conversation history is always kept locally.
+type ChatCompletions struct {
+	client openai.Client
+	config *configStore
+	keys   *keyPool
+	cache  *responseCache
+}
+
+var (
+	_ Provider          = (*ChatCompletions)(nil)
+	_ KeyStatusReporter = (*ChatCompletions)(nil)
+)
+
+// NewChatCompletions creates a new Chat Completions text transformer.
+// API endpoint is set by env variables, see newClientOptions.
+// Token is a list of API keys, see newKeyPool.
+func NewChatCompletions(token string) (*ChatCompletions, error) {
+	keys, err := newKeyPool(token)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := newConfigStore(apiChatCompletions, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := newClientOptions(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatCompletions{
+		client: openai.NewClient(options...),
+		config: config,
+		keys:   keys,
+		cache:  newResponseCache(apiChatCompletions),
+	}, nil
+}
+
+// Watch reloads config on change until context is canceled.
+func (c *ChatCompletions) Watch(ctx context.Context) {
+	c.config.Watch(ctx)
+}
+
+// KeyStatus returns status of every API key in use.
+func (c *ChatCompletions) KeyStatus() []KeyStatus {
+	return c.keys.KeyStatus()
+}
+
+// Generate generates a new message from the input stream.
+func (c *ChatCompletions) Generate(ctx context.Context, req Request) (Response, error) {
+	cfg := c.config.Load().withPersona(req.Persona)
+	return c.cache.do(cfg, req, func() (Response, error) {
+		return withFallback(cfg, func(cfg *gptConfig) (Response, error) {
+			return c.generate(ctx, cfg, req, func(request openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				return c.client.Chat.Completions.New(ctx, request)
+			})
+		})
+	})
+}
+
+// GenerateStream generates a new message like Generate does, but reports partial text as it's being generated.
+func (c *ChatCompletions) GenerateStream(ctx context.Context, req Request, onUpdate StreamFunc) (Response, error) {
+	cfg := c.config.Load().withPersona(req.Persona)
+	return c.cache.do(cfg, req, func() (Response, error) {
+		return withFallback(cfg, func(cfg *gptConfig) (Response, error) {
+			return c.generate(ctx, cfg, req, func(request openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+				request.StreamOptions.IncludeUsage = param.Opt[bool]{Value: true}
+				return c.streamCompletion(ctx, request, onUpdate)
+			})
+		})
+	})
+}
+
+// generate generates a reply with the model cfg refers to, sending requests with send.
+func (c *ChatCompletions) generate(
+	ctx context.Context,
+	cfg *gptConfig,
+	req Request,
+	send func(request openai.ChatCompletionNewParams) (*openai.ChatCompletion, error),
+) (Response, error) {
+	req, inputUsage, err := reduceLongInput(ctx, cfg, req, c.summarize)
+	if err != nil {
+		return Response{}, err
+	}
+
+	request := c.prepareChatRequest(cfg, req)
+
+	completion, output, toolUsage, err := completeStructured(cfg,
+		func() (*openai.ChatCompletion, Usage, error) {
+			return c.completeWithTools(ctx, request, send)
+		},
+		func(completion *openai.ChatCompletion) (string, Usage) {
+			return completion.Choices[0].Message.Content, convertChatUsage(completion.Usage)
+		},
+	)
+	if err != nil {
+		return Response{}, err
+	}
+
+	response := c.completeResponse(cfg, req, completion.Model, output, completion.Usage)
+	result := withUsage(c.compactIfNeeded(ctx, cfg, req, response), inputUsage.Add(toolUsage))
+	return withCost(cfg, result), nil
+}
+
+// streamCompletion sends a streaming request, reporting partial text, and returns the accumulated completion.
+func (c *ChatCompletions) streamCompletion(ctx context.Context, request openai.ChatCompletionNewParams, onUpdate StreamFunc) (*openai.ChatCompletion, error) {
+	stream := c.client.Chat.Completions.NewStreaming(ctx, request)
+	defer func() { _ = stream.Close() }()
+
+	var (
+		completion openai.ChatCompletionAccumulator
+		raw        strings.Builder
+		lastText   string
+	)
+	for stream.Next() {
+		chunk := stream.Current()
+		completion.AddChunk(chunk)
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		raw.WriteString(chunk.Choices[0].Delta.Content)
+
+		text := partialOutputMarkdown(raw.String())
+		if text != lastText {
+			lastText = text
+			onUpdate(text)
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	return &completion.ChatCompletion, nil
+}
+
+func (c *ChatCompletions) completeResponse(cfg *gptConfig, req Request, model string, output jsonOutput, usage openai.CompletionUsage) Response {
+	log.Debug().
+		Str("model", model).
+		Int64("tokens", usage.TotalTokens).
+		Int64("reasoning_tokens", usage.CompletionTokensDetails.ReasoningTokens).
+		Msg("gpt stats")
+
+	response := Response{
+		ID:      newResponseID(),
+		Model:   model,
+		History: cfg.Conversation.trimHistory(req, output.OutputMarkdown),
+		Summary: req.Summary,
+		Usage:   convertChatUsage(usage),
+	}
+	output.apply(&response)
+	return response
+}
+
+func convertChatUsage(usage openai.CompletionUsage) Usage {
+	return Usage{
+		InputTokens:       usage.PromptTokens,
+		CachedInputTokens: usage.PromptTokensDetails.CachedTokens,
+		OutputTokens:      usage.CompletionTokens,
+		ReasoningTokens:   usage.CompletionTokensDetails.ReasoningTokens,
+		TotalTokens:       usage.TotalTokens,
+	}
+}
+
+// chatMessages converts local history into chat messages.
+func chatMessages(history []Message) []openai.ChatCompletionMessageParamUnion {
+	var messages []openai.ChatCompletionMessageParamUnion
+	for _, message := range history {
+		if message.Participant == ParticipantUser {
+			messages = append(messages, openai.UserMessage(message.Text))
+		} else {
+			messages = append(messages, openai.AssistantMessage(message.Text))
+		}
+	}
+
+	return messages
+}
+
+func (c *ChatCompletions) prepareChatRequest(cfg *gptConfig, request Request) openai.ChatCompletionNewParams {
+	messages := []openai.ChatCompletionMessageParamUnion{openai.SystemMessage(cfg.prompt(request))}
+	if request.Summary != "" {
+		messages = append(messages, openai.SystemMessage(summaryMessage(request.Summary)))
+	}
+	messages = append(messages, chatMessages(cfg.Conversation.recent(request.History))...)
+	messages = append(messages, chatUserMessage(request))
+
+	req := openai.ChatCompletionNewParams{
+		Model:    shared.ChatModel(cfg.Model.modelFor(request)),
+		Messages: messages,
+	}
+
+	if cfg.Model.useJSONSchema() {
+		req.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   outputSchemaName,
+					Schema: outputSchema(),
+					Strict: param.Opt[bool]{Value: true},
+				},
+			},
+		}
+	}
+
+	req.Tools = cfg.chatTools()
+	cfg.Model.applyChat(&req)
+
+	return req
+}
+
+// chatUserMessage returns chat message with request text and images.
+func chatUserMessage(request Request) openai.ChatCompletionMessageParamUnion {
+	if len(request.Images) == 0 && request.Instruction == "" {
+		return openai.UserMessage(request.Message)
+	}
+
+	var parts []openai.ChatCompletionContentPartUnionParam
+	for _, text := range request.texts() {
+		parts = append(parts, openai.TextContentPart(text))
+	}
+
+	for _, image := range request.Images {
+		parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+			URL: image.DataURL(),
+		}))
+	}
+
+	return openai.UserMessage(parts)
+}
+
+func newResponseID() string {
+	var buf [12]byte
+	_, _ = rand.Read(buf[:])
+	return "chat_" + hex.EncodeToString(buf[:])
+}
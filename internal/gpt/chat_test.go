@@ -0,0 +1,106 @@
+package gpt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go/v3"
+)
+
+func TestPrepareChatRequestHistory(t *testing.T) {
+	history := []Message{
+		{Participant: ParticipantUser, Text: "q1"}, {Participant: ParticipantBot, Text: "a1"},
+		{Participant: ParticipantUser, Text: "q2"}, {Participant: ParticipantBot, Text: "a2"},
+	}
+
+	tests := []struct {
+		name         string
+		maxDepth     int
+		req          Request
+		wantMessages []string // Role and text of messages.
+	}{
+		{name: "first message", req: Request{Message: "hi"}, wantMessages: []string{"system: prompt", "user: hi"}},
+		{
+			name:         "history",
+			req:          Request{Message: "hi", History: history},
+			wantMessages: []string{"system: prompt", "user: q1", "assistant: a1", "user: q2", "assistant: a2", "user: hi"},
+		},
+		{
+			name:         "max depth",
+			maxDepth:     1,
+			req:          Request{Message: "hi", History: history},
+			wantMessages: []string{"system: prompt", "user: q2", "assistant: a2", "user: hi"},
+		},
+		{
+			name:         "summary",
+			maxDepth:     1,
+			req:          Request{Message: "hi", History: history, Summary: "earlier"},
+			wantMessages: []string{"system: prompt", "system: " + summaryMessage("earlier"), "user: q2", "assistant: a2", "user: hi"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &gptConfig{Prompt: "prompt", Conversation: gptConversationConfig{MaxDepth: tt.maxDepth}}
+			req := (&ChatCompletions{}).prepareChatRequest(cfg, tt.req)
+
+			var messages []string
+			for _, message := range req.Messages {
+				messages = append(messages, chatMessageText(message))
+			}
+			if strings.Join(messages, "\n") != strings.Join(tt.wantMessages, "\n") {
+				t.Errorf("messages = %q, want %q", messages, tt.wantMessages)
+			}
+		})
+	}
+}
+
+// chatMessageText returns role and text of a message.
+func chatMessageText(message openai.ChatCompletionMessageParamUnion) string {
+	switch {
+	case message.OfSystem != nil:
+		return "system: " + message.OfSystem.Content.OfString.Value
+	case message.OfUser != nil:
+		return "user: " + message.OfUser.Content.OfString.Value
+	case message.OfAssistant != nil:
+		return "assistant: " + message.OfAssistant.Content.OfString.Value
+	}
+
+	return "unknown"
+}
+
+func TestChatUserMessage(t *testing.T) {
+	image := Image{MIMEType: "image/jpeg", Data: []byte("jpeg")}
+
+	tests := []struct {
+		name      string
+		req       Request
+		wantParts []string // Parts of the message, nil if it's plain text.
+	}{
+		{name: "text", req: Request{Message: "hi"}},
+		{name: "images", req: Request{Message: "what is it", Images: []Image{image}}, wantParts: []string{"what is it", image.DataURL()}},
+		{name: "instruction", req: Request{Message: "text", Instruction: "translate"}, wantParts: []string{"translate", "text"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := chatUserMessage(tt.req).OfUser.Content
+
+			var parts []string
+			for _, part := range content.OfArrayOfContentParts {
+				if part.OfText != nil {
+					parts = append(parts, part.OfText.Text)
+				}
+				if part.OfImageURL != nil {
+					parts = append(parts, part.OfImageURL.ImageURL.URL)
+				}
+			}
+			if strings.Join(parts, "\n") != strings.Join(tt.wantParts, "\n") {
+				t.Errorf("message parts = %q, want %q", parts, tt.wantParts)
+			}
+			if tt.wantParts == nil && content.OfString.Value != tt.req.Message {
+				t.Errorf("message = %q, want %q", content.OfString.Value, tt.req.Message)
+			}
+		})
+	}
+}
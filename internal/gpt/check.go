@@ -0,0 +1,92 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openai/openai-go/v3"
+)
+
+// HealthChecker checks that a provider can serve requests.
+type HealthChecker interface {
+	// Check checks that the API is reachable and configured models exist.
+	// Returned errors are classified (see Error), so that callers may tell whether a retry makes sense.
+	Check(ctx context.Context) error
+}
+
+var (
+	_ HealthChecker = (*GPT)(nil)
+	_ HealthChecker = (*ChatCompletions)(nil)
+)
+
+// Check checks that the API is reachable and configured models exist.
+func (g *GPT) Check(ctx context.Context) error {
+	return checkModels(ctx, &g.client, g.keys, g.config.Load())
+}
+
+// Check checks that the API is reachable and configured models exist.
+func (c *ChatCompletions) Check(ctx context.Context) error {
+	return checkModels(ctx, &c.client, c.keys, c.config.Load())
+}
+
+// checkModels lists models and verifies that configured models are among them.
+func checkModels(ctx context.Context, client *openai.Client, keys *keyPool, cfg *gptConfig) error {
+	available := make(map[string]struct{})
+	models := client.Models.ListAutoPaging(ctx)
+	for models.Next() {
+		available[models.Current().ID] = struct{}{}
+	}
+	if err := models.Err(); err != nil {
+		return classifyError(err)
+	}
+
+	// Azure OpenAI lists base models, while requests refer to deployments.
+	if keys.header == azureKeyHeader {
+		return nil
+	}
+
+	var missing []string
+	for _, model := range cfg.models() {
+		if _, exists := available[model]; !exists {
+			missing = append(missing, model)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &Error{Kind: ErrorModelNotFound, Err: fmt.Errorf("%s not in the model list", strings.Join(missing, ", "))}
+	}
+
+	return nil
+}
+
+// models returns names of chat models the config refers to, including fallback and persona ones.
+func (c *gptConfig) models() []string {
+	names := make(map[string]struct{})
+	add := func(model gptModelConfig) {
+		for _, name := range []string{model.Name, model.VisionModel} {
+			if name != "" {
+				names[name] = struct{}{}
+			}
+		}
+	}
+
+	add(c.Model)
+	for _, model := range c.FallbackModels {
+		add(model)
+	}
+	for _, persona := range c.Personas {
+		if persona.Model != nil {
+			add(*persona.Model)
+		}
+	}
+
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result
+}
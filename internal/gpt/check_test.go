@@ -0,0 +1,75 @@
+package gpt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+func TestCheckModels(t *testing.T) {
+	const models = `{"object": "list", "data": [{"id": "gpt-4o", "object": "model"}, {"id": "o3", "object": "model"}]}`
+
+	tests := []struct {
+		name       string
+		statusCode int
+		azure      bool
+		cfg        gptConfig
+		wantErr    bool
+		wantKind   ErrorKind
+	}{
+		{name: "available", cfg: gptConfig{Model: gptModelConfig{Name: "gpt-4o", VisionModel: "o3"}}},
+		{name: "missing model", cfg: gptConfig{Model: gptModelConfig{Name: "gpt-5"}}, wantErr: true, wantKind: ErrorModelNotFound},
+		{name: "missing vision model", cfg: gptConfig{Model: gptModelConfig{Name: "gpt-4o", VisionModel: "gpt-5"}}, wantErr: true, wantKind: ErrorModelNotFound},
+		{
+			name:     "missing fallback model",
+			cfg:      gptConfig{Model: gptModelConfig{Name: "gpt-4o"}, FallbackModels: []gptModelConfig{{Name: "gpt-5"}}},
+			wantErr:  true,
+			wantKind: ErrorModelNotFound,
+		},
+		{
+			name:     "missing persona model",
+			cfg:      gptConfig{Model: gptModelConfig{Name: "gpt-4o"}, Personas: map[string]*gptPersonaConfig{"formal": {Model: &gptModelConfig{Name: "gpt-5"}}}},
+			wantErr:  true,
+			wantKind: ErrorModelNotFound,
+		},
+		{name: "azure deployments", azure: true, cfg: gptConfig{Model: gptModelConfig{Name: "my-deployment"}}},
+		{name: "server error", statusCode: 503, cfg: gptConfig{Model: gptModelConfig{Name: "gpt-4o"}}, wantErr: true, wantKind: ErrorServer},
+		{name: "invalid key", statusCode: 401, cfg: gptConfig{Model: gptModelConfig{Name: "gpt-4o"}}, wantErr: true, wantKind: ErrorAuth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if tt.statusCode != 0 {
+					w.WriteHeader(tt.statusCode)
+					_, _ = w.Write([]byte(`{"error": {"message": "failed"}}`))
+					return
+				}
+				_, _ = w.Write([]byte(models))
+			}))
+			t.Cleanup(server.Close)
+
+			keys, err := newKeyPool("sk-aaaa1111")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.azure {
+				keys.header = azureKeyHeader
+			}
+
+			client := openai.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("sk-aaaa1111"), option.WithMaxRetries(0))
+			err = checkModels(context.Background(), &client, keys, &tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkModels() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && ErrorKindOf(err) != tt.wantKind {
+				t.Errorf("checkModels() error kind = %v, want %v", ErrorKindOf(err), tt.wantKind)
+			}
+		})
+	}
+}
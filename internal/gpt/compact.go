@@ -0,0 +1,111 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/openai/openai-go/v3/responses"
+	"github.com/openai/openai-go/v3/shared"
+	"github.com/rs/zerolog/log"
+)
+
+// compactionPrompt asks the model to summarize a conversation.
+const compactionPrompt = `Summarize the conversation so far for your own future reference.
+Keep all facts, names, numbers, decisions and open questions that may be needed to continue the conversation.
+Write the summary in the language of the conversation. Reply with the summary only.`
+
+// summaryMessage formats a summary of the earlier conversation for the model.
+func summaryMessage(summary string) string {
+	return fmt.Sprintf("Summary of the earlier conversation:\n\n%s", summary)
+}
+
+// compactIfNeeded replaces a long conversation with its summary.
+// A failed compaction isn't fatal: the conversation just goes on uncompacted.
+func (g *GPT) compactIfNeeded(ctx context.Context, cfg *gptConfig, req Request, response Response) Response {
+	if !cfg.Conversation.shouldCompact(response.Usage) {
+		return response
+	}
+
+	var itemsList []responses.ResponseInputItemUnionParam
+	if cfg.Conversation.useLocalHistory(apiResponses) {
+		if req.Summary != "" {
+			itemsList = append(itemsList, inputMessage(responses.EasyInputMessageRoleSystem, summaryMessage(req.Summary)))
+		}
+		for _, message := range response.History {
+			itemsList = append(itemsList, inputMessage(historyRole(message), message.Text))
+		}
+	}
+	itemsList = append(itemsList, inputMessage(responses.EasyInputMessageRoleUser, compactionPrompt))
+
+	request := responses.ResponseNewParams{
+		Model: shared.ResponsesModel(cfg.Model.Name),
+		Input: responses.ResponseNewParamsInputUnion{OfInputItemList: itemsList},
+		Store: param.Opt[bool]{Value: false},
+	}
+	if !cfg.Conversation.useLocalHistory(apiResponses) {
+		request.PreviousResponseID = param.Opt[string]{Value: response.ID}
+	}
+
+	summary, err := withRetry(ctx, func() (*responses.Response, error) {
+		return g.client.Responses.New(ctx, request)
+	})
+	if err != nil {
+		log.Error().Err(err).Int64("tokens", response.Usage.TotalTokens).Msg("failed to compact conversation")
+		return response
+	}
+
+	return compacted(response, summary.OutputText(), convertUsage(summary.Usage))
+}
+
+// compactIfNeeded replaces a long conversation with its summary.
+// A failed compaction isn't fatal: the conversation just goes on uncompacted.
+func (c *ChatCompletions) compactIfNeeded(ctx context.Context, cfg *gptConfig, req Request, response Response) Response {
+	if !cfg.Conversation.shouldCompact(response.Usage) {
+		return response
+	}
+
+	var messages []openai.ChatCompletionMessageParamUnion
+	if req.Summary != "" {
+		messages = append(messages, openai.SystemMessage(summaryMessage(req.Summary)))
+	}
+	messages = append(messages, chatMessages(response.History)...)
+	messages = append(messages, openai.UserMessage(compactionPrompt))
+
+	completion, err := withRetry(ctx, func() (*openai.ChatCompletion, error) {
+		return c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+			Model:    shared.ChatModel(cfg.Model.Name),
+			Messages: messages,
+		})
+	})
+	if err != nil {
+		log.Error().Err(err).Int64("tokens", response.Usage.TotalTokens).Msg("failed to compact conversation")
+		return response
+	}
+
+	if len(completion.Choices) == 0 {
+		log.Error().Int64("tokens", response.Usage.TotalTokens).Msg("failed to compact conversation: no choices")
+		return response
+	}
+
+	return compacted(response, completion.Choices[0].Message.Content, convertChatUsage(completion.Usage))
+}
+
+// compacted turns response into a response that starts a new conversation seeded with summary.
+func compacted(response Response, summary string, usage Usage) Response {
+	log.Info().
+		Int64("tokens", response.Usage.TotalTokens).
+		Int64("compaction_tokens", usage.TotalTokens).
+		Msg("conversation compacted")
+
+	response.Compaction = &Compaction{
+		TokensBefore: response.Usage.TotalTokens,
+		Usage:        usage,
+	}
+	response.ID = ""
+	response.History = nil
+	response.Summary = summary
+	response.Usage = response.Usage.Add(usage)
+	return response
+}
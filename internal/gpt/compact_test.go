@@ -0,0 +1,123 @@
+package gpt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// testCompactionServer returns a client of a server that replies to compaction requests with a summary,
+// or fails if fail is set. Request bodies are stored into requests.
+func testCompactionServer(t *testing.T, fail bool, requests *[]map[string]any) openai.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		*requests = append(*requests, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		if fail {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": {"message": "failed"}}`))
+			return
+		}
+
+		if r.URL.Path == "/chat/completions" {
+			_, _ = w.Write([]byte(`{"id": "chat_1", "model": "gpt-4o", "choices": [{"index": 0, "message": {"role": "assistant", "content": "summary"}}], ` +
+				`"usage": {"total_tokens": 7}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": "resp_2", "model": "gpt-4o", "output": [{"type": "message", "role": "assistant", ` +
+			`"content": [{"type": "output_text", "text": "summary"}]}], "usage": {"total_tokens": 7}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return openai.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("sk-test"), option.WithMaxRetries(0))
+}
+
+func TestCompactIfNeeded(t *testing.T) {
+	history := []Message{{Participant: ParticipantUser, Text: "hi"}, {Participant: ParticipantBot, Text: "hello"}}
+
+	tests := []struct {
+		name          string
+		api           api
+		mode          string
+		threshold     int64
+		fail          bool
+		wantRequests  int
+		wantCompacted bool
+		wantPrev      bool // Compaction request refers to the response instead of sending history.
+		wantMessages  int  // Messages sent with the compaction request.
+	}{
+		{name: "disabled", api: apiResponses, mode: conversationModeChain},
+		{name: "below threshold", api: apiResponses, mode: conversationModeChain, threshold: 100},
+		{name: "chain", api: apiResponses, mode: conversationModeChain, threshold: 50, wantRequests: 1, wantCompacted: true, wantPrev: true, wantMessages: 1},
+		{name: "local", api: apiResponses, mode: conversationModeLocal, threshold: 50, wantRequests: 1, wantCompacted: true, wantMessages: 4},
+		{name: "failure", api: apiResponses, mode: conversationModeChain, threshold: 50, fail: true, wantRequests: 1, wantPrev: true, wantMessages: 1},
+		{name: "chat completions", api: apiChatCompletions, threshold: 50, wantRequests: 1, wantCompacted: true, wantMessages: 4},
+		{name: "chat completions failure", api: apiChatCompletions, threshold: 50, fail: true, wantRequests: 1, wantMessages: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []map[string]any
+			client := testCompactionServer(t, tt.fail, &requests)
+
+			cfg := &gptConfig{
+				Model:        gptModelConfig{Name: "gpt-4o"},
+				Conversation: gptConversationConfig{Mode: tt.mode, CompactThreshold: tt.threshold},
+			}
+			req := Request{Message: "hi", Summary: "earlier"}
+			response := Response{ID: "resp_1", Text: "hello", History: history, Usage: Usage{TotalTokens: 60}}
+
+			var got Response
+			if tt.api == apiResponses {
+				got = (&GPT{client: client}).compactIfNeeded(context.Background(), cfg, req, response)
+			} else {
+				got = (&ChatCompletions{client: client}).compactIfNeeded(context.Background(), cfg, req, response)
+			}
+
+			if len(requests) != tt.wantRequests {
+				t.Fatalf("got %d requests, want %d", len(requests), tt.wantRequests)
+			}
+			if tt.wantRequests > 0 {
+				_, hasPrev := requests[0]["previous_response_id"]
+				if hasPrev != tt.wantPrev {
+					t.Errorf("request refers to the previous response = %v, want %v", hasPrev, tt.wantPrev)
+				}
+
+				// Summary of the earlier conversation, the history and the compaction prompt.
+				messages, _ := requests[0]["input"].([]any)
+				if tt.api == apiChatCompletions {
+					messages, _ = requests[0]["messages"].([]any)
+				}
+				if len(messages) != tt.wantMessages {
+					t.Errorf("request has %d messages, want %d", len(messages), tt.wantMessages)
+				}
+			}
+
+			if !tt.wantCompacted {
+				if got.Compaction != nil || got.ID != response.ID || len(got.History) != len(history) || got.Usage != response.Usage {
+					t.Errorf("compactIfNeeded() = %+v, want the response unchanged", got)
+				}
+				return
+			}
+
+			want := Compaction{TokensBefore: 60, Usage: Usage{TotalTokens: 7}}
+			if got.Compaction == nil || *got.Compaction != want {
+				t.Errorf("compaction = %+v, want %+v", got.Compaction, want)
+			}
+			// The next request starts a new conversation seeded with the summary.
+			if got.ID != "" || got.History != nil || got.Summary != "summary" {
+				t.Errorf("compactIfNeeded() = ID %q, history %+v, summary %q, want a new conversation with the summary", got.ID, got.History, got.Summary)
+			}
+			if got.Text != "hello" || got.Usage.TotalTokens != 67 {
+				t.Errorf("compactIfNeeded() = text %q, %d tokens, want the reply and 67 tokens", got.Text, got.Usage.TotalTokens)
+			}
+		})
+	}
+}
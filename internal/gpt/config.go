@@ -0,0 +1,679 @@
+package gpt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/openai/openai-go/v3/responses"
+	"github.com/openai/openai-go/v3/shared"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// api is an OpenAI API flavor used by a provider.
+type api int
+
+const (
+	apiResponses       api = iota // Responses API.
+	apiChatCompletions            // Chat Completions API.
+)
+
+func (a api) String() string {
+	if a == apiChatCompletions {
+		return "Chat Completions API"
+	}
+
+	return "Responses API"
+}
+
+type gptConfig struct {
+	Description    string                       `yaml:"description"` // Description of the default persona.
+	Model          gptModelConfig               `yaml:"model"`
+	FallbackModels []gptModelConfig             `yaml:"fallback_models"` // Models to try in order if the model fails.
+	FallbackOn     []string                     `yaml:"fallback_on"`     // Error classes that trigger fallback.
+	Conversation   gptConversationConfig        `yaml:"conversation"`
+	Transcription  gptTranscriptionConfig       `yaml:"transcription"`
+	LongInput      gptLongInputConfig           `yaml:"long_input"`
+	Cache          gptCacheConfig               `yaml:"cache"`
+	Tools          []string                     `yaml:"tools"`    // Names of tools the model may call.
+	Pricing        map[string]gptPriceConfig    `yaml:"pricing"`  // Model prices by model name.
+	Timezone       string                       `yaml:"timezone"` // Timezone of prompt variables, empty means UTC.
+	Prompt         string                       `yaml:"-"`        // Loaded from PROMPT.md.
+	Personas       map[string]*gptPersonaConfig `yaml:"-"`        // Alternative personas by name.
+
+	promptTemplate *template.Template // Parsed Prompt.
+	timezone       *time.Location     // Parsed Timezone.
+}
+
+// gptLongInputConfig defines how inputs larger than the model context are summarized.
+// Zero values mean "use the default".
+type gptLongInputConfig struct {
+	Budget         int64 `yaml:"budget"`          // Max input size (in tokens) sent as is.
+	SectionSize    int64 `yaml:"section_size"`    // Size of a section (in tokens) summarized separately.
+	SectionOverlap int64 `yaml:"section_overlap"` // Size of overlap (in tokens) between adjacent sections.
+}
+
+// Default long input parameters.
+const (
+	defaultInputBudget    = 100_000
+	defaultSectionSize    = 16_000
+	defaultSectionOverlap = 300
+)
+
+func (c *gptLongInputConfig) validate() error {
+	if c.Budget < 0 || c.SectionSize < 0 || c.SectionOverlap < 0 {
+		return errors.New("long_input values must be non-negative")
+	}
+
+	if c.sectionSize() > c.budget() {
+		return fmt.Errorf("long_input.section_size (%d) must not exceed long_input.budget (%d)", c.sectionSize(), c.budget())
+	}
+
+	if c.sectionOverlap() > c.sectionSize()/2 {
+		return fmt.Errorf("long_input.section_overlap (%d) must not exceed half of long_input.section_size (%d)", c.sectionOverlap(), c.sectionSize())
+	}
+
+	return nil
+}
+
+// budget returns max input size (in tokens) that is sent as is.
+func (c *gptLongInputConfig) budget() int64 {
+	if c.Budget == 0 {
+		return defaultInputBudget
+	}
+
+	return c.Budget
+}
+
+// sectionSize returns size of a section (in tokens).
+func (c *gptLongInputConfig) sectionSize() int64 {
+	if c.SectionSize == 0 {
+		return min(defaultSectionSize, c.budget())
+	}
+
+	return c.SectionSize
+}
+
+// sectionOverlap returns size of overlap (in tokens) between adjacent sections.
+func (c *gptLongInputConfig) sectionOverlap() int64 {
+	if c.SectionOverlap == 0 {
+		return min(defaultSectionOverlap, c.sectionSize()/2)
+	}
+
+	return c.SectionOverlap
+}
+
+// gptTranscriptionConfig contains speech-to-text parameters.
+type gptTranscriptionConfig struct {
+	Model    string `yaml:"model"`    // Transcription model.
+	Language string `yaml:"language"` // Speech language (ISO-639-1), empty means "detect automatically".
+}
+
+// model returns name of transcription model.
+func (c *gptTranscriptionConfig) model() string {
+	if c.Model == "" {
+		return string(openai.AudioModelWhisper1)
+	}
+
+	return c.Model
+}
+
+// gptConversationConfig defines how conversation context is passed to the model.
+type gptConversationConfig struct {
+	Mode             string `yaml:"mode"`              // Conversation mode.
+	MaxDepth         int    `yaml:"max_depth"`         // Max number of user/bot turns kept in local history.
+	CompactThreshold int64  `yaml:"compact_threshold"` // Conversation size (in tokens) that triggers compaction, 0 disables it.
+}
+
+// Supported conversation modes.
+const (
+	conversationModeChain = "chain" // Conversation is stored by OpenAI and referred by previous response ID.
+	conversationModeLocal = "local" // Conversation history is stored locally and sent with every request.
+)
+
+// gptModelConfig contains model parameters.
+// Zero (or null) values mean "use the model default".
+type gptModelConfig struct {
+	Name                string   `yaml:"name"`
+	VisionModel         string   `yaml:"vision_model"`
+	MaxCompletionTokens int64    `yaml:"max_completion_tokens"`
+	Temperature         *float64 `yaml:"temperature"`
+	TopP                *float64 `yaml:"top_p"`
+	N                   int64    `yaml:"n"`
+	PresencePenalty     float64  `yaml:"presence_penalty"`
+	Seed                *int64   `yaml:"seed"`
+	FrequencyPenalty    float64  `yaml:"frequency_penalty"`
+	ServiceTier         string   `yaml:"service_tier"`
+	Verbosity           string   `yaml:"verbosity"`
+	ResponseFormat      string   `yaml:"response_format"`
+
+	Reasoning gptReasoningConfig `yaml:"reasoning"` // Parameters of reasoning models.
+}
+
+// gptReasoningConfig contains parameters of reasoning models.
+// Empty values mean "use the model default".
+type gptReasoningConfig struct {
+	Effort  string `yaml:"effort"`  // Reasoning effort.
+	Summary string `yaml:"summary"` // Detail level of reasoning summary, empty means "no summary".
+}
+
+// Supported response formats.
+const (
+	responseFormatJSONSchema = "json_schema" // Structured output (default).
+	responseFormatText       = "text"        // Plain text, for servers that don't support JSON schemas.
+)
+
+func (c *gptConfig) validate(api api) error {
+	if c.Model.Name == "" {
+		return errors.New("model.name is required")
+	}
+
+	err := c.Model.validate(api)
+	if err != nil {
+		return err
+	}
+
+	for i, model := range c.FallbackModels {
+		if model.Name == "" {
+			return fmt.Errorf("fallback_models[%d].name is required", i)
+		}
+
+		err = model.validate(api)
+		if err != nil {
+			return errors.Wrapf(err, "fallback_models[%d]", i)
+		}
+	}
+
+	for _, name := range c.FallbackOn {
+		if _, exists := errorKindNames[name]; !exists {
+			return fmt.Errorf("fallback_on: unknown error class %q", name)
+		}
+	}
+
+	err = c.Conversation.validate(api)
+	if err != nil {
+		return err
+	}
+
+	err = c.LongInput.validate()
+	if err != nil {
+		return err
+	}
+
+	err = c.Cache.validate()
+	if err != nil {
+		return err
+	}
+
+	_, err = time.LoadLocation(c.Timezone)
+	if err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+
+	err = validateTools(c.Tools)
+	if err != nil {
+		return err
+	}
+
+	for model, price := range c.Pricing {
+		err = price.validate(model)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateTools checks that tools are registered.
+func validateTools(names []string) error {
+	for _, name := range names {
+		if _, exists := lookupTool(name); !exists {
+			return fmt.Errorf("tools: unknown tool %q, available tools are %s", name, strings.Join(toolNames(), ", "))
+		}
+	}
+
+	return nil
+}
+
+func (c *gptConversationConfig) validate(api api) error {
+	switch c.Mode {
+	case "", conversationModeLocal:
+	case conversationModeChain:
+		if api != apiResponses {
+			return fmt.Errorf("conversation.mode %q is not supported by the %s", c.Mode, api)
+		}
+	default:
+		return fmt.Errorf("conversation.mode must be one of %q or %q, got %q", conversationModeChain, conversationModeLocal, c.Mode)
+	}
+
+	if c.MaxDepth < 0 {
+		return fmt.Errorf("conversation.max_depth must be non-negative, got %d", c.MaxDepth)
+	}
+
+	if c.CompactThreshold < 0 {
+		return fmt.Errorf("conversation.compact_threshold must be non-negative, got %d", c.CompactThreshold)
+	}
+
+	return nil
+}
+
+// useLocalHistory returns true if conversation history is stored locally.
+func (c *gptConversationConfig) useLocalHistory(api api) bool {
+	return c.Mode == conversationModeLocal || (c.Mode == "" && api != apiResponses)
+}
+
+// shouldCompact returns true if a conversation that has used given number of tokens should be compacted.
+func (c *gptConversationConfig) shouldCompact(usage Usage) bool {
+	// Next request will have to carry both input and output of the last one.
+	return c.CompactThreshold > 0 && usage.TotalTokens > c.CompactThreshold
+}
+
+// depth returns max number of user/bot turns kept in local history.
+func (c *gptConversationConfig) depth() int {
+	if c.MaxDepth == 0 {
+		return MaxConversationDepth
+	}
+
+	return c.MaxDepth
+}
+
+// recent returns the last depth turns of the history.
+func (c *gptConversationConfig) recent(history []Message) []Message {
+	// Every turn is a pair of messages.
+	if maxLength := 2 * c.depth(); len(history) > maxLength {
+		return history[len(history)-maxLength:]
+	}
+
+	return history
+}
+
+// trimHistory appends a new turn to the history and keeps the last depth turns only.
+func (c *gptConversationConfig) trimHistory(req Request, reply string) []Message {
+	result := make([]Message, 0, len(req.History)+2)
+	result = append(result, req.History...)
+	result = append(result,
+		Message{Participant: ParticipantUser, Text: req.historyText()},
+		Message{Participant: ParticipantBot, Text: reply},
+	)
+
+	return c.recent(result)
+}
+
+func (c *gptModelConfig) validate(api api) error {
+	if c.MaxCompletionTokens < 0 {
+		return fmt.Errorf("model.max_completion_tokens must be non-negative, got %d", c.MaxCompletionTokens)
+	}
+
+	if c.Temperature != nil && (*c.Temperature < 0 || *c.Temperature > 2) {
+		return fmt.Errorf("model.temperature must be in range [0, 2], got %v", *c.Temperature)
+	}
+
+	if c.TopP != nil && (*c.TopP < 0 || *c.TopP > 1) {
+		return fmt.Errorf("model.top_p must be in range [0, 1], got %v", *c.TopP)
+	}
+
+	if c.PresencePenalty < -2 || c.PresencePenalty > 2 {
+		return fmt.Errorf("model.presence_penalty must be in range [-2, 2], got %v", c.PresencePenalty)
+	}
+
+	if c.FrequencyPenalty < -2 || c.FrequencyPenalty > 2 {
+		return fmt.Errorf("model.frequency_penalty must be in range [-2, 2], got %v", c.FrequencyPenalty)
+	}
+
+	switch responses.ResponseNewParamsServiceTier(c.ServiceTier) {
+	case "",
+		responses.ResponseNewParamsServiceTierAuto,
+		responses.ResponseNewParamsServiceTierDefault,
+		responses.ResponseNewParamsServiceTierFlex,
+		responses.ResponseNewParamsServiceTierScale,
+		responses.ResponseNewParamsServiceTierPriority:
+	default:
+		return fmt.Errorf("model.service_tier must be one of \"auto\", \"default\", \"flex\", \"scale\" or \"priority\", got %q", c.ServiceTier)
+	}
+
+	switch responses.ResponseTextConfigVerbosity(c.Verbosity) {
+	case "",
+		responses.ResponseTextConfigVerbosityLow,
+		responses.ResponseTextConfigVerbosityMedium,
+		responses.ResponseTextConfigVerbosityHigh:
+	default:
+		return fmt.Errorf("model.verbosity must be one of \"low\", \"medium\" or \"high\", got %q", c.Verbosity)
+	}
+
+	err := c.Reasoning.validate(api)
+	if err != nil {
+		return err
+	}
+
+	switch c.ResponseFormat {
+	case "", responseFormatJSONSchema, responseFormatText:
+	default:
+		return fmt.Errorf("model.response_format must be one of %q or %q, got %q", responseFormatJSONSchema, responseFormatText, c.ResponseFormat)
+	}
+
+	// Only a single output is ever used.
+	if c.N < 0 || c.N > 1 {
+		return fmt.Errorf("model.n must be 0 or 1, got %d", c.N)
+	}
+
+	if api == apiResponses {
+		// The Responses API has no seed or penalty parameters.
+		// Reject them explicitly instead of silently dropping them.
+		if c.Seed != nil {
+			return fmt.Errorf("model.seed is not supported by the %s", api)
+		}
+
+		if c.PresencePenalty != 0 {
+			return fmt.Errorf("model.presence_penalty is not supported by the %s", api)
+		}
+
+		if c.FrequencyPenalty != 0 {
+			return fmt.Errorf("model.frequency_penalty is not supported by the %s", api)
+		}
+	}
+
+	return nil
+}
+
+func (c *gptReasoningConfig) validate(api api) error {
+	switch shared.ReasoningEffort(c.Effort) {
+	case "",
+		shared.ReasoningEffortNone,
+		shared.ReasoningEffortMinimal,
+		shared.ReasoningEffortLow,
+		shared.ReasoningEffortMedium,
+		shared.ReasoningEffortHigh,
+		shared.ReasoningEffortXhigh:
+	default:
+		return fmt.Errorf("model.reasoning.effort must be one of \"none\", \"minimal\", \"low\", \"medium\", \"high\" or \"xhigh\", got %q", c.Effort)
+	}
+
+	switch shared.ReasoningSummary(c.Summary) {
+	case "",
+		shared.ReasoningSummaryAuto,
+		shared.ReasoningSummaryConcise,
+		shared.ReasoningSummaryDetailed:
+	default:
+		return fmt.Errorf("model.reasoning.summary must be one of \"auto\", \"concise\" or \"detailed\", got %q", c.Summary)
+	}
+
+	// The Chat Completions API doesn't return reasoning.
+	if c.Summary != "" && api != apiResponses {
+		return fmt.Errorf("model.reasoning.summary is not supported by the %s", api)
+	}
+
+	return nil
+}
+
+// modelFor returns name of the model that should handle the request.
+func (c *gptModelConfig) modelFor(req Request) string {
+	if len(req.Images) > 0 && c.VisionModel != "" {
+		return c.VisionModel
+	}
+
+	return c.Name
+}
+
+// useJSONSchema returns true if the model is asked for a structured output.
+func (c *gptModelConfig) useJSONSchema() bool {
+	return c.ResponseFormat != responseFormatText
+}
+
+// apply copies model parameters into the request.
+func (c *gptModelConfig) apply(req *responses.ResponseNewParams) {
+	if c.MaxCompletionTokens > 0 {
+		req.MaxOutputTokens = param.Opt[int64]{Value: c.MaxCompletionTokens}
+	}
+
+	if c.Temperature != nil {
+		req.Temperature = param.Opt[float64]{Value: *c.Temperature}
+	}
+
+	if c.TopP != nil {
+		req.TopP = param.Opt[float64]{Value: *c.TopP}
+	}
+
+	if c.ServiceTier != "" {
+		req.ServiceTier = responses.ResponseNewParamsServiceTier(c.ServiceTier)
+	}
+
+	if c.Verbosity != "" {
+		req.Text.Verbosity = responses.ResponseTextConfigVerbosity(c.Verbosity)
+	}
+
+	if c.Reasoning.Effort != "" {
+		req.Reasoning.Effort = shared.ReasoningEffort(c.Reasoning.Effort)
+	}
+
+	if c.Reasoning.Summary != "" {
+		req.Reasoning.Summary = shared.ReasoningSummary(c.Reasoning.Summary)
+	}
+}
+
+// applyChat copies model parameters into the chat completion request.
+func (c *gptModelConfig) applyChat(req *openai.ChatCompletionNewParams) {
+	if c.MaxCompletionTokens > 0 {
+		req.MaxCompletionTokens = param.Opt[int64]{Value: c.MaxCompletionTokens}
+	}
+
+	if c.Temperature != nil {
+		req.Temperature = param.Opt[float64]{Value: *c.Temperature}
+	}
+
+	if c.TopP != nil {
+		req.TopP = param.Opt[float64]{Value: *c.TopP}
+	}
+
+	if c.N > 0 {
+		req.N = param.Opt[int64]{Value: c.N}
+	}
+
+	if c.PresencePenalty != 0 {
+		req.PresencePenalty = param.Opt[float64]{Value: c.PresencePenalty}
+	}
+
+	if c.FrequencyPenalty != 0 {
+		req.FrequencyPenalty = param.Opt[float64]{Value: c.FrequencyPenalty}
+	}
+
+	if c.Seed != nil {
+		req.Seed = param.Opt[int64]{Value: *c.Seed}
+	}
+
+	if c.ServiceTier != "" {
+		req.ServiceTier = openai.ChatCompletionNewParamsServiceTier(c.ServiceTier)
+	}
+
+	if c.Verbosity != "" {
+		req.Verbosity = openai.ChatCompletionNewParamsVerbosity(c.Verbosity)
+	}
+
+	if c.Reasoning.Effort != "" {
+		req.ReasoningEffort = shared.ReasoningEffort(c.Reasoning.Effort)
+	}
+}
+
+// configStore holds the current config and reloads it when config files change.
+type configStore struct {
+	api    api
+	keys   *keyPool
+	path   string
+	stamp  string // Accessed by Watch goroutine only (after newConfigStore).
+	config atomic.Pointer[gptConfig]
+}
+
+// newConfigStore loads config from CONFIG_PATH (or ./conf/gpt.yaml).
+// Config is checked to work with the key pool, see keyPool.checkConversation.
+func newConfigStore(api api, keys *keyPool) (*configStore, error) {
+	const defaultSourcePath = "./conf/gpt.yaml"
+	sourcePath := os.Getenv("CONFIG_PATH")
+	if sourcePath == "" {
+		sourcePath = defaultSourcePath
+	}
+
+	stamp, err := configStamp(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &configStore{
+		api:   api,
+		keys:  keys,
+		path:  sourcePath,
+		stamp: stamp,
+	}
+
+	cfg, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	s.config.Store(cfg)
+	return s, nil
+}
+
+// load loads and checks config.
+func (s *configStore) load() (*gptConfig, error) {
+	cfg, err := loadGTPConfig(s.path, s.api)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.keys.checkConversation(s.api, &cfg.Conversation)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid gpt config %s", s.path)
+	}
+
+	return cfg, nil
+}
+
+// Load returns the current config.
+func (s *configStore) Load() *gptConfig {
+	return s.config.Load()
+}
+
+// Watch polls config files for changes until context is canceled.
+// A modified config is validated and swapped in atomically; an invalid one is rejected
+// and the last good config stays in use.
+func (s *configStore) Watch(ctx context.Context) {
+	const pollInterval = 5 * time.Second
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reload()
+		}
+	}
+}
+
+func (s *configStore) reload() {
+	stamp, err := configStamp(s.path)
+	if err != nil {
+		log.Error().Err(err).Str("path", s.path).Msg("unable to check gpt config")
+		return
+	}
+
+	if stamp == s.stamp {
+		return
+	}
+
+	// Remember the stamp even if the config is broken, so that the same error isn't reported every tick.
+	s.stamp = stamp
+
+	cfg, err := s.load()
+	if err != nil {
+		log.Error().Err(err).Str("path", s.path).Msg("gpt config rejected, keeping the last good one")
+		return
+	}
+
+	s.config.Store(cfg)
+	log.Info().Str("path", s.path).Str("model", cfg.Model.Name).Msg("gpt config reloaded")
+}
+
+// promptPath returns path to PROMPT.md that belongs to gpt.yaml at sourcePath.
+func promptPath(sourcePath string) string {
+	return filepath.Join(filepath.Dir(sourcePath), "PROMPT.md")
+}
+
+// configStamp returns a value that changes whenever config files are modified.
+func configStamp(sourcePath string) (string, error) {
+	personaPaths, err := filepath.Glob(filepath.Join(personasDir(sourcePath), "*"))
+	if err != nil {
+		return "", err
+	}
+
+	var stamp strings.Builder
+	for _, path := range append([]string{sourcePath, promptPath(sourcePath)}, personaPaths...) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+
+		_, _ = fmt.Fprintf(&stamp, "%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+	}
+
+	return stamp.String(), nil
+}
+
+func loadGTPConfig(sourcePath string, api api) (*gptConfig, error) {
+	raw, err := os.ReadFile(sourcePath)
+	if err != nil {
+		log.Error().Err(err).Str("path", sourcePath).Msg("unable to load gpt config")
+		return nil, err
+	}
+
+	var cfg gptConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Error().Err(err).Str("path", sourcePath).Msg("unable to parse gpt config")
+		return nil, err
+	}
+
+	err = cfg.validate(api)
+	if err != nil {
+		log.Error().Err(err).Str("path", sourcePath).Msg("invalid gpt config")
+		return nil, errors.Wrapf(err, "invalid gpt config %s", sourcePath)
+	}
+
+	promptPath := promptPath(sourcePath)
+	promptRaw, err := os.ReadFile(promptPath)
+	if err != nil {
+		log.Error().Err(err).Str("path", promptPath).Msg("unable to load prompt")
+		return nil, err
+	}
+
+	cfg.timezone, _ = time.LoadLocation(cfg.Timezone) // Checked by validate.
+	cfg.Prompt = string(promptRaw)
+	cfg.promptTemplate, err = parsePrompt(filepath.Base(promptPath), cfg.Prompt)
+	if err != nil {
+		log.Error().Err(err).Str("path", promptPath).Msg("invalid prompt")
+		return nil, err
+	}
+
+	cfg.Personas, err = loadPersonas(personasDir(sourcePath), api)
+	if err != nil {
+		log.Error().Err(err).Str("path", personasDir(sourcePath)).Msg("unable to load personas")
+		return nil, err
+	}
+
+	return &cfg, nil
+}
@@ -0,0 +1,357 @@
+package gpt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestConfig writes config files (gpt.yaml, PROMPT.md, personas/...) into dir and returns path to gpt.yaml.
+func writeTestConfig(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(content), 0o600)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return filepath.Join(dir, "gpt.yaml")
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestModelConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   gptModelConfig
+		api     api
+		wantErr string
+	}{
+		{name: "defaults", model: gptModelConfig{}},
+		{name: "bounds", model: gptModelConfig{Temperature: ptr(2.0), TopP: ptr(0.0), N: 1, MaxCompletionTokens: 100}},
+		{name: "negative max tokens", model: gptModelConfig{MaxCompletionTokens: -1}, wantErr: "model.max_completion_tokens"},
+		{name: "temperature below range", model: gptModelConfig{Temperature: ptr(-0.1)}, wantErr: "model.temperature"},
+		{name: "temperature above range", model: gptModelConfig{Temperature: ptr(2.1)}, wantErr: "model.temperature"},
+		{name: "top_p above range", model: gptModelConfig{TopP: ptr(1.5)}, wantErr: "model.top_p"},
+		{name: "several outputs", model: gptModelConfig{N: 2}, wantErr: "model.n"},
+		{name: "service tier", model: gptModelConfig{ServiceTier: "fast"}, wantErr: "model.service_tier"},
+		{name: "verbosity", model: gptModelConfig{Verbosity: "loud"}, wantErr: "model.verbosity"},
+		{name: "response format", model: gptModelConfig{ResponseFormat: "xml"}, wantErr: "model.response_format"},
+		{name: "reasoning", model: gptModelConfig{Reasoning: gptReasoningConfig{Effort: "high", Summary: "detailed"}}},
+		{name: "reasoning effort", model: gptModelConfig{Reasoning: gptReasoningConfig{Effort: "max"}}, wantErr: "model.reasoning.effort"},
+		{name: "reasoning summary", model: gptModelConfig{Reasoning: gptReasoningConfig{Summary: "short"}}, wantErr: "model.reasoning.summary"},
+		{name: "seed", model: gptModelConfig{Seed: ptr(int64(1))}, wantErr: "model.seed is not supported by the Responses API"},
+		{name: "presence penalty", model: gptModelConfig{PresencePenalty: 1}, wantErr: "model.presence_penalty is not supported"},
+		{name: "frequency penalty", model: gptModelConfig{FrequencyPenalty: -1}, wantErr: "model.frequency_penalty is not supported"},
+		{
+			name:  "chat completions parameters",
+			model: gptModelConfig{Seed: ptr(int64(1)), PresencePenalty: 2, FrequencyPenalty: -2},
+			api:   apiChatCompletions,
+		},
+		{name: "presence penalty above range", model: gptModelConfig{PresencePenalty: 2.5}, api: apiChatCompletions, wantErr: "model.presence_penalty must be in range"},
+		{name: "frequency penalty below range", model: gptModelConfig{FrequencyPenalty: -2.5}, api: apiChatCompletions, wantErr: "model.frequency_penalty must be in range"},
+		{
+			name:    "reasoning summary by chat completions",
+			model:   gptModelConfig{Reasoning: gptReasoningConfig{Summary: "auto"}},
+			api:     apiChatCompletions,
+			wantErr: "model.reasoning.summary is not supported",
+		},
+		{name: "reasoning effort by chat completions", model: gptModelConfig{Reasoning: gptReasoningConfig{Effort: "low"}}, api: apiChatCompletions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.model.validate(tt.api)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("validate() error = %v, want none", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadGPTConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     string
+		wantErr    string
+		wantPrompt string
+	}{
+		{name: "valid", config: "model:\n  name: gpt-4o\n  temperature: 0.5\n", wantPrompt: "Be brief."},
+		{name: "empty", config: "", wantErr: "model.name is required"},
+		{name: "unknown key", config: "model:\n  name: gpt-4o\nmodels: []\n", wantErr: "field models not found"},
+		{name: "unknown model key", config: "model:\n  name: gpt-4o\n  temprature: 0.5\n", wantErr: "field temprature not found"},
+		{name: "prompt key", config: "model:\n  name: gpt-4o\nprompt: Be verbose.\n", wantErr: "field prompt not found"},
+		{name: "personas key", config: "model:\n  name: gpt-4o\npersonas: {}\n", wantErr: "field personas not found"},
+		{name: "invalid value", config: "model:\n  name: gpt-4o\n  top_p: 2\n", wantErr: "model.top_p"},
+		{name: "unsupported by api", config: "model:\n  name: gpt-4o\n  seed: 42\n", wantErr: "model.seed"},
+		{name: "unknown fallback class", config: "model:\n  name: gpt-4o\nfallback_on: [timeout]\n", wantErr: "fallback_on: unknown error class"},
+		{name: "fallback model without name", config: "model:\n  name: gpt-4o\nfallback_models: [{}]\n", wantErr: "fallback_models[0].name"},
+		{name: "invalid fallback model", config: "model:\n  name: gpt-4o\nfallback_models: [{name: o3, top_p: 2}]\n", wantErr: "fallback_models[0]: model.top_p"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestConfig(t, t.TempDir(), map[string]string{
+				"gpt.yaml":  tt.config,
+				"PROMPT.md": "Be brief.",
+			})
+
+			cfg, err := loadGTPConfig(path, apiResponses)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadGTPConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadGTPConfig() error = %v", err)
+			}
+
+			if cfg.Prompt != tt.wantPrompt {
+				t.Errorf("prompt = %q, want %q", cfg.Prompt, tt.wantPrompt)
+			}
+			if cfg.Model.Temperature == nil || *cfg.Model.Temperature != 0.5 {
+				t.Errorf("temperature = %v, want 0.5", cfg.Model.Temperature)
+			}
+		})
+	}
+}
+
+func TestConfigStoreReload(t *testing.T) {
+	const (
+		goodConfig = "model:\n  name: gpt-4o\nconversation:\n  mode: local\n"
+		goodPrompt = "Be brief."
+	)
+
+	tests := []struct {
+		name      string
+		files     map[string]string // Files changed after the initial load.
+		remove    string            // File removed after the initial load.
+		wantModel string            // Model of the config in use after reload.
+		wantNew   bool              // Config is swapped.
+	}{
+		{name: "unchanged", wantModel: "gpt-4o"},
+		{name: "valid change", files: map[string]string{"gpt.yaml": "model:\n  name: o3\nconversation:\n  mode: local\n"}, wantModel: "o3", wantNew: true},
+		{name: "prompt change", files: map[string]string{"PROMPT.md": "Be verbose."}, wantModel: "gpt-4o", wantNew: true},
+		{
+			name:      "new persona",
+			files:     map[string]string{"personas/formal.yaml": "description: Formal\n", "personas/formal.md": "Be formal."},
+			wantModel: "gpt-4o",
+			wantNew:   true,
+		},
+		{name: "malformed yaml", files: map[string]string{"gpt.yaml": "model: [\n"}, wantModel: "gpt-4o"},
+		{name: "unknown key", files: map[string]string{"gpt.yaml": "model:\n  name: o3\n  temprature: 1\n"}, wantModel: "gpt-4o"},
+		{name: "invalid value", files: map[string]string{"gpt.yaml": "model:\n  name: o3\n  temperature: 5\n"}, wantModel: "gpt-4o"},
+		{name: "broken prompt", files: map[string]string{"PROMPT.md": "Hi {{.Unknown}}"}, wantModel: "gpt-4o"},
+		{name: "broken persona", files: map[string]string{"personas/formal.yaml": "description: Formal\n"}, wantModel: "gpt-4o"},
+		{name: "removed prompt", remove: "PROMPT.md", wantModel: "gpt-4o"},
+		{
+			name:      "chain mode with keys of several projects",
+			files:     map[string]string{"gpt.yaml": "model:\n  name: o3\nconversation:\n  mode: chain\n"},
+			wantModel: "gpt-4o",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeTestConfig(t, dir, map[string]string{"gpt.yaml": goodConfig, "PROMPT.md": goodPrompt})
+			t.Setenv("CONFIG_PATH", path)
+
+			keys, err := newKeyPool("sk-aaaa1111:org:proj_a,sk-bbbb2222:org:proj_b")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := newConfigStore(apiResponses, keys)
+			if err != nil {
+				t.Fatalf("newConfigStore() error = %v", err)
+			}
+			old := s.Load()
+
+			writeTestConfig(t, dir, tt.files)
+			if tt.remove != "" {
+				err = os.Remove(filepath.Join(dir, tt.remove))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Make changes visible to the stamp even on file systems with coarse modification times.
+			for name := range tt.files {
+				modTime := time.Now().Add(time.Minute)
+				err = os.Chtimes(filepath.Join(dir, name), modTime, modTime)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s.reload()
+
+			cfg := s.Load()
+			if cfg.Model.Name != tt.wantModel {
+				t.Errorf("model = %q, want %q", cfg.Model.Name, tt.wantModel)
+			}
+			if (cfg != old) != tt.wantNew {
+				t.Errorf("config is swapped = %v, want %v", cfg != old, tt.wantNew)
+			}
+			// The swapped config is a new one, the old one stays intact for requests in flight.
+			if old.Model.Name != "gpt-4o" || old.Prompt != goodPrompt {
+				t.Errorf("old config is modified: model %q, prompt %q", old.Model.Name, old.Prompt)
+			}
+		})
+	}
+}
+
+func TestConfigStoreRecoversAfterRejectedReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestConfig(t, dir, map[string]string{"gpt.yaml": "model:\n  name: gpt-4o\n", "PROMPT.md": "Be brief."})
+	t.Setenv("CONFIG_PATH", path)
+
+	keys, err := newKeyPool("sk-aaaa1111")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := newConfigStore(apiResponses, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		config    string
+		wantModel string
+	}{
+		{config: "model:\n  name: o3\n  top_p: 2\n", wantModel: "gpt-4o"},
+		{config: "model:\n  name: o3\n  top_p: 3\n", wantModel: "gpt-4o"},
+		{config: "model:\n  name: o3\n  top_p: 1\n", wantModel: "o3"},
+	}
+
+	for i, step := range steps {
+		writeTestConfig(t, dir, map[string]string{"gpt.yaml": step.config})
+		modTime := time.Now().Add(time.Duration(i+1) * time.Minute)
+		err = os.Chtimes(path, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.reload()
+
+		if got := s.Load().Model.Name; got != step.wantModel {
+			t.Errorf("step %d: model = %q, want %q", i+1, got, step.wantModel)
+		}
+	}
+}
+
+func TestConversationConfigValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		conversation gptConversationConfig
+		api          api
+		wantErr      bool
+		wantLocal    bool // History is kept locally.
+	}{
+		{name: "responses default", api: apiResponses},
+		{name: "responses chain", conversation: gptConversationConfig{Mode: conversationModeChain}, api: apiResponses},
+		{name: "responses local", conversation: gptConversationConfig{Mode: conversationModeLocal}, api: apiResponses, wantLocal: true},
+		{name: "chat completions default", api: apiChatCompletions, wantLocal: true},
+		{name: "chat completions local", conversation: gptConversationConfig{Mode: conversationModeLocal}, api: apiChatCompletions, wantLocal: true},
+		{name: "chat completions chain", conversation: gptConversationConfig{Mode: conversationModeChain}, api: apiChatCompletions, wantErr: true},
+		{name: "unknown mode", conversation: gptConversationConfig{Mode: "server"}, api: apiResponses, wantErr: true},
+		{name: "negative depth", conversation: gptConversationConfig{MaxDepth: -1}, api: apiResponses, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.conversation.validate(tt.api)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && tt.conversation.useLocalHistory(tt.api) != tt.wantLocal {
+				t.Errorf("useLocalHistory() = %v, want %v", !tt.wantLocal, tt.wantLocal)
+			}
+		})
+	}
+}
+
+func TestTrimHistory(t *testing.T) {
+	// history returns n turns of a conversation.
+	history := func(n int) []Message {
+		var messages []Message
+		for i := range n {
+			messages = append(messages,
+				Message{Participant: ParticipantUser, Text: fmt.Sprintf("question %d", i+1)},
+				Message{Participant: ParticipantBot, Text: fmt.Sprintf("answer %d", i+1)},
+			)
+		}
+		return messages
+	}
+
+	tests := []struct {
+		name      string
+		maxDepth  int
+		req       Request
+		wantLen   int
+		wantFirst string // Text of the first message kept.
+		wantLast  string // Text of the new user message.
+	}{
+		{name: "first turn", req: Request{Message: "hi"}, wantLen: 2, wantFirst: "hi", wantLast: "hi"},
+		{name: "within depth", req: Request{Message: "hi", History: history(3)}, wantLen: 8, wantFirst: "question 1", wantLast: "hi"},
+		{
+			name:      "default depth",
+			req:       Request{Message: "hi", History: history(MaxConversationDepth)},
+			wantLen:   2 * MaxConversationDepth,
+			wantFirst: "question 2",
+			wantLast:  "hi",
+		},
+		{name: "custom depth", maxDepth: 2, req: Request{Message: "hi", History: history(4)}, wantLen: 4, wantFirst: "question 4", wantLast: "hi"},
+		{name: "instruction", req: Request{Message: "text", Instruction: "translate"}, wantLen: 2, wantFirst: "translate\n\ntext", wantLast: "translate\n\ntext"},
+		{
+			name:      "images",
+			req:       Request{Message: "what is it", Images: []Image{{}, {}}},
+			wantLen:   2,
+			wantFirst: "what is it\n[2 image(s) attached]",
+			wantLast:  "what is it\n[2 image(s) attached]",
+		},
+		{
+			name:      "long message",
+			req:       Request{Message: strings.Repeat("я", maxHistoryMessageLength+1)},
+			wantLen:   2,
+			wantFirst: strings.Repeat("я", maxHistoryMessageLength) + "…",
+			wantLast:  strings.Repeat("я", maxHistoryMessageLength) + "…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversation := gptConversationConfig{MaxDepth: tt.maxDepth}
+			got := conversation.trimHistory(tt.req, "reply")
+
+			if len(got) != tt.wantLen {
+				t.Fatalf("trimHistory() has %d messages, want %d", len(got), tt.wantLen)
+			}
+			if got[0].Participant != ParticipantUser || got[0].Text != tt.wantFirst {
+				t.Errorf("first message = %+v, want user message %q", got[0], tt.wantFirst)
+			}
+			if user := got[len(got)-2]; user.Participant != ParticipantUser || user.Text != tt.wantLast {
+				t.Errorf("new user message = %+v, want %q", user, tt.wantLast)
+			}
+			if bot := got[len(got)-1]; bot.Participant != ParticipantBot || bot.Text != "reply" {
+				t.Errorf("last message = %+v, want the reply", bot)
+			}
+		})
+	}
+}
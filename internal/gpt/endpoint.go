@@ -0,0 +1,188 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go/v3/option"
+	"github.com/pkg/errors"
+
+	"github.com/kapitanov/gptbot/internal/httpclient"
+)
+
+// azureKeyHeader is a header Azure OpenAI expects API keys in.
+const azureKeyHeader = "api-key"
+
+// azureDeploymentPaths are API paths that Azure OpenAI (before v1 API) serves per deployment.
+var azureDeploymentPaths = []string{
+	"chat/completions",
+	"completions",
+	"embeddings",
+	"audio/transcriptions",
+	"audio/translations",
+	"audio/speech",
+	"images/generations",
+}
+
+// newClientOptions returns OpenAI client options configured by env variables:
+//   - OPENAI_BASE_URL overrides API endpoint;
+//   - AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_VERSION and AZURE_OPENAI_DEPLOYMENT switch to Azure OpenAI, see azureOptions;
+//   - OPENAI_HEADERS adds headers to every request, see parseHeaders;
+//   - OPENAI_PROXY and OPENAI_TIMEOUT configure HTTP client, see httpclient.New.
+func newClientOptions(keys *keyPool) ([]option.RequestOption, error) {
+	client, err := httpclient.FromEnv("OPENAI", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []option.RequestOption{
+		option.WithHTTPClient(client),
+		option.WithMaxRetries(0), // Requests are retried by withRetry.
+	}
+
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if endpoint := os.Getenv("AZURE_OPENAI_ENDPOINT"); endpoint != "" {
+		if baseURL != "" {
+			return nil, errors.New("OPENAI_BASE_URL and AZURE_OPENAI_ENDPOINT are mutually exclusive")
+		}
+
+		options = append(options, azureOptions(endpoint, os.Getenv("AZURE_OPENAI_API_VERSION"), os.Getenv("AZURE_OPENAI_DEPLOYMENT"))...)
+		keys.header = azureKeyHeader
+	} else if baseURL != "" {
+		options = append(options, option.WithBaseURL(baseURL))
+	}
+
+	headers, err := parseHeaders(os.Getenv("OPENAI_HEADERS"))
+	if err != nil {
+		return nil, errors.Wrap(err, "OPENAI_HEADERS")
+	}
+	for _, header := range headers {
+		options = append(options, option.WithHeader(header[0], header[1]))
+	}
+
+	// Key pool middleware goes last, so that requests are rewritten once, whatever key they are sent with.
+	return append(options, keys.options()...), nil
+}
+
+// azureOptions returns client options for an Azure OpenAI resource.
+// Without API version, the v1 API is used, where model names are deployment names.
+// With API version, requests to per-deployment paths are sent to the deployment,
+// which is either the configured one or the one named after the requested model.
+func azureOptions(endpoint, apiVersion, deployment string) []option.RequestOption {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if apiVersion == "" {
+		return []option.RequestOption{option.WithBaseURL(endpoint + "/openai/v1/")}
+	}
+
+	return []option.RequestOption{
+		option.WithBaseURL(endpoint + "/openai/"),
+		option.WithQuery("api-version", apiVersion),
+		option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			path, found := strings.CutPrefix(req.URL.Path, "/openai/")
+			if !found || !isAzureDeploymentPath(path) {
+				return next(req)
+			}
+
+			name := deployment
+			if name == "" {
+				var err error
+				name, err = requestModel(req)
+				if err != nil {
+					return nil, errors.Wrap(err, "unable to find deployment of the request")
+				}
+			}
+
+			req.URL.Path = "/openai/deployments/" + name + "/" + path
+			req.URL.RawPath = ""
+			return next(req)
+		}),
+	}
+}
+
+func isAzureDeploymentPath(path string) bool {
+	for _, deploymentPath := range azureDeploymentPaths {
+		if path == deploymentPath {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestModel returns model name from a JSON or multipart request body, leaving the body readable.
+func requestModel(req *http.Request) (string, error) {
+	if req.GetBody == nil {
+		return "", errors.New("request has no body")
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = body.Close() }()
+
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+
+	if mediaType == "multipart/form-data" {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				return "", errors.Wrap(err, "model not found")
+			}
+
+			if part.FormName() == "model" {
+				value, err := io.ReadAll(part)
+				return string(value), err
+			}
+		}
+	}
+
+	var request struct {
+		Model string `json:"model"`
+	}
+	err = json.NewDecoder(body).Decode(&request)
+	if err != nil {
+		return "", err
+	}
+
+	if request.Model == "" {
+		return "", errors.New("model not found")
+	}
+
+	return request.Model, nil
+}
+
+// parseHeaders parses a list of "Name: value" headers separated by semicolons or newlines.
+func parseHeaders(s string) ([][2]string, error) {
+	fieldFunc := func(r rune) bool {
+		return r == ';' || r == '\n'
+	}
+
+	var headers [][2]string
+	for i, field := range strings.FieldsFunc(s, fieldFunc) {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+
+		// Header values may be secrets, so they aren't quoted in errors.
+		name, value, found := strings.Cut(field, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("header #%d is malformed: must be \"Name: value\"", i+1)
+		}
+
+		headers = append(headers, [2]string{http.CanonicalHeaderKey(name), strings.TrimSpace(value)})
+	}
+
+	return headers, nil
+}
@@ -0,0 +1,257 @@
+package gpt
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/responses"
+)
+
+func TestNewClientOptions(t *testing.T) {
+	type request struct {
+		path    string
+		query   string
+		headers http.Header
+	}
+
+	tests := []struct {
+		name             string
+		env              map[string]string // Values are expanded with the server URL as $URL.
+		wantErr          bool
+		wantPaths        []string // Paths (and queries) of chat, responses and transcription requests.
+		wantAPIKeyHeader bool     // Key is sent in the api-key header instead of Authorization.
+	}{
+		{
+			name:      "default endpoint",
+			env:       map[string]string{"OPENAI_BASE_URL": "$URL/v1/"},
+			wantPaths: []string{"/v1/chat/completions", "/v1/responses", "/v1/audio/transcriptions"},
+		},
+		{
+			name:             "azure v1",
+			env:              map[string]string{"AZURE_OPENAI_ENDPOINT": "$URL/"},
+			wantPaths:        []string{"/openai/v1/chat/completions", "/openai/v1/responses", "/openai/v1/audio/transcriptions"},
+			wantAPIKeyHeader: true,
+		},
+		{
+			name: "azure deployment",
+			env:  map[string]string{"AZURE_OPENAI_ENDPOINT": "$URL", "AZURE_OPENAI_API_VERSION": "2025-04-01-preview", "AZURE_OPENAI_DEPLOYMENT": "bot"},
+			wantPaths: []string{
+				"/openai/deployments/bot/chat/completions?api-version=2025-04-01-preview",
+				"/openai/responses?api-version=2025-04-01-preview",
+				"/openai/deployments/bot/audio/transcriptions?api-version=2025-04-01-preview",
+			},
+			wantAPIKeyHeader: true,
+		},
+		{
+			name: "azure deployment per model",
+			env:  map[string]string{"AZURE_OPENAI_ENDPOINT": "$URL", "AZURE_OPENAI_API_VERSION": "2024-10-21"},
+			wantPaths: []string{
+				"/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21",
+				"/openai/responses?api-version=2024-10-21",
+				"/openai/deployments/whisper-1/audio/transcriptions?api-version=2024-10-21",
+			},
+			wantAPIKeyHeader: true,
+		},
+		{
+			name:    "ambiguous endpoint",
+			env:     map[string]string{"OPENAI_BASE_URL": "$URL/v1/", "AZURE_OPENAI_ENDPOINT": "$URL"},
+			wantErr: true,
+		},
+		{
+			name:    "malformed headers",
+			env:     map[string]string{"OPENAI_BASE_URL": "$URL/v1/", "OPENAI_HEADERS": "X-Team bots"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mutex    sync.Mutex
+				requests []request
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mutex.Lock()
+				requests = append(requests, request{path: r.URL.Path, query: r.URL.RawQuery, headers: r.Header.Clone()})
+				mutex.Unlock()
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"text": ""}`))
+			}))
+			t.Cleanup(server.Close)
+
+			for _, name := range []string{"OPENAI_BASE_URL", "AZURE_OPENAI_ENDPOINT", "AZURE_OPENAI_API_VERSION", "AZURE_OPENAI_DEPLOYMENT", "OPENAI_PROXY", "OPENAI_TIMEOUT"} {
+				t.Setenv(name, "")
+			}
+			t.Setenv("OPENAI_HEADERS", "X-Team: bots; x-trace-id:1")
+			for name, value := range tt.env {
+				t.Setenv(name, strings.ReplaceAll(value, "$URL", server.URL))
+			}
+
+			keys, err := newKeyPool("sk-aaaa1111")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			options, err := newClientOptions(keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newClientOptions() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			ctx := context.Background()
+			client := openai.NewClient(options...)
+			_, err = client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+				Model:    "gpt-4o",
+				Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hello")},
+			})
+			if err != nil {
+				t.Errorf("chat completion error = %v", err)
+			}
+			_, err = client.Responses.New(ctx, responses.ResponseNewParams{Model: "gpt-4o"})
+			if err != nil {
+				t.Errorf("response error = %v", err)
+			}
+			_, err = client.Audio.Transcriptions.New(ctx, openai.AudioTranscriptionNewParams{
+				File:  openai.File(bytes.NewReader([]byte("audio")), "voice.ogg", "audio/ogg"),
+				Model: openai.AudioModelWhisper1,
+			})
+			if err != nil {
+				t.Errorf("transcription error = %v", err)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if len(requests) != len(tt.wantPaths) {
+				t.Fatalf("got %d requests, want %d", len(requests), len(tt.wantPaths))
+			}
+			for i, req := range requests {
+				path := req.path
+				if req.query != "" {
+					path += "?" + req.query
+				}
+				if path != tt.wantPaths[i] {
+					t.Errorf("request %d is sent to %s, want %s", i+1, path, tt.wantPaths[i])
+				}
+
+				if got := req.headers.Get("X-Team") + "," + req.headers.Get("X-Trace-Id"); got != "bots,1" {
+					t.Errorf("request %d has custom headers %q, want %q", i+1, got, "bots,1")
+				}
+
+				auth, apiKey := req.headers.Get("Authorization"), req.headers.Get(azureKeyHeader)
+				if tt.wantAPIKeyHeader && (auth != "" || apiKey != "sk-aaaa1111") {
+					t.Errorf("request %d has Authorization %q and api-key %q, want the key in api-key only", i+1, auth, apiKey)
+				}
+				if !tt.wantAPIKeyHeader && (auth != "Bearer sk-aaaa1111" || apiKey != "") {
+					t.Errorf("request %d has Authorization %q and api-key %q, want the key in Authorization only", i+1, auth, apiKey)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestModel(t *testing.T) {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	_ = writer.WriteField("language", "en")
+	_ = writer.WriteField("model", "whisper-1")
+	_ = writer.Close()
+
+	var formWithoutModel bytes.Buffer
+	writerWithoutModel := multipart.NewWriter(&formWithoutModel)
+	_ = writerWithoutModel.WriteField("language", "en")
+	_ = writerWithoutModel.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		wantErr     bool
+	}{
+		{name: "json", contentType: "application/json", body: `{"messages": [], "model": "gpt-4o"}`, want: "gpt-4o"},
+		{name: "json with charset", contentType: "application/json; charset=utf-8", body: `{"model": "o3"}`, want: "o3"},
+		{name: "json without model", contentType: "application/json", body: `{"messages": []}`, wantErr: true},
+		{name: "malformed json", contentType: "application/json", body: `{"model":`, wantErr: true},
+		{name: "multipart", contentType: writer.FormDataContentType(), body: form.String(), want: "whisper-1"},
+		{name: "multipart without model", contentType: writerWithoutModel.FormDataContentType(), body: formWithoutModel.String(), wantErr: true},
+		{name: "no content type", body: `{"model": "gpt-4o"}`, wantErr: true},
+		{name: "no body", contentType: "application/json", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://example.com/openai/chat/completions", nil)
+			if tt.body != "" {
+				req, _ = http.NewRequest(http.MethodPost, "https://example.com/openai/chat/completions", strings.NewReader(tt.body))
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			got, err := requestModel(req)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("requestModel() = %q, %v, want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+
+			// The body is left for the request itself.
+			if tt.body != "" {
+				data, _ := io.ReadAll(req.Body)
+				if string(data) != tt.body {
+					t.Errorf("request body = %q, want %q", data, tt.body)
+				}
+			}
+		})
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    [][2]string
+		wantErr bool
+	}{
+		{name: "empty", s: ""},
+		{name: "blank", s: " ;\n ; "},
+		{name: "single", s: "X-Team: bots", want: [][2]string{{"X-Team", "bots"}}},
+		{
+			name: "semicolons and newlines",
+			s:    "x-team: bots;\nx-trace-id:1\r\n; Helicone-Auth: Bearer sk-1",
+			want: [][2]string{{"X-Team", "bots"}, {"X-Trace-Id", "1"}, {"Helicone-Auth", "Bearer sk-1"}},
+		},
+		{name: "colon in value", s: "X-Url: https://example.com", want: [][2]string{{"X-Url", "https://example.com"}}},
+		{name: "empty value", s: "X-Empty:", want: [][2]string{{"X-Empty", ""}}},
+		{name: "no colon", s: "X-Team bots", wantErr: true},
+		{name: "no name", s: "X-Team: bots; : value", wantErr: true},
+		{name: "space in name", s: "X Team: bots", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHeaders(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHeaders(%q) error = %v, want error %v", tt.s, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseHeaders(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseHeaders(%q) = %q, want %q", tt.s, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/pkg/errors"
+)
+
+// ErrorKind is a class of provider errors.
+type ErrorKind int
+
+const (
+	ErrorUnknown              ErrorKind = iota // Unclassified error.
+	ErrorRateLimit                             // Too many requests, retryable.
+	ErrorQuotaExceeded                         // Account is out of credits.
+	ErrorContextTooLong                        // Request doesn't fit into the model context.
+	ErrorServer                                // Server or network failure, retryable.
+	ErrorInvalidRequest                        // Request is rejected as invalid.
+	ErrorAuth                                  // API key is invalid or has no access.
+	ErrorModelNotFound                         // Model doesn't exist or is deprecated.
+	ErrorConversationNotFound                  // Previous response of the conversation has expired or is inaccessible.
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorRateLimit:
+		return "rate limit"
+	case ErrorQuotaExceeded:
+		return "quota exceeded"
+	case ErrorContextTooLong:
+		return "context too long"
+	case ErrorServer:
+		return "server error"
+	case ErrorInvalidRequest:
+		return "invalid request"
+	case ErrorAuth:
+		return "authentication error"
+	case ErrorModelNotFound:
+		return "model not found"
+	case ErrorConversationNotFound:
+		return "conversation not found"
+	default:
+		return "unknown error"
+	}
+}
+
+// Retryable returns true if a request that failed with this kind of error may succeed if retried.
+func (k ErrorKind) Retryable() bool {
+	return k == ErrorRateLimit || k == ErrorServer
+}
+
+// Error is a classified provider error.
+type Error struct {
+	Kind       ErrorKind     // Error class.
+	RetryAfter time.Duration // Delay requested by the server before retrying, if any.
+	Err        error         // Original error.
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKindOf returns class of a provider error.
+func ErrorKindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return ErrorUnknown
+}
+
+// classifyError wraps err into an *Error.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return &Error{
+			Kind:       classifyAPIError(apiErr.StatusCode, apiErr.Code, apiErr.Message),
+			RetryAfter: retryAfter(apiErr.Response),
+			Err:        err,
+		}
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Kind: ErrorUnknown, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return &Error{Kind: ErrorServer, Err: err}
+	}
+
+	return &Error{Kind: ErrorUnknown, Err: err}
+}
+
+// responseError returns a classified error reported inside a (streaming) response.
+func responseError(code, message string) error {
+	return &Error{
+		Kind: classifyAPIError(0, code, message),
+		Err:  fmt.Errorf("response failed: %s: %s", code, message),
+	}
+}
+
+func classifyAPIError(statusCode int, code, message string) ErrorKind {
+	switch {
+	case code == "insufficient_quota":
+		return ErrorQuotaExceeded
+	case code == "context_length_exceeded" || strings.Contains(message, "maximum context length"):
+		return ErrorContextTooLong
+	case code == "rate_limit_exceeded" || statusCode == http.StatusTooManyRequests:
+		return ErrorRateLimit
+	case code == "server_error" || code == "server_is_overloaded" || statusCode >= 500:
+		return ErrorServer
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusConflict:
+		return ErrorServer
+	case code == "model_not_found":
+		return ErrorModelNotFound
+	case code == "previous_response_not_found":
+		return ErrorConversationNotFound
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden || code == "invalid_api_key":
+		return ErrorAuth
+	case statusCode >= 400 || code == "invalid_prompt" || code == "invalid_request_error":
+		return ErrorInvalidRequest
+	default:
+		return ErrorUnknown
+	}
+}
+
+// retryAfter returns a delay requested by the server via "Retry-After" (or "Retry-After-Ms") header.
+func retryAfter(response *http.Response) time.Duration {
+	if response == nil {
+		return 0
+	}
+
+	if ms, err := strconv.ParseFloat(response.Header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := response.Header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
@@ -0,0 +1,150 @@
+package gpt
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/pkg/errors"
+)
+
+func TestClassifyAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		code       string
+		message    string
+		want       ErrorKind
+	}{
+		{name: "insufficient quota", statusCode: 429, code: "insufficient_quota", want: ErrorQuotaExceeded},
+		{name: "context length code", statusCode: 400, code: "context_length_exceeded", want: ErrorContextTooLong},
+		{
+			name:       "context length message",
+			statusCode: 400,
+			message:    "This model's maximum context length is 128000 tokens.",
+			want:       ErrorContextTooLong,
+		},
+		{name: "rate limit code", code: "rate_limit_exceeded", want: ErrorRateLimit},
+		{name: "too many requests", statusCode: 429, want: ErrorRateLimit},
+		{name: "server error code", code: "server_error", want: ErrorServer},
+		{name: "overloaded", code: "server_is_overloaded", want: ErrorServer},
+		{name: "internal server error", statusCode: 500, want: ErrorServer},
+		{name: "bad gateway", statusCode: 502, want: ErrorServer},
+		{name: "request timeout", statusCode: 408, want: ErrorServer},
+		{name: "conflict", statusCode: 409, want: ErrorServer},
+		{name: "model not found", statusCode: 404, code: "model_not_found", want: ErrorModelNotFound},
+		{name: "previous response not found", statusCode: 400, code: "previous_response_not_found", want: ErrorConversationNotFound},
+		{name: "unauthorized", statusCode: 401, want: ErrorAuth},
+		{name: "forbidden", statusCode: 403, want: ErrorAuth},
+		{name: "invalid api key", code: "invalid_api_key", want: ErrorAuth},
+		{name: "plain not found", statusCode: 404, want: ErrorInvalidRequest},
+		{name: "bad request", statusCode: 400, want: ErrorInvalidRequest},
+		{name: "invalid prompt", code: "invalid_prompt", want: ErrorInvalidRequest},
+		{name: "unknown", code: "something_new", want: ErrorUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyAPIError(tt.statusCode, tt.code, tt.message); got != tt.want {
+				t.Errorf("classifyAPIError(%d, %q, %q) = %v, want %v", tt.statusCode, tt.code, tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+// testAPIError returns an API error of a response with the given status and headers.
+func testAPIError(statusCode int, code string, header http.Header) *openai.Error {
+	return &openai.Error{
+		Code:       code,
+		StatusCode: statusCode,
+		Request:    httptest.NewRequest(http.MethodPost, "https://api.openai.com/v1/responses", nil),
+		Response:   &http.Response{StatusCode: statusCode, Header: header},
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	classified := &Error{Kind: ErrorAuth, Err: errors.New("denied")}
+
+	tests := []struct {
+		name           string
+		err            error
+		wantKind       ErrorKind
+		wantRetryAfter time.Duration
+	}{
+		{name: "api error", err: testAPIError(500, "", nil), wantKind: ErrorServer},
+		{
+			name:           "retry after",
+			err:            testAPIError(429, "", http.Header{"Retry-After": {"7"}}),
+			wantKind:       ErrorRateLimit,
+			wantRetryAfter: 7 * time.Second,
+		},
+		{name: "wrapped api error", err: errors.Wrap(testAPIError(401, "", nil), "request failed"), wantKind: ErrorAuth},
+		{name: "already classified", err: errors.Wrap(classified, "context"), wantKind: ErrorAuth},
+		{name: "canceled", err: context.Canceled, wantKind: ErrorUnknown},
+		{name: "deadline", err: errors.Wrap(context.DeadlineExceeded, "request"), wantKind: ErrorUnknown},
+		{name: "network", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, wantKind: ErrorServer},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, wantKind: ErrorServer},
+		{name: "other", err: errors.New("something"), wantKind: ErrorUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyError(tt.err)
+
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("classifyError() = %v, want *Error", err)
+			}
+			if e.Kind != tt.wantKind {
+				t.Errorf("classifyError() kind = %v, want %v", e.Kind, tt.wantKind)
+			}
+			if e.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("classifyError() retry after = %v, want %v", e.RetryAfter, tt.wantRetryAfter)
+			}
+			if !errors.Is(err, tt.err) && !errors.Is(tt.err, err) {
+				t.Errorf("classifyError() = %v, doesn't wrap %v", err, tt.err)
+			}
+		})
+	}
+
+	if err := classifyError(nil); err != nil {
+		t.Errorf("classifyError(nil) = %v, want nil", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "none", header: http.Header{}, want: 0},
+		{name: "seconds", header: http.Header{"Retry-After": {"2"}}, want: 2 * time.Second},
+		{name: "fractional seconds", header: http.Header{"Retry-After": {"0.5"}}, want: 500 * time.Millisecond},
+		{name: "milliseconds first", header: http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"2"}}, want: 250 * time.Millisecond},
+		{name: "past date", header: http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, want: 0},
+		{name: "garbage", header: http.Header{"Retry-After": {"soon"}, "Retry-After-Ms": {"-1"}}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(&http.Response{Header: tt.header}); got != tt.want {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := retryAfter(&http.Response{Header: http.Header{"Retry-After": {date}}}); got <= 58*time.Minute || got > time.Hour {
+		t.Errorf("retryAfter(%q) = %v, want about an hour", date, got)
+	}
+
+	if got := retryAfter(nil); got != 0 {
+		t.Errorf("retryAfter(nil) = %v, want 0", got)
+	}
+}
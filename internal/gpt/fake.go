@@ -0,0 +1,74 @@
+package gpt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Fake is a deterministic in-process provider that doesn't require network access.
+// It echoes request messages back, so it's useful for running the bot offline.
+// Fake is stateless, conversation history is kept locally.
+type Fake struct{}
+
+var _ Provider = (*Fake)(nil)
+
+// NewFake creates a new fake provider.
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+// Generate generates a fake reply to the request.
+func (f *Fake) Generate(ctx context.Context, req Request) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	depth := len(req.History)/2 + 1
+
+	hash := sha256.Sum256([]byte(req.PrevResponseID + "\n" + req.Instruction + "\n" + req.Message))
+	id := "fake_" + hex.EncodeToString(hash[:8])
+
+	text := fmt.Sprintf("%s\n\n_fake reply #%d_", req.historyText(), depth)
+
+	inputTokens := int64(len(strings.Fields(req.Message)))
+	outputTokens := int64(len(strings.Fields(text)))
+
+	conversation := gptConversationConfig{Mode: conversationModeLocal}
+
+	return Response{
+		ID:      id,
+		Model:   "fake",
+		Text:    text,
+		History: conversation.trimHistory(req, text),
+
+		TLDR:       fmt.Sprintf("Fake reply #%d.", depth),
+		KeyPoints:  []string{"The message is echoed back."},
+		Tags:       []string{"fake"},
+		Confidence: 1,
+
+		Summary: req.Summary,
+		Usage: Usage{
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+			TotalTokens:  inputTokens + outputTokens,
+		},
+	}, nil
+}
+
+// GenerateStream generates a fake reply to the request, reporting it word by word.
+func (f *Fake) GenerateStream(ctx context.Context, req Request, onUpdate StreamFunc) (Response, error) {
+	response, err := f.Generate(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	words := strings.SplitAfter(response.Text, " ")
+	for i := range words {
+		onUpdate(strings.Join(words[:i+1], ""))
+	}
+
+	return response, nil
+}
@@ -0,0 +1,58 @@
+package gpt
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+// Error classes as they are named in gpt.yaml.
+var errorKindNames = map[string]ErrorKind{
+	"rate_limit":       ErrorRateLimit,
+	"quota_exceeded":   ErrorQuotaExceeded,
+	"context_too_long": ErrorContextTooLong,
+	"server_error":     ErrorServer,
+	"invalid_request":  ErrorInvalidRequest,
+	"auth":             ErrorAuth,
+	"model_not_found":  ErrorModelNotFound,
+}
+
+// defaultFallbackOn lists error classes that trigger fallback by default.
+var defaultFallbackOn = []string{"rate_limit", "server_error", "model_not_found"}
+
+// withFallback calls generate with the primary model and, if it fails with one of fallback error classes,
+// with the fallback models in order.
+func withFallback(cfg *gptConfig, generate func(cfg *gptConfig) (Response, error)) (Response, error) {
+	response, err := generate(cfg)
+
+	for _, model := range cfg.FallbackModels {
+		if err == nil || !cfg.shouldFallback(err) {
+			break
+		}
+
+		log.Warn().Err(err).Str("model", cfg.Model.Name).Str("fallback", model.Name).Msg("model failed, falling back")
+
+		fallback := *cfg
+		fallback.Model = model
+		cfg = &fallback
+
+		response, err = generate(cfg)
+	}
+
+	return response, err
+}
+
+// shouldFallback returns true if a request that failed with err should be retried with a fallback model.
+func (c *gptConfig) shouldFallback(err error) bool {
+	names := c.FallbackOn
+	if len(names) == 0 {
+		names = defaultFallbackOn
+	}
+
+	kind := ErrorKindOf(err)
+	for _, name := range names {
+		if errorKindNames[name] == kind {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+package gpt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestWithFallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		fallbackOn []string
+		errs       map[string]error // Errors by model name, models that aren't listed succeed.
+		wantModels []string         // Models tried in order.
+		wantModel  string           // Model of the response, empty if the request fails.
+		wantErr    ErrorKind        // Kind of the returned error.
+	}{
+		{name: "primary succeeds", wantModels: []string{"primary"}, wantModel: "primary"},
+		{
+			name:       "rate limit",
+			errs:       map[string]error{"primary": &Error{Kind: ErrorRateLimit}},
+			wantModels: []string{"primary", "first"},
+			wantModel:  "first",
+		},
+		{
+			name:       "all models fail",
+			errs:       map[string]error{"primary": &Error{Kind: ErrorServer}, "first": &Error{Kind: ErrorModelNotFound}, "second": &Error{Kind: ErrorRateLimit}},
+			wantModels: []string{"primary", "first", "second"},
+			wantErr:    ErrorRateLimit,
+		},
+		{
+			name:       "not a fallback class by default",
+			errs:       map[string]error{"primary": &Error{Kind: ErrorContextTooLong}},
+			wantModels: []string{"primary"},
+			wantErr:    ErrorContextTooLong,
+		},
+		{
+			name:       "unclassified error",
+			errs:       map[string]error{"primary": errors.New("failed")},
+			wantModels: []string{"primary"},
+			wantErr:    ErrorUnknown,
+		},
+		{
+			name:       "wrapped error",
+			errs:       map[string]error{"primary": errors.Wrap(&Error{Kind: ErrorServer}, "request failed")},
+			wantModels: []string{"primary", "first"},
+			wantModel:  "first",
+		},
+		{
+			name:       "configured class",
+			fallbackOn: []string{"context_too_long"},
+			errs:       map[string]error{"primary": &Error{Kind: ErrorContextTooLong}},
+			wantModels: []string{"primary", "first"},
+			wantModel:  "first",
+		},
+		{
+			name:       "default class not configured",
+			fallbackOn: []string{"context_too_long"},
+			errs:       map[string]error{"primary": &Error{Kind: ErrorRateLimit}},
+			wantModels: []string{"primary"},
+			wantErr:    ErrorRateLimit,
+		},
+		{
+			name:       "fallback fails with another class",
+			errs:       map[string]error{"primary": &Error{Kind: ErrorServer}, "first": &Error{Kind: ErrorAuth}},
+			wantModels: []string{"primary", "first"},
+			wantErr:    ErrorAuth,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &gptConfig{
+				Model:          gptModelConfig{Name: "primary"},
+				FallbackModels: []gptModelConfig{{Name: "first"}, {Name: "second"}},
+				FallbackOn:     tt.fallbackOn,
+			}
+
+			var models []string
+			response, err := withFallback(cfg, func(cfg *gptConfig) (Response, error) {
+				models = append(models, cfg.Model.Name)
+				return Response{Model: cfg.Model.Name}, tt.errs[cfg.Model.Name]
+			})
+
+			if strings.Join(models, ",") != strings.Join(tt.wantModels, ",") {
+				t.Errorf("models tried = %v, want %v", models, tt.wantModels)
+			}
+			if tt.wantModel == "" {
+				if err == nil || ErrorKindOf(err) != tt.wantErr {
+					t.Errorf("withFallback() error = %v, want kind %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("withFallback() error = %v, want none", err)
+			}
+			if response.Model != tt.wantModel {
+				t.Errorf("response model = %q, want %q", response.Model, tt.wantModel)
+			}
+		})
+	}
+
+	// The primary config is left intact for other requests.
+	cfg := &gptConfig{Model: gptModelConfig{Name: "primary"}, FallbackModels: []gptModelConfig{{Name: "first"}}}
+	_, _ = withFallback(cfg, func(cfg *gptConfig) (Response, error) {
+		return Response{}, &Error{Kind: ErrorServer}
+	})
+	if cfg.Model.Name != "primary" {
+		t.Errorf("config model = %q after fallback, want primary", cfg.Model.Name)
+	}
+}
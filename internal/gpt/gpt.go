@@ -2,195 +2,258 @@ package gpt
 
 import (
 	"context"
-	"encoding/json"
-	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/openai/openai-go/v3"
-	"github.com/openai/openai-go/v3/option"
 	"github.com/openai/openai-go/v3/packages/param"
 	"github.com/openai/openai-go/v3/responses"
 	"github.com/openai/openai-go/v3/shared"
 	"github.com/rs/zerolog/log"
-	"gopkg.in/yaml.v3"
 )
 
 // GPT is a GPT-5 text transformer.
 type GPT struct {
 	client openai.Client
+	config *configStore
+	keys   *keyPool
+	cache  *responseCache
 }
 
-// MaxConversationDepth limits conversation depth.
+var (
+	_ Provider          = (*GPT)(nil)
+	_ KeyStatusReporter = (*GPT)(nil)
+)
+
+// MaxConversationDepth is a default limit of local conversation history depth.
 const MaxConversationDepth = 5
 
 // New creates a new GPT-3 text transformer.
+// Token is a list of API keys, see newKeyPool.
 func New(token string) (*GPT, error) {
-	client := openai.NewClient(option.WithAPIKey(token))
-
-	_, err := client.Models.List(context.Background())
+	keys, err := newKeyPool(token)
 	if err != nil {
 		return nil, err
 	}
 
-	return &GPT{client: client}, nil
-}
+	config, err := newConfigStore(apiResponses, keys)
+	if err != nil {
+		return nil, err
+	}
 
-// Message is a message in a conversation.
-type Message struct {
-	Participant Participant // Conversation participant.
-	Text        string      // Message text.
-}
+	options, err := newClientOptions(keys)
+	if err != nil {
+		return nil, err
+	}
 
-// Participant is the side of conversation.
-type Participant int
+	client := openai.NewClient(options...)
 
-const (
-	ParticipantBot  Participant = iota // Bot.
-	ParticipantUser                    // User.
-)
+	// API isn't called here, so that the bot starts even if the API is unreachable, see Check.
+	return &GPT{client: client, config: config, keys: keys, cache: newResponseCache(apiResponses)}, nil
+}
 
-// Request is a GPT request.
-type Request struct {
-	Message        string
-	PrevResponseID string
+// Watch reloads config on change until context is canceled.
+func (g *GPT) Watch(ctx context.Context) {
+	g.config.Watch(ctx)
 }
 
-// Response is a GPT response.
-type Response struct {
-	ID    string
-	Text  string                  // Transformed text.
-	Usage responses.ResponseUsage // Token usage.
+// KeyStatus returns status of every API key in use.
+func (g *GPT) KeyStatus() []KeyStatus {
+	return g.keys.KeyStatus()
 }
 
 // Generate generates a new message from the input stream.
 func (g *GPT) Generate(ctx context.Context, req Request) (Response, error) {
-	request, err := g.prepareGTPRequest(req)
+	cfg := g.config.Load().withPersona(req.Persona)
+	return g.cache.do(cfg, req, func() (Response, error) {
+		return withFallback(cfg, func(cfg *gptConfig) (Response, error) {
+			return g.generate(ctx, cfg, req, func(request responses.ResponseNewParams) (*responses.Response, error) {
+				return g.client.Responses.New(ctx, request)
+			})
+		})
+	})
+}
+
+// generate generates a reply with the model cfg refers to, sending requests with send.
+func (g *GPT) generate(
+	ctx context.Context,
+	cfg *gptConfig,
+	req Request,
+	send func(request responses.ResponseNewParams) (*responses.Response, error),
+) (Response, error) {
+	req, inputUsage, err := reduceLongInput(ctx, cfg, req, g.summarize)
 	if err != nil {
 		return Response{}, err
 	}
 
-	response, err := g.client.Responses.New(ctx, request)
+	complete := func(request responses.ResponseNewParams) (*responses.Response, jsonOutput, Usage, error) {
+		return completeStructured(cfg,
+			func() (*responses.Response, Usage, error) {
+				return g.completeWithTools(ctx, request, send)
+			},
+			func(response *responses.Response) (string, Usage) {
+				return response.OutputText(), convertUsage(response.Usage)
+			},
+		)
+	}
+
+	response, output, toolUsage, err := complete(g.prepareGTPRequest(cfg, req))
+	if ErrorKindOf(err) == ErrorConversationNotFound && req.PrevResponseID != "" {
+		// Stored responses expire, so the conversation starts over; the new response ID replaces the stored one.
+		log.Warn().Err(err).Str("prev", req.PrevResponseID).Msg("previous response not found, starting the conversation over")
+		req.PrevResponseID = ""
+		response, output, toolUsage, err = complete(g.prepareGTPRequest(cfg, req))
+	}
 	if err != nil {
 		return Response{}, err
 	}
 
-	log.Debug().Str("model", response.Model).Int64("tokens", response.Usage.TotalTokens).Msg("gpt stats")
+	result := withUsage(g.compactIfNeeded(ctx, cfg, req, g.parseResponse(cfg, req, response, output)), inputUsage.Add(toolUsage))
+	return withCost(cfg, result), nil
+}
 
-	transformedText := response.OutputText()
+func (g *GPT) parseResponse(cfg *gptConfig, req Request, response *responses.Response, output jsonOutput) Response {
+	log.Debug().
+		Str("model", response.Model).
+		Int64("tokens", response.Usage.TotalTokens).
+		Int64("reasoning_tokens", response.Usage.OutputTokensDetails.ReasoningTokens).
+		Msg("gpt stats")
+
+	result := Response{
+		ID:        response.ID,
+		Model:     response.Model,
+		Usage:     convertUsage(response.Usage),
+		Summary:   req.Summary,
+		Reasoning: reasoningSummary(response),
+	}
+	output.apply(&result)
 
-	type jsonOutput struct {
-		OutputMarkdown string `json:"output_markdown"`
+	if cfg.Conversation.useLocalHistory(apiResponses) {
+		result.History = cfg.Conversation.trimHistory(req, result.Text)
 	}
 
-	var transformedOutput jsonOutput
-	if err = json.Unmarshal([]byte(transformedText), &transformedOutput); err == nil {
-		transformedText = transformedOutput.OutputMarkdown
+	return result
+}
+
+// reasoningSummary returns the summary of the model's reasoning, if it's been requested.
+func reasoningSummary(response *responses.Response) string {
+	var parts []string
+	for _, item := range response.Output {
+		if item.Type != "reasoning" {
+			continue
+		}
+
+		for _, summary := range item.AsReasoning().Summary {
+			parts = append(parts, summary.Text)
+		}
 	}
 
-	return Response{
-		ID:    response.ID,
-		Text:  transformedText,
-		Usage: response.Usage,
-	}, nil
+	return strings.Join(parts, "\n\n")
 }
 
-func (g *GPT) prepareGTPRequest(request Request) (responses.ResponseNewParams, error) {
-	cfg, err := loadGTPConfig()
-	if err != nil {
-		return responses.ResponseNewParams{}, err
+func convertUsage(usage responses.ResponseUsage) Usage {
+	return Usage{
+		InputTokens:       usage.InputTokens,
+		CachedInputTokens: usage.InputTokensDetails.CachedTokens,
+		OutputTokens:      usage.OutputTokens,
+		ReasoningTokens:   usage.OutputTokensDetails.ReasoningTokens,
+		TotalTokens:       usage.TotalTokens,
 	}
+}
+
+func (g *GPT) prepareGTPRequest(cfg *gptConfig, request Request) responses.ResponseNewParams {
+	useLocalHistory := cfg.Conversation.useLocalHistory(apiResponses)
 
 	var itemsList []responses.ResponseInputItemUnionParam
 
-	if request.PrevResponseID == "" {
-		itemsList = append(itemsList, responses.ResponseInputItemUnionParam{
-			OfMessage: &responses.EasyInputMessageParam{
-				Role: responses.EasyInputMessageRoleSystem,
-				Content: responses.EasyInputMessageContentUnionParam{
-					OfString: param.Opt[string]{Value: cfg.Prompt},
-				},
-			},
-		})
+	if request.PrevResponseID == "" || useLocalHistory {
+		itemsList = append(itemsList, inputMessage(responses.EasyInputMessageRoleSystem, cfg.prompt(request)))
+
+		if request.Summary != "" {
+			itemsList = append(itemsList, inputMessage(responses.EasyInputMessageRoleSystem, summaryMessage(request.Summary)))
+		}
 	}
 
-	itemsList = append(itemsList, responses.ResponseInputItemUnionParam{
-		OfMessage: &responses.EasyInputMessageParam{
-			Role: responses.EasyInputMessageRoleUser,
-			Content: responses.EasyInputMessageContentUnionParam{
-				OfString: param.Opt[string]{Value: request.Message},
-			},
-		},
-	})
+	if useLocalHistory {
+		for _, message := range cfg.Conversation.recent(request.History) {
+			itemsList = append(itemsList, inputMessage(historyRole(message), message.Text))
+		}
+	}
+
+	itemsList = append(itemsList, userInputMessage(request))
 
 	req := responses.ResponseNewParams{
-		Model: shared.ResponsesModel(cfg.Model.Name),
-		Text: responses.ResponseTextConfigParam{
-			Format: responses.ResponseFormatTextConfigUnionParam{
-				OfJSONSchema: &responses.ResponseFormatTextJSONSchemaConfigParam{
-					Name: "output",
-					Schema: map[string]any{
-						"type": "object",
-						"properties": map[string]any{
-							"output_markdown": map[string]any{
-								"type":        "string",
-								"description": "The response text in Markdown format.",
-							},
-						},
-						"required":             []string{"output_markdown"},
-						"additionalProperties": false,
-					},
-					Strict: param.Opt[bool]{Value: true},
-				},
-			},
-		},
+		Model: shared.ResponsesModel(cfg.Model.modelFor(request)),
 		Input: responses.ResponseNewParamsInputUnion{
 			OfInputItemList: itemsList,
 		},
 	}
 
-	if request.PrevResponseID != "" {
+	if cfg.Model.useJSONSchema() {
+		req.Text.Format = responses.ResponseFormatTextConfigUnionParam{
+			OfJSONSchema: &responses.ResponseFormatTextJSONSchemaConfigParam{
+				Name:   outputSchemaName,
+				Schema: outputSchema(),
+				Strict: param.Opt[bool]{Value: true},
+			},
+		}
+	}
+
+	if request.PrevResponseID != "" && !useLocalHistory {
 		req.PreviousResponseID = param.Opt[string]{Value: request.PrevResponseID}
 	}
 
-	return req, nil
-}
+	req.Tools = cfg.responsesTools()
+	cfg.Model.apply(&req)
 
-type gptConfig struct {
-	Model  gptModelConfig `yaml:"model"`
-	Prompt string         `yaml:"prompt"`
+	return req
 }
 
-type gptModelConfig struct {
-	Name string `yaml:"name"`
+func inputMessage(role responses.EasyInputMessageRole, text string) responses.ResponseInputItemUnionParam {
+	return responses.ResponseInputItemUnionParam{
+		OfMessage: &responses.EasyInputMessageParam{
+			Role: role,
+			Content: responses.EasyInputMessageContentUnionParam{
+				OfString: param.Opt[string]{Value: text},
+			},
+		},
+	}
 }
 
-func loadGTPConfig() (*gptConfig, error) {
-	const defaultSourcePath = "./conf/gpt.yaml"
-	sourcePath := os.Getenv("CONFIG_PATH")
-	if sourcePath == "" {
-		sourcePath = defaultSourcePath
+// historyRole returns input message role for a message from local history.
+func historyRole(message Message) responses.EasyInputMessageRole {
+	if message.Participant == ParticipantUser {
+		return responses.EasyInputMessageRoleUser
 	}
 
-	raw, err := os.ReadFile(sourcePath)
-	if err != nil {
-		log.Error().Err(err).Str("path", sourcePath).Msg("unable to load gpt config")
-		return nil, err
+	return responses.EasyInputMessageRoleAssistant
+}
+
+// userInputMessage returns input message with request text and images.
+func userInputMessage(request Request) responses.ResponseInputItemUnionParam {
+	if len(request.Images) == 0 && request.Instruction == "" {
+		return inputMessage(responses.EasyInputMessageRoleUser, request.Message)
 	}
 
-	var cfg gptConfig
-	err = yaml.Unmarshal(raw, &cfg)
-	if err != nil {
-		log.Error().Err(err).Str("path", sourcePath).Msg("unable to parse gpt config")
-		return nil, err
+	var content responses.ResponseInputMessageContentListParam
+	for _, text := range request.texts() {
+		content = append(content, responses.ResponseInputContentUnionParam{
+			OfInputText: &responses.ResponseInputTextParam{Text: text},
+		})
 	}
 
-	promptPath := filepath.Join(filepath.Dir(sourcePath), "PROMPT.md")
-	promptRaw, err := os.ReadFile(promptPath)
-	if err != nil {
-		log.Error().Err(err).Str("path", promptPath).Msg("unable to load prompt")
-		return nil, err
+	for _, image := range request.Images {
+		content = append(content, responses.ResponseInputContentUnionParam{
+			OfInputImage: &responses.ResponseInputImageParam{
+				ImageURL: param.Opt[string]{Value: image.DataURL()},
+				Detail:   responses.ResponseInputImageDetailAuto,
+			},
+		})
 	}
 
-	cfg.Prompt = string(promptRaw)
-	return &cfg, nil
+	return responses.ResponseInputItemUnionParam{
+		OfMessage: &responses.EasyInputMessageParam{
+			Role:    responses.EasyInputMessageRoleUser,
+			Content: responses.EasyInputMessageContentUnionParam{OfInputItemContentList: content},
+		},
+	}
 }
@@ -0,0 +1,203 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/responses"
+)
+
+func TestPrepareGTPRequestHistory(t *testing.T) {
+	history := []Message{
+		{Participant: ParticipantUser, Text: "q1"}, {Participant: ParticipantBot, Text: "a1"},
+		{Participant: ParticipantUser, Text: "q2"}, {Participant: ParticipantBot, Text: "a2"},
+	}
+
+	tests := []struct {
+		name      string
+		mode      string
+		req       Request
+		wantInput []string // Role and text of input messages.
+		wantPrev  string
+	}{
+		{
+			name:      "chain first message",
+			mode:      conversationModeChain,
+			req:       Request{Message: "hi"},
+			wantInput: []string{"system: prompt", "user: hi"},
+		},
+		{
+			name:      "chain follow-up",
+			mode:      conversationModeChain,
+			req:       Request{Message: "hi", PrevResponseID: "resp_1", History: history},
+			wantInput: []string{"user: hi"},
+			wantPrev:  "resp_1",
+		},
+		{
+			name:      "chain after compaction",
+			mode:      conversationModeChain,
+			req:       Request{Message: "hi", Summary: "earlier"},
+			wantInput: []string{"system: prompt", "system: " + summaryMessage("earlier"), "user: hi"},
+		},
+		{
+			name:      "local",
+			mode:      conversationModeLocal,
+			req:       Request{Message: "hi", PrevResponseID: "resp_1", History: history, Summary: "earlier"},
+			wantInput: []string{"system: prompt", "system: " + summaryMessage("earlier"), "user: q2", "assistant: a2", "user: hi"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &gptConfig{Prompt: "prompt", Conversation: gptConversationConfig{Mode: tt.mode, MaxDepth: 1}}
+			req := (&GPT{}).prepareGTPRequest(cfg, tt.req)
+
+			var input []string
+			for _, item := range req.Input.OfInputItemList {
+				input = append(input, fmt.Sprintf("%s: %s", item.OfMessage.Role, item.OfMessage.Content.OfString.Value))
+			}
+			if strings.Join(input, "\n") != strings.Join(tt.wantInput, "\n") {
+				t.Errorf("input = %q, want %q", input, tt.wantInput)
+			}
+			if req.PreviousResponseID.Value != tt.wantPrev {
+				t.Errorf("previous response ID = %q, want %q", req.PreviousResponseID.Value, tt.wantPrev)
+			}
+		})
+	}
+}
+
+func TestPrepareGTPRequestImages(t *testing.T) {
+	image := Image{MIMEType: "image/png", Data: []byte("png")}
+
+	tests := []struct {
+		name        string
+		model       gptModelConfig
+		req         Request
+		wantModel   string
+		wantContent []string // Parts of the user message, nil if it's plain text.
+	}{
+		{name: "text", model: gptModelConfig{Name: "gpt-4o", VisionModel: "o3"}, req: Request{Message: "hi"}, wantModel: "gpt-4o"},
+		{
+			name:        "vision model",
+			model:       gptModelConfig{Name: "gpt-4o", VisionModel: "o3"},
+			req:         Request{Message: "what is it", Images: []Image{image, image}},
+			wantModel:   "o3",
+			wantContent: []string{"what is it", image.DataURL(), image.DataURL()},
+		},
+		{
+			name:        "no vision model",
+			model:       gptModelConfig{Name: "gpt-4o"},
+			req:         Request{Images: []Image{image}},
+			wantModel:   "gpt-4o",
+			wantContent: []string{image.DataURL()},
+		},
+		{
+			name:        "instruction",
+			model:       gptModelConfig{Name: "gpt-4o"},
+			req:         Request{Message: "text", Instruction: "translate"},
+			wantModel:   "gpt-4o",
+			wantContent: []string{"translate", "text"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &gptConfig{Model: tt.model}
+			req := (&GPT{}).prepareGTPRequest(cfg, tt.req)
+
+			if string(req.Model) != tt.wantModel {
+				t.Errorf("model = %q, want %q", req.Model, tt.wantModel)
+			}
+
+			items := req.Input.OfInputItemList
+			user := items[len(items)-1].OfMessage.Content
+			var content []string
+			for _, part := range user.OfInputItemContentList {
+				if part.OfInputText != nil {
+					content = append(content, part.OfInputText.Text)
+				}
+				if part.OfInputImage != nil {
+					content = append(content, part.OfInputImage.ImageURL.Value)
+				}
+			}
+			if strings.Join(content, "\n") != strings.Join(tt.wantContent, "\n") {
+				t.Errorf("user message content = %q, want %q", content, tt.wantContent)
+			}
+			if tt.wantContent == nil && user.OfString.Value != tt.req.Message {
+				t.Errorf("user message = %q, want %q", user.OfString.Value, tt.req.Message)
+			}
+		})
+	}
+}
+
+func TestModelConfigApplyReasoning(t *testing.T) {
+	tests := []struct {
+		name        string
+		reasoning   gptReasoningConfig
+		wantEffort  string
+		wantSummary string // Not supported by the Chat Completions API.
+	}{
+		{name: "defaults"},
+		{name: "effort", reasoning: gptReasoningConfig{Effort: "low"}, wantEffort: "low"},
+		{name: "summary", reasoning: gptReasoningConfig{Effort: "high", Summary: "concise"}, wantEffort: "high", wantSummary: "concise"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := gptModelConfig{Reasoning: tt.reasoning}
+
+			var req responses.ResponseNewParams
+			model.apply(&req)
+			if string(req.Reasoning.Effort) != tt.wantEffort || string(req.Reasoning.Summary) != tt.wantSummary {
+				t.Errorf("reasoning = %q, %q, want %q, %q", req.Reasoning.Effort, req.Reasoning.Summary, tt.wantEffort, tt.wantSummary)
+			}
+
+			var chatReq openai.ChatCompletionNewParams
+			model.applyChat(&chatReq)
+			if string(chatReq.ReasoningEffort) != tt.wantEffort {
+				t.Errorf("chat reasoning effort = %q, want %q", chatReq.ReasoningEffort, tt.wantEffort)
+			}
+		})
+	}
+}
+
+func TestReasoningSummary(t *testing.T) {
+	message := `{"type": "message", "id": "msg_1", "role": "assistant", "status": "completed", "content": [{"type": "output_text", "text": "reply", "annotations": []}]}`
+
+	tests := []struct {
+		name   string
+		output string // Output items of the response.
+		want   string
+	}{
+		{name: "no reasoning", output: message},
+		{name: "no summary", output: `{"type": "reasoning", "id": "rs_1", "summary": []}, ` + message},
+		{
+			name:   "summary",
+			output: `{"type": "reasoning", "id": "rs_1", "summary": [{"type": "summary_text", "text": "first"}, {"type": "summary_text", "text": "second"}]}, ` + message,
+			want:   "first\n\nsecond",
+		},
+		{
+			name: "several items",
+			output: `{"type": "reasoning", "id": "rs_1", "summary": [{"type": "summary_text", "text": "first"}]}, ` +
+				`{"type": "reasoning", "id": "rs_2", "summary": [{"type": "summary_text", "text": "second"}]}, ` + message,
+			want: "first\n\nsecond",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response responses.Response
+			err := json.Unmarshal([]byte(`{"id": "resp_1", "output": [`+tt.output+`]}`), &response)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := reasoningSummary(&response); got != tt.want {
+				t.Errorf("reasoningSummary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
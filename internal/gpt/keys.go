@@ -0,0 +1,371 @@
+package gpt
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/v3/option"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	rateLimitBench = 30 * time.Second // Bench time of a rate limited key if the server doesn't say how long to wait.
+	quotaBench     = time.Hour        // Bench time of a key that is out of credits.
+	authBench      = time.Hour        // Bench time of a key that is rejected as invalid.
+	maxErrorBody   = 64 << 10         // Max size of an error response body read to classify it.
+)
+
+// KeyStatusReporter reports health of API keys.
+type KeyStatusReporter interface {
+	// KeyStatus returns status of every API key in the pool.
+	KeyStatus() []KeyStatus
+}
+
+// KeyStatus is a health status of an API key.
+type KeyStatus struct {
+	Key             string    // Masked key.
+	Organization    string    // Organization ID, if any.
+	Project         string    // Project ID, if any.
+	Requests        int64     // Number of requests sent with the key.
+	RateLimits      int64     // Number of requests rejected due to rate limits.
+	AuthErrors      int64     // Number of requests rejected due to invalid key or missing access.
+	LastError       string    // Last error class, if any.
+	LastRateLimited time.Time // Time the key was last rate limited, zero if never.
+	BenchedUntil    time.Time // Time the key will be used again, zero if it's healthy.
+}
+
+// apiKey is an API key with its health stats.
+type apiKey struct {
+	key          string
+	organization string
+	project      string
+
+	requests        int64
+	rateLimits      int64
+	authErrors      int64
+	lastError       ErrorKind
+	lastRateLimited time.Time
+	benchedUntil    time.Time
+}
+
+// keyPool is a pool of API keys.
+// Each request is sent with the least recently rate limited healthy key, keys are rotated round-robin otherwise.
+// Keys that are rate limited or rejected are benched for a while.
+type keyPool struct {
+	mutex  sync.Mutex
+	keys   []*apiKey
+	next   int
+	header string // Header to send keys in, empty means "Authorization: Bearer <key>".
+}
+
+var _ KeyStatusReporter = (*keyPool)(nil)
+
+// newKeyPool parses a list of API keys separated by commas, semicolons or spaces.
+// Each key may be followed by an organization ID and a project ID, separated by colons:
+// "sk-1,sk-2:org-abc,sk-3:org-abc:proj_xyz".
+func newKeyPool(token string) (*keyPool, error) {
+	fieldFunc := func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n'
+	}
+
+	pool := &keyPool{}
+	for _, field := range strings.FieldsFunc(token, fieldFunc) {
+		parts := strings.Split(field, ":")
+		if len(parts) > 3 || parts[0] == "" {
+			return nil, errors.Errorf("malformed api key %q: must be \"key[:organization[:project]]\"", maskKey(parts[0]))
+		}
+
+		key := &apiKey{key: parts[0]}
+		if len(parts) > 1 {
+			key.organization = parts[1]
+		}
+		if len(parts) > 2 {
+			key.project = parts[2]
+		}
+		pool.keys = append(pool.keys, key)
+	}
+
+	// A keyless pool still works with servers that don't require authentication.
+	if len(pool.keys) == 0 {
+		pool.keys = append(pool.keys, &apiKey{})
+	}
+
+	log.Info().Int("keys", len(pool.keys)).Msg("loaded api keys")
+	return pool, nil
+}
+
+// checkConversation returns an error if conversations stored by the provider may be continued with keys of another
+// organization or project, which can't see stored responses. Keys without organization and project are assumed
+// to belong to the same project.
+func (p *keyPool) checkConversation(api api, cfg *gptConversationConfig) error {
+	if cfg.useLocalHistory(api) {
+		return nil
+	}
+
+	projects := make(map[[2]string]struct{})
+	for _, key := range p.keys {
+		projects[[2]string{key.organization, key.project}] = struct{}{}
+	}
+
+	if len(projects) > 1 {
+		return errors.Errorf("api keys span %d organizations or projects, which can't continue each other's conversations; "+
+			"use keys of a single project or set conversation.mode to %q", len(projects), conversationModeLocal)
+	}
+
+	return nil
+}
+
+// options returns client options that make the client use the pool.
+func (p *keyPool) options() []option.RequestOption {
+	return []option.RequestOption{
+		option.WithAPIKey(p.keys[0].key),
+		option.WithMiddleware(p.middleware),
+	}
+}
+
+// middleware sends a request with a key from the pool.
+// If the key gets benched, the request is resent with another healthy key, if there is one.
+func (p *keyPool) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		key := p.pick()
+		key.apply(req, p.header)
+
+		resp, err := next(req)
+		if !p.report(key, resp, err) || attempt >= len(p.keys) || !p.hasHealthy() {
+			return resp, err
+		}
+
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return resp, err
+			}
+
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			req.Body = body
+		}
+		_ = resp.Body.Close()
+
+		log.Warn().Str("key", maskKey(key.key)).Int("attempt", attempt).Msg("retrying request with another api key")
+	}
+}
+
+// pick returns the least recently rate limited healthy key.
+// If every key is benched, the one that gets back soonest is returned.
+func (p *keyPool) pick() *apiKey {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	var best *apiKey
+	bestIndex := 0
+	for i := range p.keys {
+		index := (p.next + i) % len(p.keys)
+		key := p.keys[index]
+
+		switch {
+		case best == nil:
+		case best.benched(now) != key.benched(now):
+			if key.benched(now) {
+				continue
+			}
+		case best.benched(now):
+			if !key.benchedUntil.Before(best.benchedUntil) {
+				continue
+			}
+		case !key.lastRateLimited.Before(best.lastRateLimited):
+			continue
+		}
+
+		best, bestIndex = key, index
+	}
+
+	if best.benched(now) {
+		log.Warn().Str("key", maskKey(best.key)).Time("benched_until", best.benchedUntil).Msg("all api keys are benched")
+	}
+
+	p.next = (bestIndex + 1) % len(p.keys)
+	best.requests++
+	return best
+}
+
+// hasHealthy returns true if there is a key that isn't benched.
+func (p *keyPool) hasHealthy() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	for _, key := range p.keys {
+		if !key.benched(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// report updates key health with a result of a request, and returns true if the key got benched.
+func (p *keyPool) report(key *apiKey, resp *http.Response, err error) bool {
+	if err != nil || resp == nil {
+		return false
+	}
+
+	var kind ErrorKind
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusUnauthorized, http.StatusForbidden:
+		kind = classifyAPIError(resp.StatusCode, errorCode(resp), "")
+	default:
+		if resp.StatusCode < 400 {
+			p.recover(key)
+		}
+		return false
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	bench := authBench
+	switch kind {
+	case ErrorRateLimit:
+		bench = rateLimitBench
+		if delay := retryAfter(resp); delay > 0 {
+			bench = delay
+		}
+		key.rateLimits++
+		key.lastRateLimited = now
+	case ErrorQuotaExceeded:
+		bench = quotaBench
+		key.rateLimits++
+		key.lastRateLimited = now
+	default:
+		key.authErrors++
+	}
+
+	key.lastError = kind
+	key.benchedUntil = now.Add(bench)
+
+	log.Warn().
+		Str("key", maskKey(key.key)).
+		Str("error", kind.String()).
+		Time("benched_until", key.benchedUntil).
+		Msg("api key benched")
+	return true
+}
+
+// recover marks key as healthy after a successful request.
+func (p *keyPool) recover(key *apiKey) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if key.benchedUntil.IsZero() {
+		return
+	}
+
+	key.benchedUntil = time.Time{}
+	log.Info().Str("key", maskKey(key.key)).Msg("api key recovered")
+}
+
+// KeyStatus returns status of every API key in the pool.
+func (p *keyPool) KeyStatus() []KeyStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	statuses := make([]KeyStatus, 0, len(p.keys))
+	for _, key := range p.keys {
+		status := KeyStatus{
+			Key:             maskKey(key.key),
+			Organization:    key.organization,
+			Project:         key.project,
+			Requests:        key.requests,
+			RateLimits:      key.rateLimits,
+			AuthErrors:      key.authErrors,
+			LastRateLimited: key.lastRateLimited,
+		}
+		if key.lastError != ErrorUnknown {
+			status.LastError = key.lastError.String()
+		}
+		if key.benched(now) {
+			status.BenchedUntil = key.benchedUntil
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+func (k *apiKey) benched(now time.Time) bool {
+	return now.Before(k.benchedUntil)
+}
+
+// apply sets request authentication headers.
+// Key goes to the header, or to "Authorization: Bearer <key>" if header is empty.
+func (k *apiKey) apply(req *http.Request, header string) {
+	if header != "" {
+		req.Header.Del("Authorization")
+		if k.key != "" {
+			req.Header.Set(header, k.key)
+		}
+	} else if k.key != "" {
+		req.Header.Set("Authorization", "Bearer "+k.key)
+	}
+
+	setOrDelete := func(name, value string) {
+		if value != "" {
+			req.Header.Set(name, value)
+		} else {
+			req.Header.Del(name)
+		}
+	}
+	setOrDelete("OpenAI-Organization", k.organization)
+	setOrDelete("OpenAI-Project", k.project)
+}
+
+// errorCode returns error code from an error response body, leaving the body readable.
+func errorCode(resp *http.Response) string {
+	if resp.Body == nil {
+		return ""
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return ""
+	}
+
+	if body.Error.Code != "" {
+		return body.Error.Code
+	}
+	return body.Error.Type
+}
+
+// maskKey hides most of an API key so that it can be logged.
+func maskKey(key string) string {
+	const visible = 4
+
+	switch {
+	case key == "":
+		return "(none)"
+	case len(key) <= 2*visible:
+		return "…"
+	default:
+		return key[:3] + "…" + key[len(key)-visible:]
+	}
+}
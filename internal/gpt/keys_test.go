@@ -0,0 +1,421 @@
+package gpt
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/v3/option"
+)
+
+func TestNewKeyPool(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		want    []apiKey
+		wantErr bool
+	}{
+		{name: "empty", token: "", want: []apiKey{{}}},
+		{name: "single", token: "sk-1", want: []apiKey{{key: "sk-1"}}},
+		{
+			name:  "separators",
+			token: "sk-1, sk-2;sk-3\nsk-4",
+			want:  []apiKey{{key: "sk-1"}, {key: "sk-2"}, {key: "sk-3"}, {key: "sk-4"}},
+		},
+		{
+			name:  "organizations and projects",
+			token: "sk-1,sk-2:org-abc,sk-3:org-abc:proj_xyz,sk-4::proj_xyz",
+			want: []apiKey{
+				{key: "sk-1"},
+				{key: "sk-2", organization: "org-abc"},
+				{key: "sk-3", organization: "org-abc", project: "proj_xyz"},
+				{key: "sk-4", project: "proj_xyz"},
+			},
+		},
+		{name: "too many parts", token: "sk-1:org:proj:extra", wantErr: true},
+		{name: "missing key", token: ":org-abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := newKeyPool(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newKeyPool() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(pool.keys) != len(tt.want) {
+				t.Fatalf("got %d keys, want %d", len(pool.keys), len(tt.want))
+			}
+			for i, key := range pool.keys {
+				if *key != tt.want[i] {
+					t.Errorf("key %d = %+v, want %+v", i, *key, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKeyPoolPick(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		keys  []apiKey
+		picks []string // Keys picked by consecutive calls.
+	}{
+		{
+			name:  "round robin",
+			keys:  []apiKey{{key: "a"}, {key: "b"}, {key: "c"}},
+			picks: []string{"a", "b", "c", "a"},
+		},
+		{
+			name:  "benched keys are skipped",
+			keys:  []apiKey{{key: "a"}, {key: "b", benchedUntil: now.Add(time.Hour)}, {key: "c"}},
+			picks: []string{"a", "c", "a"},
+		},
+		{
+			name:  "expired bench",
+			keys:  []apiKey{{key: "a", benchedUntil: now.Add(-time.Second)}, {key: "b"}},
+			picks: []string{"a", "b", "a"},
+		},
+		{
+			name: "least recently rate limited",
+			keys: []apiKey{
+				{key: "a", lastRateLimited: now.Add(-time.Minute)},
+				{key: "b", lastRateLimited: now.Add(-time.Hour)},
+				{key: "c", lastRateLimited: now.Add(-time.Second)},
+			},
+			picks: []string{"b", "b"},
+		},
+		{
+			name: "all benched",
+			keys: []apiKey{
+				{key: "a", benchedUntil: now.Add(time.Hour)},
+				{key: "b", benchedUntil: now.Add(time.Minute)},
+				{key: "c", benchedUntil: now.Add(2 * time.Hour)},
+			},
+			picks: []string{"b", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := &keyPool{}
+			for _, key := range tt.keys {
+				pool.keys = append(pool.keys, &key)
+			}
+
+			var picks []string
+			for range tt.picks {
+				picks = append(picks, pool.pick().key)
+			}
+
+			if strings.Join(picks, ",") != strings.Join(tt.picks, ",") {
+				t.Errorf("picked %v, want %v", picks, tt.picks)
+			}
+		})
+	}
+}
+
+// testResponse returns a response with the given status and headers.
+func testResponse(statusCode int, header http.Header) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{StatusCode: statusCode, Header: header, Body: http.NoBody}
+}
+
+func TestKeyPoolReport(t *testing.T) {
+	tests := []struct {
+		name           string
+		resp           *http.Response
+		body           string // Response body.
+		wantBenched    bool
+		wantBench      time.Duration
+		wantRateLimits int64
+		wantAuthErrors int64
+		wantLastError  ErrorKind
+	}{
+		{name: "success", resp: testResponse(200, nil)},
+		{name: "server error", resp: testResponse(500, nil)},
+		{name: "bad request", resp: testResponse(400, nil), body: `{"error": {"code": "invalid_prompt"}}`},
+		{
+			name:           "rate limit",
+			resp:           testResponse(429, nil),
+			body:           `{"error": {"code": "rate_limit_exceeded"}}`,
+			wantBenched:    true,
+			wantBench:      rateLimitBench,
+			wantRateLimits: 1,
+			wantLastError:  ErrorRateLimit,
+		},
+		{
+			name:           "rate limit with retry after",
+			resp:           testResponse(429, http.Header{"Retry-After": {"5"}}),
+			wantBenched:    true,
+			wantBench:      5 * time.Second,
+			wantRateLimits: 1,
+			wantLastError:  ErrorRateLimit,
+		},
+		{
+			name:           "out of credits",
+			resp:           testResponse(429, nil),
+			body:           `{"error": {"type": "insufficient_quota"}}`,
+			wantBenched:    true,
+			wantBench:      quotaBench,
+			wantRateLimits: 1,
+			wantLastError:  ErrorQuotaExceeded,
+		},
+		{
+			name:           "invalid key",
+			resp:           testResponse(401, nil),
+			body:           `{"error": {"code": "invalid_api_key"}}`,
+			wantBenched:    true,
+			wantBench:      authBench,
+			wantAuthErrors: 1,
+			wantLastError:  ErrorAuth,
+		},
+		{
+			name:           "no access",
+			resp:           testResponse(403, nil),
+			body:           "not json",
+			wantBenched:    true,
+			wantBench:      authBench,
+			wantAuthErrors: 1,
+			wantLastError:  ErrorAuth,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.resp.Body = io.NopCloser(strings.NewReader(tt.body))
+			key := &apiKey{key: "sk-test"}
+			pool := &keyPool{keys: []*apiKey{key}}
+
+			start := time.Now()
+			if benched := pool.report(key, tt.resp, nil); benched != tt.wantBenched {
+				t.Fatalf("report() = %v, want %v", benched, tt.wantBenched)
+			}
+
+			if tt.wantBenched {
+				bench := key.benchedUntil.Sub(start)
+				if bench < tt.wantBench || bench > tt.wantBench+time.Second {
+					t.Errorf("key is benched for %v, want %v", bench, tt.wantBench)
+				}
+			} else if !key.benchedUntil.IsZero() {
+				t.Errorf("key is benched until %v", key.benchedUntil)
+			}
+
+			if key.rateLimits != tt.wantRateLimits || key.authErrors != tt.wantAuthErrors || key.lastError != tt.wantLastError {
+				t.Errorf("key stats = %d rate limits, %d auth errors, last error %v, want %d, %d, %v",
+					key.rateLimits, key.authErrors, key.lastError, tt.wantRateLimits, tt.wantAuthErrors, tt.wantLastError)
+			}
+
+			// The body stays readable for the caller.
+			if body, _ := io.ReadAll(tt.resp.Body); string(body) != tt.body {
+				t.Errorf("response body = %q after report(), want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestKeyPoolRecover(t *testing.T) {
+	key := &apiKey{key: "sk-test", benchedUntil: time.Now().Add(-time.Second), lastError: ErrorRateLimit}
+	pool := &keyPool{keys: []*apiKey{key}}
+
+	pool.report(key, testResponse(200, nil), nil)
+	if !key.benchedUntil.IsZero() {
+		t.Errorf("key is benched until %v after a successful request", key.benchedUntil)
+	}
+
+	if status := pool.KeyStatus()[0]; status.LastError != ErrorRateLimit.String() || !status.BenchedUntil.IsZero() {
+		t.Errorf("key status = %+v, want healthy with the last error kept", status)
+	}
+}
+
+func TestKeyPoolMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		rejected   map[string]int // Status of responses to requests with the given key.
+		wantKeys   []string       // Keys requests are sent with.
+		wantStatus int
+	}{
+		{name: "healthy", token: "sk-aaaa1111,sk-bbbb2222", wantKeys: []string{"sk-aaaa1111"}, wantStatus: 200},
+		{
+			name:       "rate limited key",
+			token:      "sk-aaaa1111,sk-bbbb2222",
+			rejected:   map[string]int{"sk-aaaa1111": 429},
+			wantKeys:   []string{"sk-aaaa1111", "sk-bbbb2222"},
+			wantStatus: 200,
+		},
+		{
+			name:       "all keys rejected",
+			token:      "sk-aaaa1111,sk-bbbb2222",
+			rejected:   map[string]int{"sk-aaaa1111": 401, "sk-bbbb2222": 429},
+			wantKeys:   []string{"sk-aaaa1111", "sk-bbbb2222"},
+			wantStatus: 429,
+		},
+		{
+			name:       "single key",
+			token:      "sk-aaaa1111",
+			rejected:   map[string]int{"sk-aaaa1111": 429},
+			wantKeys:   []string{"sk-aaaa1111"},
+			wantStatus: 429,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mutex sync.Mutex
+				keys  []string
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+				body, _ := io.ReadAll(r.Body)
+
+				mutex.Lock()
+				keys = append(keys, key)
+				mutex.Unlock()
+
+				if string(body) != "payload" {
+					t.Errorf("request body = %q, want %q", body, "payload")
+				}
+
+				if status, rejected := tt.rejected[key]; rejected {
+					w.WriteHeader(status)
+					return
+				}
+				_, _ = w.Write([]byte("{}"))
+			}))
+			t.Cleanup(server.Close)
+
+			pool, err := newKeyPool(tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+			resp, err := pool.middleware(req, option.MiddlewareNext(http.DefaultClient.Do))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if strings.Join(keys, ",") != strings.Join(tt.wantKeys, ",") {
+				t.Errorf("requests are sent with keys %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestApplyKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        apiKey
+		header     string
+		wantHeader http.Header
+	}{
+		{
+			name:       "bearer",
+			key:        apiKey{key: "sk-1"},
+			wantHeader: http.Header{"Authorization": {"Bearer sk-1"}},
+		},
+		{
+			name: "organization and project",
+			key:  apiKey{key: "sk-1", organization: "org-1", project: "proj_1"},
+			wantHeader: http.Header{
+				"Authorization":       {"Bearer sk-1"},
+				"Openai-Organization": {"org-1"},
+				"Openai-Project":      {"proj_1"},
+			},
+		},
+		{
+			name:       "custom header",
+			key:        apiKey{key: "azure-key"},
+			header:     "api-key",
+			wantHeader: http.Header{"Api-Key": {"azure-key"}},
+		},
+		{name: "no key with custom header", key: apiKey{}, header: "api-key", wantHeader: http.Header{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+			req.Header.Set("Authorization", "Bearer stale")
+			req.Header.Set("OpenAI-Organization", "org-stale")
+			req.Header.Set("OpenAI-Project", "proj_stale")
+
+			tt.key.apply(req, tt.header)
+
+			if len(req.Header) != len(tt.wantHeader) {
+				t.Errorf("headers = %v, want %v", req.Header, tt.wantHeader)
+			}
+			for name, values := range tt.wantHeader {
+				if got := req.Header.Values(name); strings.Join(got, ",") != strings.Join(values, ",") {
+					t.Errorf("header %s = %v, want %v", name, got, values)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyPoolCheckConversation(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		api     api
+		mode    string
+		wantErr bool
+	}{
+		{name: "single project", token: "sk-1:org:proj,sk-2:org:proj", api: apiResponses},
+		{name: "unqualified keys", token: "sk-1,sk-2", api: apiResponses},
+		{name: "projects in chain mode", token: "sk-1:org:proj1,sk-2:org:proj2", api: apiResponses, wantErr: true},
+		{name: "organizations in chain mode", token: "sk-1:org1,sk-2:org2", api: apiResponses, mode: conversationModeChain, wantErr: true},
+		{name: "mixed keys in chain mode", token: "sk-1,sk-2:org:proj", api: apiResponses, wantErr: true},
+		{name: "projects in local mode", token: "sk-1:org:proj1,sk-2:org:proj2", api: apiResponses, mode: conversationModeLocal},
+		{name: "projects with chat completions", token: "sk-1:org:proj1,sk-2:org:proj2", api: apiChatCompletions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := newKeyPool(tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = pool.checkConversation(tt.api, &gptConversationConfig{Mode: tt.mode})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkConversation() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaskKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "", want: "(none)"},
+		{key: "short", want: "…"},
+		{key: "sk-proj-abcdef123456", want: "sk-…3456"},
+	}
+
+	for _, tt := range tests {
+		if got := maskKey(tt.key); got != tt.want {
+			t.Errorf("maskKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/openai/openai-go/v3/responses"
+	"github.com/openai/openai-go/v3/shared"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// sectionPrompt asks the model to summarize a single section of a long text.
+const sectionPrompt = `You are given section %d of %d of a long text. The text is too long to be processed at once,
+so every section is summarized separately and then the summaries are combined.
+Summarize this section, keeping all facts, names, numbers, conclusions and important details.
+Write the summary in the language of the text. Reply with the summary only.`
+
+// sectionInstructionPrompt tells the model what the user wants to get out of the text.
+const sectionInstructionPrompt = `
+The user asked to do the following with the whole text, keep the details it needs: %s`
+
+// sectionsMessage introduces summaries of sections that replace a long text.
+const sectionsMessage = "The text is too long, so here are summaries of its sections in order:\n\n"
+
+const (
+	maxReduceLevels     = 3 // Max number of times summaries are summarized again.
+	maxParallelSections = 4 // Max number of sections summarized at once.
+)
+
+// summarizeFunc sends text with instructions as a standalone request and returns the output.
+type summarizeFunc func(ctx context.Context, cfg *gptConfig, instructions, text string) (string, Usage, error)
+
+// reduceLongInput replaces a message that doesn't fit into the input budget with summaries of its sections.
+// If summaries don't fit either, they are summarized again.
+// Returned usage covers all the summarization requests.
+func reduceLongInput(ctx context.Context, cfg *gptConfig, req Request, summarize summarizeFunc) (Request, Usage, error) {
+	var usage Usage
+
+	for level := 0; estimateTokens(req.Message) > cfg.LongInput.budget(); level++ {
+		if level == maxReduceLevels {
+			return req, usage, errors.New("input is too long to be summarized")
+		}
+
+		sections := splitSections(req.Message, cfg.LongInput.sectionSize(), cfg.LongInput.sectionOverlap())
+		log.Info().
+			Int64("tokens", estimateTokens(req.Message)).
+			Int("sections", len(sections)).
+			Int("level", level).
+			Msg("summarizing long input by sections")
+
+		summaries, sectionsUsage, err := summarizeSections(ctx, cfg, req.Instruction, sections, summarize)
+		usage = usage.Add(sectionsUsage)
+		if err != nil {
+			return req, usage, errors.Wrap(err, "unable to summarize long input")
+		}
+
+		req.Message = sectionsMessage + strings.Join(summaries, "\n\n")
+	}
+
+	return req, usage, nil
+}
+
+// withUsage adds usage of extra requests to the response.
+func withUsage(response Response, usage Usage) Response {
+	response.Usage = response.Usage.Add(usage)
+	return response
+}
+
+func summarizeSections(ctx context.Context, cfg *gptConfig, instruction string, sections []string, summarize summarizeFunc) ([]string, Usage, error) {
+	var (
+		summaries = make([]string, len(sections))
+		usages    = make([]Usage, len(sections))
+		errs      = make([]error, len(sections))
+		semaphore = make(chan struct{}, maxParallelSections)
+		wg        sync.WaitGroup
+	)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for i, section := range sections {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			instructions := fmt.Sprintf(sectionPrompt, i+1, len(sections))
+			if instruction != "" {
+				instructions += fmt.Sprintf(sectionInstructionPrompt, instruction)
+			}
+
+			summary, usage, err := summarize(ctx, cfg, instructions, section)
+			if err != nil {
+				errs[i] = err
+				cancel()
+				return
+			}
+
+			summaries[i] = fmt.Sprintf("[Section %d of %d]\n%s", i+1, len(sections), strings.TrimSpace(summary))
+			usages[i] = usage
+		}()
+	}
+	wg.Wait()
+
+	var usage Usage
+	for _, u := range usages {
+		usage = usage.Add(u)
+	}
+
+	// Other sections are canceled after the first failure, so its error is the one to report.
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, usage, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, usage, err
+		}
+	}
+
+	return summaries, usage, nil
+}
+
+// estimateTokens roughly estimates number of tokens in text.
+// Tokenizers produce about 4 characters per token for English and about 2 for Cyrillic and most other scripts.
+func estimateTokens(text string) int64 {
+	var ascii, other int64
+	for _, r := range text {
+		if r < 0x80 {
+			ascii++
+		} else {
+			other++
+		}
+	}
+
+	return ascii/4 + other/2
+}
+
+// splitSections splits text into sections of about size tokens, each overlapping with the previous one by overlap tokens.
+// Sections are cut on paragraph, line or word boundaries where possible.
+func splitSections(text string, size, overlap int64) []string {
+	runes := []rune(text)
+	runesPerToken := float64(len(runes)) / float64(max(estimateTokens(text), 1))
+
+	sectionLength := max(int(float64(size)*runesPerToken), 1)
+	overlapLength := min(int(float64(overlap)*runesPerToken), sectionLength/2)
+
+	var sections []string
+	for start := 0; start < len(runes); {
+		end := min(start+sectionLength, len(runes))
+		if end < len(runes) {
+			end = breakPoint(runes, start+sectionLength/2, end)
+		}
+
+		sections = append(sections, string(runes[start:end]))
+		if end == len(runes) {
+			break
+		}
+
+		start = max(end-overlapLength, start+1)
+		if overlapLength > 0 {
+			// Start the overlap on a word boundary too.
+			for start < end && runes[start-1] != ' ' && runes[start-1] != '\n' {
+				start++
+			}
+		}
+	}
+
+	return sections
+}
+
+// breakPoint returns a position right after the last paragraph break in runes[from:to],
+// falling back to a line break, a space and to itself.
+func breakPoint(runes []rune, from, to int) int {
+	for _, separator := range []string{"\n\n", "\n", " "} {
+		s := []rune(separator)
+		for i := to - len(s); i >= from; i-- {
+			if string(runes[i:i+len(s)]) == separator {
+				return i + len(s)
+			}
+		}
+	}
+
+	return to
+}
+
+// summarize sends a standalone request that isn't stored by OpenAI.
+func (g *GPT) summarize(ctx context.Context, cfg *gptConfig, instructions, text string) (string, Usage, error) {
+	response, err := withRetry(ctx, func() (*responses.Response, error) {
+		return g.client.Responses.New(ctx, responses.ResponseNewParams{
+			Model:        shared.ResponsesModel(cfg.Model.Name),
+			Instructions: param.Opt[string]{Value: instructions},
+			Input:        responses.ResponseNewParamsInputUnion{OfString: param.Opt[string]{Value: text}},
+			Store:        param.Opt[bool]{Value: false},
+		})
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	return response.OutputText(), convertUsage(response.Usage), nil
+}
+
+// summarize sends a standalone request.
+func (c *ChatCompletions) summarize(ctx context.Context, cfg *gptConfig, instructions, text string) (string, Usage, error) {
+	completion, err := withRetry(ctx, func() (*openai.ChatCompletion, error) {
+		return c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+			Model: shared.ChatModel(cfg.Model.Name),
+			Messages: []openai.ChatCompletionMessageParamUnion{
+				openai.SystemMessage(instructions),
+				openai.UserMessage(text),
+			},
+		})
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	if len(completion.Choices) == 0 {
+		return "", Usage{}, errors.New("chat completion has no choices")
+	}
+
+	return completion.Choices[0].Message.Content, convertChatUsage(completion.Usage), nil
+}
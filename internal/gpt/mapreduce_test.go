@@ -0,0 +1,193 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int64
+	}{
+		{text: "", want: 0},
+		{text: "abc", want: 0},
+		{text: "four word text ok", want: 4},
+		{text: "Привет", want: 3},
+		{text: "hi мир!", want: 2},
+	}
+
+	for _, tt := range tests {
+		if got := estimateTokens(tt.text); got != tt.want {
+			t.Errorf("estimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplitSections(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		size    int64
+		overlap int64
+		want    []string
+	}{
+		{name: "empty", text: "", size: 10},
+		{name: "fits", text: "short text", size: 10, want: []string{"short text"}},
+		{
+			name: "words",
+			text: "one two three four five six",
+			size: 2,
+			want: []string{"one two ", "three ", "four ", "five six"},
+		},
+		{
+			name: "paragraphs first",
+			text: "First paragraph.\n\nSecond one, a bit longer.\nThird line.",
+			size: 8,
+			want: []string{"First paragraph.\n\n", "Second one, a bit longer.\n", "Third line."},
+		},
+		{
+			name: "no boundaries",
+			text: strings.Repeat("x", 20),
+			size: 2,
+			want: []string{"xxxxxxxx", "xxxxxxxx", "xxxx"},
+		},
+		{
+			name:    "overlap",
+			text:    "aaa bbb ccc ddd eee fff ggg hhh",
+			size:    4,
+			overlap: 1,
+			want:    []string{"aaa bbb ccc ddd ", "ddd eee fff ggg ", "ggg hhh"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitSections(tt.text, tt.size, tt.overlap)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("splitSections() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitSectionsCoverText(t *testing.T) {
+	var words []string
+	for i := range 500 {
+		if i%7 == 6 {
+			words = append(words, fmt.Sprintf("слово%d.\n", i))
+		} else {
+			words = append(words, fmt.Sprintf("word%d", i))
+		}
+	}
+	text := strings.Join(words, " ")
+
+	for _, overlap := range []int64{0, 20} {
+		sections := splitSections(text, 100, overlap)
+		if len(sections) < 2 {
+			t.Fatalf("overlap %d: got %d sections, want several", overlap, len(sections))
+		}
+
+		if overlap == 0 && strings.Join(sections, "") != text {
+			t.Errorf("overlap %d: sections don't add up to the text", overlap)
+		}
+
+		// Every section continues the text without gaps, overlapping the previous one by about overlap tokens.
+		prevEnd := 0
+		for i, section := range sections {
+			start := strings.Index(text, section)
+			if start < 0 || start > prevEnd || (i > 0 && start == prevEnd && overlap > 0) {
+				t.Fatalf("overlap %d: section %d %q doesn't continue the text", overlap, i, section)
+			}
+			if tokens := estimateTokens(text[start:prevEnd]); tokens > overlap {
+				t.Errorf("overlap %d: section %d overlaps by %d tokens", overlap, i, tokens)
+			}
+			if tokens := estimateTokens(section); tokens > 100 {
+				t.Errorf("overlap %d: section %d has %d tokens, want at most 100", overlap, i, tokens)
+			}
+			prevEnd = start + len(section)
+		}
+
+		if prevEnd != len(text) {
+			t.Errorf("overlap %d: sections end at %d, want %d", overlap, prevEnd, len(text))
+		}
+	}
+}
+
+func TestReduceLongInput(t *testing.T) {
+	// The smallest overlap is within a word, so sections start at word boundaries without overlapping.
+	cfg := &gptConfig{LongInput: gptLongInputConfig{Budget: 100, SectionSize: 50, SectionOverlap: 1}}
+	long := strings.Repeat("word ", 200) // 250 tokens, 5 sections.
+
+	tests := []struct {
+		name        string
+		message     string
+		summarize   func(text string) (string, error)
+		wantCalls   int32
+		wantMessage string
+		wantErr     bool
+	}{
+		{
+			name:        "fits",
+			message:     "short message",
+			summarize:   func(string) (string, error) { return "summary", nil },
+			wantMessage: "short message",
+		},
+		{
+			name:      "one level",
+			message:   long,
+			summarize: func(string) (string, error) { return " summary\n", nil },
+			wantCalls: 5,
+			wantMessage: sectionsMessage +
+				"[Section 1 of 5]\nsummary\n\n[Section 2 of 5]\nsummary\n\n[Section 3 of 5]\nsummary\n\n" +
+				"[Section 4 of 5]\nsummary\n\n[Section 5 of 5]\nsummary",
+		},
+		{
+			name:      "summaries don't shrink",
+			message:   long,
+			summarize: func(text string) (string, error) { return text, nil },
+			wantErr:   true,
+		},
+		{
+			name:      "failure",
+			message:   long,
+			summarize: func(string) (string, error) { return "", errors.New("failed") },
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			summarize := func(ctx context.Context, _ *gptConfig, instructions, text string) (string, Usage, error) {
+				calls.Add(1)
+				if !strings.Contains(instructions, "keep the details it needs: focus") {
+					t.Errorf("instructions %q don't include the user instruction", instructions)
+				}
+
+				summary, err := tt.summarize(text)
+				return summary, Usage{TotalTokens: 1}, err
+			}
+
+			req, usage, err := reduceLongInput(context.Background(), cfg, Request{Message: tt.message, Instruction: "focus"}, summarize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reduceLongInput() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if tt.wantCalls > 0 && calls.Load() != tt.wantCalls {
+				t.Errorf("got %d summarize calls, want %d", calls.Load(), tt.wantCalls)
+			}
+			if !tt.wantErr && usage.TotalTokens != int64(calls.Load()) {
+				t.Errorf("usage = %d tokens, want %d", usage.TotalTokens, calls.Load())
+			}
+			if !tt.wantErr && req.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", req.Message, tt.wantMessage)
+			}
+		})
+	}
+}
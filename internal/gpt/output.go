@@ -0,0 +1,141 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// jsonOutput is a structured output of the model.
+type jsonOutput struct {
+	OutputMarkdown   string   `json:"output_markdown"`
+	TLDR             string   `json:"tldr"`
+	KeyPoints        []string `json:"key_points"`
+	Tags             []string `json:"tags"`
+	DetectedLanguage string   `json:"detected_language"`
+	Confidence       float64  `json:"confidence"`
+}
+
+// outputSchemaName is a name of JSON schema of jsonOutput.
+const outputSchemaName = "output"
+
+// outputSchema returns JSON schema of jsonOutput.
+// "output_markdown" goes first so that it's streamed first.
+func outputSchema() map[string]any {
+	stringArray := func(description string) map[string]any {
+		return map[string]any{
+			"type":        "array",
+			"items":       map[string]any{"type": "string"},
+			"description": description,
+		}
+	}
+
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"output_markdown": map[string]any{
+				"type":        "string",
+				"description": "The response text in Markdown format.",
+			},
+			"tldr": map[string]any{
+				"type":        "string",
+				"description": "A one-sentence summary of the response, in the language of the response.",
+			},
+			"key_points": stringArray("Up to 5 key points of the response, short plain text sentences."),
+			"tags":       stringArray("1 to 5 topic tags of the input, single lowercase words without \"#\"."),
+			"detected_language": map[string]any{
+				"type":        "string",
+				"description": "ISO 639-1 code of the input language, e.g. \"en\".",
+			},
+			"confidence": map[string]any{
+				"type":        "number",
+				"description": "Confidence in accuracy of the response, from 0 to 1.",
+			},
+		},
+		"required":             []string{"output_markdown", "tldr", "key_points", "tags", "detected_language", "confidence"},
+		"additionalProperties": false,
+	}
+}
+
+// parseOutput parses output of the model.
+// Output that violates the schema is returned as text along with an error.
+func parseOutput(cfg *gptConfig, text string) (jsonOutput, error) {
+	if !cfg.Model.useJSONSchema() {
+		return jsonOutput{OutputMarkdown: text}, nil
+	}
+
+	var output jsonOutput
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&output)
+	if err != nil {
+		// Output cut short by the token limit still has the text that has been streamed so far.
+		return jsonOutput{OutputMarkdown: partialOutputMarkdown(text)}, errors.Wrap(err, "malformed output")
+	}
+
+	if decoder.More() {
+		return output, errors.New("malformed output: trailing data")
+	}
+
+	if output.OutputMarkdown == "" {
+		return output, errors.New("output_markdown is empty")
+	}
+
+	if output.Confidence < 0 || output.Confidence > 1 {
+		return output, fmt.Errorf("confidence must be in range [0, 1], got %v", output.Confidence)
+	}
+
+	return output, nil
+}
+
+// completeStructured calls complete and parses the output.
+// If the output violates the schema, complete is called once more, and the second output is taken as is.
+// Returned usage includes the usage of the discarded completion.
+func completeStructured[T any](
+	cfg *gptConfig,
+	complete func() (T, Usage, error),
+	outputOf func(completion T) (string, Usage),
+) (T, jsonOutput, Usage, error) {
+	completion, usage, err := complete()
+	if err != nil {
+		return completion, jsonOutput{}, usage, err
+	}
+
+	text, discardedUsage := outputOf(completion)
+	output, err := parseOutput(cfg, text)
+	if err == nil {
+		return completion, output, usage, nil
+	}
+
+	log.Warn().Err(err).Str("model", cfg.Model.Name).Msg("output violates schema, retrying")
+
+	retried, retryUsage, err := complete()
+	usage = usage.Add(discardedUsage).Add(retryUsage)
+	if err != nil {
+		return retried, jsonOutput{}, usage, err
+	}
+
+	text, _ = outputOf(retried)
+	output, err = parseOutput(cfg, text)
+	if err != nil {
+		log.Warn().Err(err).Str("model", cfg.Model.Name).Msg("output violates schema again, using it as is")
+		if output.OutputMarkdown == "" {
+			output.OutputMarkdown = text
+		}
+	}
+
+	return retried, output, usage, nil
+}
+
+// apply copies structured output fields into the response.
+func (o jsonOutput) apply(response *Response) {
+	response.Text = o.OutputMarkdown
+	response.TLDR = o.TLDR
+	response.KeyPoints = o.KeyPoints
+	response.Tags = o.Tags
+	response.Language = o.DetectedLanguage
+	response.Confidence = o.Confidence
+}
@@ -0,0 +1,74 @@
+package gpt
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestCompleteStructured(t *testing.T) {
+	const valid = `{"output_markdown": "reply", "tldr": "", "key_points": [], "tags": [], "detected_language": "en", "confidence": 1}`
+
+	type completion struct {
+		text string
+		err  error
+	}
+
+	tests := []struct {
+		name        string
+		completions []completion
+		wantText    string
+		wantUsage   Usage // Each call of complete uses 10 tokens besides the completion, which uses 100 more.
+		wantErr     bool
+	}{
+		{name: "valid", completions: []completion{{text: valid}}, wantText: "reply", wantUsage: Usage{TotalTokens: 10}},
+		{name: "failure", completions: []completion{{err: errors.New("failed")}}, wantUsage: Usage{TotalTokens: 10}, wantErr: true},
+		{
+			name:        "retry",
+			completions: []completion{{text: "not json"}, {text: valid}},
+			wantText:    "reply",
+			wantUsage:   Usage{TotalTokens: 120},
+		},
+		{
+			name:        "invalid again",
+			completions: []completion{{text: "not json"}, {text: "still not json"}},
+			wantText:    "still not json",
+			wantUsage:   Usage{TotalTokens: 120},
+		},
+		{
+			name:        "retry failure",
+			completions: []completion{{text: "not json"}, {err: errors.New("failed")}},
+			wantUsage:   Usage{TotalTokens: 120},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			complete := func() (completion, Usage, error) {
+				c := tt.completions[calls]
+				calls++
+				return c, Usage{TotalTokens: 10}, c.err
+			}
+			outputOf := func(c completion) (string, Usage) {
+				return c.text, Usage{TotalTokens: 100}
+			}
+
+			_, output, usage, err := completeStructured(&gptConfig{}, complete, outputOf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("completeStructured() error = %v, want error %v", err, tt.wantErr)
+			}
+			if calls != len(tt.completions) {
+				t.Errorf("got %d completions, want %d", calls, len(tt.completions))
+			}
+			if output.OutputMarkdown != tt.wantText {
+				t.Errorf("output = %q, want %q", output.OutputMarkdown, tt.wantText)
+			}
+			// Usage of the returned completion itself is added by callers, the rest is returned even on failure.
+			if usage != tt.wantUsage {
+				t.Errorf("usage = %+v, want %+v", usage, tt.wantUsage)
+			}
+		})
+	}
+}
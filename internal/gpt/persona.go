@@ -0,0 +1,177 @@
+package gpt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Persona is a named voice of the bot: a prompt with its own model and tools.
+type Persona struct {
+	Name        string // Persona name, empty for the default persona.
+	Description string // Human-readable description.
+}
+
+// PersonaLister lists personas a request may refer to.
+type PersonaLister interface {
+	// Personas returns available personas, the default one goes first.
+	Personas() []Persona
+}
+
+var (
+	_ PersonaLister = (*GPT)(nil)
+	_ PersonaLister = (*ChatCompletions)(nil)
+)
+
+// gptPersonaConfig is a persona definition.
+// Null values mean "same as the default persona".
+type gptPersonaConfig struct {
+	Description string          `yaml:"description"`
+	Model       *gptModelConfig `yaml:"model"`
+	Tools       *[]string       `yaml:"tools"`
+	Prompt      string          `yaml:"-"`
+
+	promptTemplate *template.Template // Parsed Prompt.
+}
+
+// personaName restricts persona names so that they fit into Telegram callback data.
+var personaName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// Personas returns available personas, the default one goes first.
+func (g *GPT) Personas() []Persona {
+	return g.config.Load().personas()
+}
+
+// Personas returns available personas, the default one goes first.
+func (c *ChatCompletions) Personas() []Persona {
+	return c.config.Load().personas()
+}
+
+func (c *gptConfig) personas() []Persona {
+	result := []Persona{{Description: c.Description}}
+
+	names := make([]string, 0, len(c.Personas))
+	for name := range c.Personas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		result = append(result, Persona{Name: name, Description: c.Personas[name].Description})
+	}
+
+	return result
+}
+
+// withPersona returns config of a persona.
+// Unknown personas (e.g. removed from config) fall back to the default one.
+func (c *gptConfig) withPersona(name string) *gptConfig {
+	if name == "" {
+		return c
+	}
+
+	persona, exists := c.Personas[name]
+	if !exists {
+		log.Warn().Str("persona", name).Msg("unknown persona, using the default one")
+		return c
+	}
+
+	cfg := *c
+	cfg.Prompt = persona.Prompt
+	cfg.promptTemplate = persona.promptTemplate
+	if persona.Model != nil {
+		cfg.Model = *persona.Model
+	}
+	if persona.Tools != nil {
+		cfg.Tools = *persona.Tools
+	}
+	return &cfg
+}
+
+// personasDir returns path to the directory with personas that belongs to gpt.yaml at sourcePath.
+func personasDir(sourcePath string) string {
+	return filepath.Join(filepath.Dir(sourcePath), "personas")
+}
+
+// loadPersonas loads persona definitions from dir.
+// A persona is defined by <name>.yaml file and <name>.md prompt file next to it.
+func loadPersonas(dir string, api api) (map[string]*gptPersonaConfig, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	personas := make(map[string]*gptPersonaConfig, len(paths))
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".yaml")
+		if !personaName.MatchString(name) {
+			return nil, fmt.Errorf("persona name %q must be 1 to 32 lowercase letters, digits, \"-\" or \"_\"", name)
+		}
+
+		persona, err := loadPersona(path, api)
+		if err != nil {
+			return nil, errors.Wrapf(err, "persona %q", name)
+		}
+
+		personas[name] = persona
+	}
+
+	return personas, nil
+}
+
+func loadPersona(path string, api api) (*gptPersonaConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var persona gptPersonaConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&persona)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if persona.Model != nil {
+		if persona.Model.Name == "" {
+			return nil, errors.New("model.name is required")
+		}
+
+		err = persona.Model.validate(api)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if persona.Tools != nil {
+		err = validateTools(*persona.Tools)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	promptPath := strings.TrimSuffix(path, ".yaml") + ".md"
+	prompt, err := os.ReadFile(promptPath)
+	if err != nil {
+		return nil, err
+	}
+
+	persona.Prompt = string(prompt)
+	persona.promptTemplate, err = parsePrompt(filepath.Base(promptPath), persona.Prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &persona, nil
+}
@@ -0,0 +1,117 @@
+package gpt
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadPersonas(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string // Files in the personas directory.
+		wantNames []string
+		wantErr   string
+	}{
+		{name: "none", files: map[string]string{}},
+		{
+			name: "several",
+			files: map[string]string{
+				"formal.yaml":  "description: Formal\n",
+				"formal.md":    "Be formal.",
+				"pirate.yaml":  "model:\n  name: o3\ntools: [calculate]\n",
+				"pirate.md":    "Arr.",
+				"notes.txt":    "Not a persona.",
+				"orphan-md.md": "A prompt without a definition.",
+			},
+			wantNames: []string{"formal", "pirate"},
+		},
+		{name: "invalid name", files: map[string]string{"Formal.yaml": "", "Formal.md": "Be formal."}, wantErr: `persona name "Formal"`},
+		{name: "no prompt", files: map[string]string{"formal.yaml": ""}, wantErr: `persona "formal"`},
+		{name: "unknown key", files: map[string]string{"formal.yaml": "prompt: Be formal.\n", "formal.md": ""}, wantErr: "field prompt not found"},
+		{name: "model without name", files: map[string]string{"formal.yaml": "model:\n  temperature: 1\n", "formal.md": ""}, wantErr: "model.name is required"},
+		{name: "invalid model", files: map[string]string{"formal.yaml": "model:\n  name: o3\n  seed: 1\n", "formal.md": ""}, wantErr: "model.seed"},
+		{name: "unknown tool", files: map[string]string{"formal.yaml": "tools: [shell]\n", "formal.md": ""}, wantErr: `unknown tool "shell"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestConfig(t, dir, tt.files)
+
+			personas, err := loadPersonas(dir, apiResponses)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadPersonas() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadPersonas() error = %v", err)
+			}
+
+			var names []string
+			for name := range personas {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			if !slices.Equal(names, tt.wantNames) {
+				t.Errorf("personas = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestWithPersona(t *testing.T) {
+	path := writeTestConfig(t, t.TempDir(), map[string]string{
+		"gpt.yaml":             "model:\n  name: gpt-4o\ndescription: Default\ntools: [calculate]\n",
+		"PROMPT.md":            "Be brief.",
+		"personas/formal.yaml": "description: Formal\n",
+		"personas/formal.md":   "Be formal.",
+		"personas/pirate.yaml": "description: Pirate\nmodel:\n  name: o3\ntools: []\n",
+		"personas/pirate.md":   "Arr.",
+	})
+
+	cfg, err := loadGTPConfig(path, apiResponses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		persona    string
+		wantPrompt string
+		wantModel  string
+		wantTools  []string
+	}{
+		{persona: "", wantPrompt: "Be brief.", wantModel: "gpt-4o", wantTools: []string{"calculate"}},
+		{persona: "formal", wantPrompt: "Be formal.", wantModel: "gpt-4o", wantTools: []string{"calculate"}},
+		{persona: "pirate", wantPrompt: "Arr.", wantModel: "o3", wantTools: []string{}},
+		{persona: "removed", wantPrompt: "Be brief.", wantModel: "gpt-4o", wantTools: []string{"calculate"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.persona, func(t *testing.T) {
+			got := cfg.withPersona(tt.persona)
+
+			if prompt := got.prompt(Request{Persona: tt.persona}); prompt != tt.wantPrompt {
+				t.Errorf("prompt = %q, want %q", prompt, tt.wantPrompt)
+			}
+			if got.Model.Name != tt.wantModel {
+				t.Errorf("model = %q, want %q", got.Model.Name, tt.wantModel)
+			}
+			if !slices.Equal(got.Tools, tt.wantTools) {
+				t.Errorf("tools = %v, want %v", got.Tools, tt.wantTools)
+			}
+		})
+	}
+
+	// Personas don't change the default config.
+	if cfg.Prompt != "Be brief." || cfg.Model.Name != "gpt-4o" {
+		t.Errorf("default config is modified: prompt %q, model %q", cfg.Prompt, cfg.Model.Name)
+	}
+
+	want := []Persona{{Description: "Default"}, {Name: "formal", Description: "Formal"}, {Name: "pirate", Description: "Pirate"}}
+	if got := cfg.personas(); !slices.Equal(got, want) {
+		t.Errorf("personas() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package gpt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// gptPriceConfig is a model price, in USD per million tokens.
+type gptPriceConfig struct {
+	Input       float64  `yaml:"input"`        // Price of input tokens.
+	CachedInput *float64 `yaml:"cached_input"` // Price of cached input tokens, null means "same as input".
+	Output      float64  `yaml:"output"`       // Price of output tokens.
+	Reasoning   *float64 `yaml:"reasoning"`    // Price of reasoning tokens, null means "same as output".
+	Minute      float64  `yaml:"minute"`       // Price of a minute of audio, for transcription models billed by duration.
+}
+
+// snapshotSuffix matches a suffix of a dated model snapshot, e.g. "-2024-08-06" in "gpt-4o-2024-08-06".
+var snapshotSuffix = regexp.MustCompile(`^-\d{4}(-\d{2}-\d{2})?$`)
+
+func (c *gptPriceConfig) validate(model string) error {
+	if c.Input < 0 || c.Output < 0 || c.Minute < 0 || (c.CachedInput != nil && *c.CachedInput < 0) || (c.Reasoning != nil && *c.Reasoning < 0) {
+		return fmt.Errorf("pricing.%s: prices must be non-negative", model)
+	}
+
+	return nil
+}
+
+// cost returns cost of usage, in USD.
+func (c *gptPriceConfig) cost(usage Usage) float64 {
+	cachedInput := c.Input
+	if c.CachedInput != nil {
+		cachedInput = *c.CachedInput
+	}
+
+	reasoning := c.Output
+	if c.Reasoning != nil {
+		reasoning = *c.Reasoning
+	}
+
+	// Input tokens include cached ones, and output tokens include reasoning ones.
+	total := float64(usage.InputTokens-usage.CachedInputTokens)*c.Input +
+		float64(usage.CachedInputTokens)*cachedInput +
+		float64(usage.OutputTokens-usage.ReasoningTokens)*c.Output +
+		float64(usage.ReasoningTokens)*reasoning
+
+	return total / 1_000_000
+}
+
+// priceFor returns price of a model.
+// Dated snapshots (e.g. "gpt-4o-2024-08-06") are priced as their base model unless they are listed explicitly.
+func (c *gptConfig) priceFor(model string) (gptPriceConfig, bool) {
+	if price, exists := c.Pricing[model]; exists {
+		return price, true
+	}
+
+	for name, price := range c.Pricing {
+		if suffix, found := strings.CutPrefix(model, name); found && snapshotSuffix.MatchString(suffix) {
+			return price, true
+		}
+	}
+
+	return gptPriceConfig{}, false
+}
+
+// cost returns cost of usage of a model, in USD, or zero if the model isn't priced.
+func (c *gptConfig) cost(model string, usage Usage) float64 {
+	if len(c.Pricing) == 0 {
+		return 0
+	}
+
+	price, exists := c.priceFor(model)
+	if !exists {
+		log.Warn().Str("model", model).Msg("model price is unknown")
+		return 0
+	}
+
+	return price.cost(usage)
+}
+
+// transcriptionCost returns cost of a transcription, in USD, or zero if the model isn't priced.
+// Models bill either tokens or seconds of audio, so the other one is zero.
+func (c *gptConfig) transcriptionCost(model string, usage Usage, seconds float64) float64 {
+	if len(c.Pricing) == 0 {
+		return 0
+	}
+
+	price, exists := c.priceFor(model)
+	if !exists {
+		log.Warn().Str("model", model).Msg("model price is unknown")
+		return 0
+	}
+
+	return price.cost(usage) + price.Minute*seconds/60
+}
+
+// withCost sets cost of the response.
+func withCost(cfg *gptConfig, response Response) Response {
+	response.Cost = cfg.cost(response.Model, response.Usage)
+	return response
+}
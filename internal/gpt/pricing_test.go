@@ -0,0 +1,172 @@
+package gpt
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPriceFor(t *testing.T) {
+	cfg := &gptConfig{Pricing: map[string]gptPriceConfig{
+		"gpt-4o":            {Input: 2.5, Output: 10},
+		"gpt-4o-mini":       {Input: 0.15, Output: 0.6},
+		"gpt-4o-2024-05-13": {Input: 5, Output: 15},
+		"o3":                {Input: 2, Output: 8},
+	}}
+
+	tests := []struct {
+		model      string
+		wantInput  float64
+		wantExists bool
+	}{
+		{model: "gpt-4o", wantInput: 2.5, wantExists: true},
+		{model: "gpt-4o-mini", wantInput: 0.15, wantExists: true},
+		{model: "gpt-4o-2024-08-06", wantInput: 2.5, wantExists: true},
+		{model: "gpt-4o-2024-05-13", wantInput: 5, wantExists: true},
+		{model: "gpt-4o-mini-2024-07-18", wantInput: 0.15, wantExists: true},
+		{model: "o3-2025", wantInput: 2, wantExists: true},
+		{model: "o3-pro", wantExists: false},
+		{model: "o3-2025-04", wantExists: false},
+		{model: "gpt-4o-audio-preview", wantExists: false},
+		{model: "gpt-4", wantExists: false},
+		{model: "", wantExists: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			price, exists := cfg.priceFor(tt.model)
+			if exists != tt.wantExists {
+				t.Fatalf("priceFor(%q) exists = %v, want %v", tt.model, exists, tt.wantExists)
+			}
+			if price.Input != tt.wantInput {
+				t.Errorf("priceFor(%q) input = %v, want %v", tt.model, price.Input, tt.wantInput)
+			}
+		})
+	}
+}
+
+func TestPriceCost(t *testing.T) {
+	cached, reasoning := 0.5, 20.0
+
+	tests := []struct {
+		name  string
+		price gptPriceConfig
+		usage Usage
+		want  float64
+	}{
+		{name: "no usage", price: gptPriceConfig{Input: 2, Output: 8}, want: 0},
+		{
+			name:  "input and output",
+			price: gptPriceConfig{Input: 2, Output: 8},
+			usage: Usage{InputTokens: 1_000_000, OutputTokens: 500_000},
+			want:  2 + 4,
+		},
+		{
+			name:  "cached input at input price",
+			price: gptPriceConfig{Input: 2, Output: 8},
+			usage: Usage{InputTokens: 1_000_000, CachedInputTokens: 400_000},
+			want:  2,
+		},
+		{
+			name:  "cached input price",
+			price: gptPriceConfig{Input: 2, CachedInput: &cached, Output: 8},
+			usage: Usage{InputTokens: 1_000_000, CachedInputTokens: 400_000},
+			want:  0.6*2 + 0.4*0.5,
+		},
+		{
+			name:  "reasoning at output price",
+			price: gptPriceConfig{Input: 2, Output: 8},
+			usage: Usage{OutputTokens: 1_000_000, ReasoningTokens: 250_000},
+			want:  8,
+		},
+		{
+			name:  "reasoning price",
+			price: gptPriceConfig{Input: 2, Output: 8, Reasoning: &reasoning},
+			usage: Usage{OutputTokens: 1_000_000, ReasoningTokens: 250_000},
+			want:  0.75*8 + 0.25*20,
+		},
+		{
+			name:  "small request",
+			price: gptPriceConfig{Input: 0.15, Output: 0.6},
+			usage: Usage{InputTokens: 1200, OutputTokens: 300},
+			want:  0.00018 + 0.00018,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.price.cost(tt.usage); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("cost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigCost(t *testing.T) {
+	usage := Usage{InputTokens: 1_000_000, OutputTokens: 1_000_000}
+
+	cfg := &gptConfig{Pricing: map[string]gptPriceConfig{"gpt-4o": {Input: 2.5, Output: 10}}}
+	if got := cfg.cost("gpt-4o-2024-08-06", usage); got != 12.5 {
+		t.Errorf("cost() of a snapshot = %v, want 12.5", got)
+	}
+	if got := cfg.cost("unknown", usage); got != 0 {
+		t.Errorf("cost() of an unknown model = %v, want 0", got)
+	}
+
+	if got := (&gptConfig{}).cost("gpt-4o", usage); got != 0 {
+		t.Errorf("cost() without pricing = %v, want 0", got)
+	}
+}
+
+func TestTranscriptionCost(t *testing.T) {
+	cfg := &gptConfig{Pricing: map[string]gptPriceConfig{
+		"whisper-1":         {Minute: 0.006},
+		"gpt-4o-transcribe": {Input: 6, Output: 10},
+	}}
+
+	tests := []struct {
+		name    string
+		model   string
+		usage   Usage
+		seconds float64
+		want    float64
+	}{
+		{name: "by duration", model: "whisper-1", seconds: 90, want: 0.009},
+		{name: "by tokens", model: "gpt-4o-transcribe", usage: Usage{InputTokens: 1000, OutputTokens: 100}, want: 0.006 + 0.001},
+		{name: "snapshot", model: "gpt-4o-transcribe-2025-03-20", usage: Usage{InputTokens: 1000}, want: 0.006},
+		{name: "unknown model", model: "gpt-4o-mini-transcribe", usage: Usage{InputTokens: 1000}, seconds: 60, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.transcriptionCost(tt.model, tt.usage, tt.seconds); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("transcriptionCost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceValidate(t *testing.T) {
+	negative := -1.0
+
+	tests := []struct {
+		name    string
+		price   gptPriceConfig
+		wantErr bool
+	}{
+		{name: "free", price: gptPriceConfig{}},
+		{name: "valid", price: gptPriceConfig{Input: 1, Output: 2}},
+		{name: "negative input", price: gptPriceConfig{Input: -1}, wantErr: true},
+		{name: "negative output", price: gptPriceConfig{Output: -1}, wantErr: true},
+		{name: "negative cached input", price: gptPriceConfig{CachedInput: &negative}, wantErr: true},
+		{name: "negative reasoning", price: gptPriceConfig{Reasoning: &negative}, wantErr: true},
+		{name: "negative minute", price: gptPriceConfig{Minute: -0.006}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.price.validate("model"); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package gpt
+
+import (
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// User describes a user a request comes from.
+type User struct {
+	FirstName    string // First name.
+	LanguageCode string // IETF language tag, e.g. "ru".
+	ChatType     string // Chat type, e.g. "private" or "group".
+}
+
+// promptData holds variables of a prompt template.
+type promptData struct {
+	User
+	Persona  string    // Persona name, empty for the default persona.
+	Now      time.Time // Current time in the configured timezone.
+	Date     string    // Current date, "2006-01-02".
+	Time     string    // Current time, "15:04".
+	Timezone string    // Configured timezone name.
+}
+
+// parsePrompt parses a prompt template and checks that it can be rendered.
+// Prompts are executed with missingkey=error, so a typo in a variable name fails at config load.
+func parsePrompt(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tmpl.Execute(&strings.Builder{}, newPromptData(time.UTC, Request{}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "prompt %s", name)
+	}
+
+	return tmpl, nil
+}
+
+func newPromptData(location *time.Location, req Request) promptData {
+	now := time.Now().In(location)
+	return promptData{
+		User:     req.User,
+		Persona:  req.Persona,
+		Now:      now,
+		Date:     now.Format(time.DateOnly),
+		Time:     now.Format("15:04"),
+		Timezone: location.String(),
+	}
+}
+
+// location returns the configured timezone.
+func (c *gptConfig) location() *time.Location {
+	if c.timezone == nil {
+		return time.UTC
+	}
+
+	return c.timezone
+}
+
+// prompt renders the system prompt for the request.
+func (c *gptConfig) prompt(req Request) string {
+	if c.promptTemplate == nil {
+		return c.Prompt
+	}
+
+	var prompt strings.Builder
+	err := c.promptTemplate.Execute(&prompt, newPromptData(c.location(), req))
+	if err != nil {
+		// parsePrompt has rendered the prompt with sample data, so variables exist; a raw prompt still beats none.
+		log.Error().Err(err).Msg("failed to render prompt, using it as is")
+		return c.Prompt
+	}
+
+	return prompt.String()
+}
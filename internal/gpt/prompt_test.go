@@ -0,0 +1,86 @@
+package gpt
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPrompt(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{name: "plain", files: map[string]string{"PROMPT.md": "Be brief."}},
+		{
+			name:  "variables",
+			files: map[string]string{"PROMPT.md": "{{.FirstName}} {{.LanguageCode}} {{.ChatType}} {{.Persona}} {{.Date}} {{.Time}} {{.Timezone}} {{.Now.Year}}"},
+		},
+		{name: "conditional", files: map[string]string{"PROMPT.md": `{{if eq .ChatType "private"}}Private{{else}}Group{{end}} chat.`}},
+		{name: "unknown variable", files: map[string]string{"PROMPT.md": "Hi {{.Name}}"}, wantErr: "prompt PROMPT.md"},
+		{name: "unknown field", files: map[string]string{"PROMPT.md": "{{.User.Age}}"}, wantErr: "prompt PROMPT.md"},
+		{name: "syntax error", files: map[string]string{"PROMPT.md": "Hi {{.FirstName"}, wantErr: "PROMPT.md"},
+		{name: "unknown function", files: map[string]string{"PROMPT.md": "{{upper .FirstName}}"}, wantErr: `function "upper" not defined`},
+		{
+			name:  "persona prompt",
+			files: map[string]string{"PROMPT.md": "Be brief.", "personas/formal.yaml": "", "personas/formal.md": "Persona {{.Persona}}."},
+		},
+		{
+			name:    "broken persona prompt",
+			files:   map[string]string{"PROMPT.md": "Be brief.", "personas/formal.yaml": "", "personas/formal.md": "Hi {{.Name}}"},
+			wantErr: `persona "formal": prompt formal.md`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["gpt.yaml"] = "model:\n  name: gpt-4o\n"
+			path := writeTestConfig(t, t.TempDir(), tt.files)
+
+			_, err := loadGTPConfig(path, apiResponses)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("loadGTPConfig() error = %v, want none", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("loadGTPConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigPrompt(t *testing.T) {
+	path := writeTestConfig(t, t.TempDir(), map[string]string{
+		"gpt.yaml":             "model:\n  name: gpt-4o\ntimezone: Asia/Tokyo\n",
+		"PROMPT.md":            "{{.FirstName}} ({{.LanguageCode}}, {{.ChatType}}) at {{.Timezone}}{{if .Persona}} as {{.Persona}}{{end}}.",
+		"personas/formal.yaml": "",
+		"personas/formal.md":   "Formal {{.FirstName}} as {{.Persona}} on {{.Date}}.",
+	})
+
+	cfg, err := loadGTPConfig(path, apiResponses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := User{FirstName: "Alice", LanguageCode: "en", ChatType: "private"}
+	date := time.Now().In(cfg.location()).Format(time.DateOnly)
+
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{name: "default", req: Request{User: user}, want: "Alice (en, private) at Asia/Tokyo."},
+		{name: "no user", req: Request{}, want: " (, ) at Asia/Tokyo."},
+		{name: "persona", req: Request{User: user, Persona: "formal"}, want: "Formal Alice as formal on " + date + "."},
+		{name: "unknown persona", req: Request{User: user, Persona: "pirate"}, want: "Alice (en, private) at Asia/Tokyo as pirate."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.withPersona(tt.req.Persona).prompt(tt.req); got != tt.want {
+				t.Errorf("prompt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
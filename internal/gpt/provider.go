@@ -0,0 +1,139 @@
+package gpt
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Provider is an LLM backend.
+type Provider interface {
+	// Generate generates a reply to the request.
+	// A conversation is continued if request refers to a previous response.
+	Generate(ctx context.Context, req Request) (Response, error)
+
+	// GenerateStream generates a reply like Generate does, but reports partial text as it's being generated.
+	GenerateStream(ctx context.Context, req Request, onUpdate StreamFunc) (Response, error)
+}
+
+// Message is a message in a conversation.
+type Message struct {
+	Participant Participant // Conversation participant.
+	Text        string      // Message text.
+}
+
+// Participant is the side of conversation.
+type Participant int
+
+const (
+	ParticipantBot  Participant = iota // Bot.
+	ParticipantUser                    // User.
+)
+
+// Request is a GPT request.
+// Conversation context is passed either as a previous response ID or as a local history,
+// depending on provider configuration, so callers should pass both.
+type Request struct {
+	Message        string
+	Instruction    string    // User instruction on what to do with the message (e.g. with an attached document), if any.
+	Images         []Image   // Attached images, if any.
+	PrevResponseID string    // ID of the previous response in the conversation, if any.
+	History        []Message // Previous messages in the conversation, as returned in Response.History.
+	Summary        string    // Summary of the earlier conversation, as returned in Response.Summary.
+	Persona        string    // Name of the persona that replies, empty means "the default one".
+	User           User      // User the request comes from, for prompt templates.
+}
+
+// Image is an image attached to a request.
+type Image struct {
+	MIMEType string // Image MIME type, e.g. "image/jpeg".
+	Data     []byte // Image content.
+}
+
+// DataURL returns image encoded as a data URL.
+func (img Image) DataURL() string {
+	return "data:" + img.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+}
+
+// maxHistoryMessageLength is a max length of a message kept in local history.
+// Documents may be quite long, so they are cut to keep follow-up requests small.
+const maxHistoryMessageLength = 2000
+
+// texts returns text parts of the request: an instruction goes before the message.
+func (r Request) texts() []string {
+	var texts []string
+	for _, text := range []string{r.Instruction, r.Message} {
+		if text != "" {
+			texts = append(texts, text)
+		}
+	}
+
+	return texts
+}
+
+// historyText returns text of the request as it's kept in local history.
+func (r Request) historyText() string {
+	message := r.Message
+	if runes := []rune(message); len(runes) > maxHistoryMessageLength {
+		message = string(runes[:maxHistoryMessageLength]) + "…"
+	}
+
+	text := strings.TrimSpace(r.Instruction + "\n\n" + message)
+	if len(r.Images) == 0 {
+		return text
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("%s\n[%d image(s) attached]", text, len(r.Images)))
+}
+
+// Response is a GPT response.
+type Response struct {
+	ID      string
+	Model   string    // Model that generated the response.
+	Text    string    // Transformed text.
+	Usage   Usage     // Token usage.
+	Cost    float64   // Cost of the usage in USD, zero if the model isn't priced.
+	History []Message // Updated local conversation history; nil if conversation is stored by the provider.
+	Summary string    // Summary of the earlier conversation to pass with the next request.
+
+	// Structured output details, empty if the model isn't asked for a structured output.
+	TLDR       string   // One-sentence summary.
+	KeyPoints  []string // Key points.
+	Tags       []string // Topic tags.
+	Language   string   // ISO 639-1 code of the input language.
+	Confidence float64  // Confidence in accuracy of the response, from 0 to 1.
+
+	// Reasoning is a summary of the model's reasoning, empty unless model.reasoning.summary is set.
+	Reasoning string
+
+	// Compaction is set if the conversation has been compacted into a summary.
+	// Compacted conversation starts over, so ID and History refer to an empty conversation.
+	Compaction *Compaction
+}
+
+// Compaction describes a compaction of a long conversation.
+type Compaction struct {
+	TokensBefore int64 // Conversation size that triggered the compaction.
+	Usage        Usage // Token usage of the compaction itself (already included into Response.Usage).
+}
+
+// Usage is a token usage of a single request.
+type Usage struct {
+	InputTokens       int64 // Input tokens, including cached ones.
+	CachedInputTokens int64 // Input tokens served from cache.
+	OutputTokens      int64 // Output tokens, including reasoning ones.
+	ReasoningTokens   int64 // Reasoning tokens.
+	TotalTokens       int64 // Total tokens.
+}
+
+// Add returns sum of two usages.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		InputTokens:       u.InputTokens + other.InputTokens,
+		CachedInputTokens: u.CachedInputTokens + other.CachedInputTokens,
+		OutputTokens:      u.OutputTokens + other.OutputTokens,
+		ReasoningTokens:   u.ReasoningTokens + other.ReasoningTokens,
+		TotalTokens:       u.TotalTokens + other.TotalTokens,
+	}
+}
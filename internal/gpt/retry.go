@@ -0,0 +1,63 @@
+package gpt
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	maxAttempts   = 4                // Max number of attempts of a single request.
+	retryDelay    = time.Second      // Delay before the first retry.
+	maxRetryDelay = 30 * time.Second // Max delay between retries.
+	maxRetryAfter = time.Minute      // Max delay requested by the server that is worth waiting for.
+)
+
+// withRetry calls fn, retrying retryable errors with jittered exponential backoff.
+// Returned errors are classified (see Error).
+func withRetry[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil {
+			return result, nil
+		}
+
+		err = classifyError(err)
+
+		var e *Error
+		if !errors.As(err, &e) {
+			return result, err
+		}
+
+		delay, retry := retryDelayFor(e, attempt)
+		if !retry {
+			return result, err
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt).Dur("delay", delay).Msg("request failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryDelayFor returns a delay before the next attempt, or false if the request shouldn't be retried.
+func retryDelayFor(err *Error, attempt int) (time.Duration, bool) {
+	if !err.Kind.Retryable() || attempt >= maxAttempts {
+		return 0, false
+	}
+
+	if err.RetryAfter > 0 {
+		return err.RetryAfter, err.RetryAfter <= maxRetryAfter
+	}
+
+	// Full jitter on top of a half of the exponential delay keeps retries of concurrent requests apart.
+	delay := min(retryDelay<<(attempt-1), maxRetryDelay)
+	return delay/2 + rand.N(delay/2), true
+}
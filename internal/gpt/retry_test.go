@@ -0,0 +1,118 @@
+package gpt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestRetryDelayFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       *Error
+		attempt   int
+		wantRetry bool
+		wantMin   time.Duration
+		wantMax   time.Duration
+	}{
+		{name: "not retryable", err: &Error{Kind: ErrorAuth}, attempt: 1},
+		{name: "first retry", err: &Error{Kind: ErrorServer}, attempt: 1, wantRetry: true, wantMin: retryDelay / 2, wantMax: retryDelay},
+		{name: "second retry", err: &Error{Kind: ErrorRateLimit}, attempt: 2, wantRetry: true, wantMin: retryDelay, wantMax: 2 * retryDelay},
+		{name: "last attempt", err: &Error{Kind: ErrorServer}, attempt: maxAttempts},
+		{
+			name:      "retry after",
+			err:       &Error{Kind: ErrorRateLimit, RetryAfter: 5 * time.Second},
+			attempt:   1,
+			wantRetry: true,
+			wantMin:   5 * time.Second,
+			wantMax:   5 * time.Second,
+		},
+		{name: "retry after too long", err: &Error{Kind: ErrorRateLimit, RetryAfter: 2 * maxRetryAfter}, attempt: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				delay, retry := retryDelayFor(tt.err, tt.attempt)
+				if retry != tt.wantRetry {
+					t.Fatalf("retryDelayFor() retry = %v, want %v", retry, tt.wantRetry)
+				}
+				if retry && (delay < tt.wantMin || delay > tt.wantMax) {
+					t.Fatalf("retryDelayFor() delay = %v, want between %v and %v", delay, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	transient := &Error{Kind: ErrorServer, RetryAfter: time.Millisecond, Err: errors.New("overloaded")}
+	permanent := &Error{Kind: ErrorInvalidRequest, Err: errors.New("bad request")}
+
+	tests := []struct {
+		name      string
+		errs      []error // Errors of consecutive attempts, then success.
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", wantCalls: 1},
+		{name: "transient", errs: []error{transient, transient}, wantCalls: 3},
+		{name: "permanent", errs: []error{permanent, transient}, wantCalls: 1, wantErr: permanent},
+		{
+			name:      "too many attempts",
+			errs:      []error{transient, transient, transient, transient, transient},
+			wantCalls: maxAttempts,
+			wantErr:   transient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			result, err := withRetry(context.Background(), func() (string, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return "", tt.errs[calls-1]
+				}
+				return "ok", nil
+			})
+
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("withRetry() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && result != "ok" {
+				t.Errorf("withRetry() = %q, want %q", result, "ok")
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	_, err := withRetry(ctx, func() (string, error) {
+		calls++
+		return "", &Error{Kind: ErrorServer, RetryAfter: time.Hour / 2, Err: errors.New("overloaded")}
+	})
+
+	if ErrorKindOf(err) != ErrorServer || calls != 1 {
+		t.Errorf("withRetry() = %v after %d calls, want the first error", err, calls)
+	}
+}
+
+func TestWithRetryClassifies(t *testing.T) {
+	_, err := withRetry(context.Background(), func() (string, error) {
+		return "", testAPIError(401, "", nil)
+	})
+
+	if ErrorKindOf(err) != ErrorAuth {
+		t.Errorf("withRetry() = %v, want an authentication error", err)
+	}
+}
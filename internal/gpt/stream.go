@@ -0,0 +1,189 @@
+package gpt
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/openai/openai-go/v3/responses"
+	"github.com/pkg/errors"
+)
+
+// StreamFunc receives the text generated so far.
+// It's called synchronously from GenerateStream, so it should return quickly.
+type StreamFunc func(text string)
+
+// GenerateStream generates a new message like Generate does, but reports partial text as it's being generated.
+func (g *GPT) GenerateStream(ctx context.Context, req Request, onUpdate StreamFunc) (Response, error) {
+	cfg := g.config.Load().withPersona(req.Persona)
+	return g.cache.do(cfg, req, func() (Response, error) {
+		return withFallback(cfg, func(cfg *gptConfig) (Response, error) {
+			return g.generate(ctx, cfg, req, func(request responses.ResponseNewParams) (*responses.Response, error) {
+				return g.streamResponse(ctx, request, onUpdate)
+			})
+		})
+	})
+}
+
+// streamResponse sends a streaming request, reporting partial text, and returns the completed response.
+func (g *GPT) streamResponse(ctx context.Context, request responses.ResponseNewParams, onUpdate StreamFunc) (*responses.Response, error) {
+	stream := g.client.Responses.NewStreaming(ctx, request)
+	defer func() { _ = stream.Close() }()
+
+	var (
+		raw      strings.Builder
+		lastText string
+	)
+	for stream.Next() {
+		event := stream.Current()
+		switch event.Type {
+		case "response.output_text.delta":
+			raw.WriteString(event.Delta)
+
+			text := partialOutputMarkdown(raw.String())
+			if text != lastText {
+				lastText = text
+				onUpdate(text)
+			}
+
+		case "response.completed":
+			response := event.AsResponseCompleted().Response
+			return &response, nil
+
+		case "response.incomplete":
+			// Output cut short (e.g. by max_completion_tokens) is returned as is, like Responses.New does.
+			response := event.AsResponseIncomplete().Response
+			return &response, nil
+
+		case "response.failed":
+			response := event.AsResponseFailed().Response
+			return nil, responseError(string(response.Error.Code), response.Error.Message)
+
+		case "error":
+			e := event.AsError()
+			return nil, responseError(e.Code, e.Message)
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, errors.New("response stream ended unexpectedly")
+}
+
+// partialOutputMarkdown extracts the value of "output_markdown" field from a (possibly incomplete) JSON output.
+// If the output isn't a JSON object, it's returned as is.
+func partialOutputMarkdown(raw string) string {
+	trimmed := strings.TrimLeft(raw, " \t\r\n")
+	if !strings.HasPrefix(trimmed, "{") {
+		return raw
+	}
+
+	const key = `"output_markdown"`
+	i := strings.Index(trimmed, key)
+	if i < 0 {
+		return ""
+	}
+
+	rest := strings.TrimLeft(trimmed[i+len(key):], " \t\r\n")
+	rest, ok := strings.CutPrefix(rest, ":")
+	if !ok {
+		return ""
+	}
+
+	rest = strings.TrimLeft(rest, " \t\r\n")
+	rest, ok = strings.CutPrefix(rest, `"`)
+	if !ok {
+		return ""
+	}
+
+	return decodePartialJSONString(rest)
+}
+
+// decodePartialJSONString decodes JSON string contents (without the opening quote)
+// up to the closing quote or up to the last complete character.
+func decodePartialJSONString(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			return sb.String()
+
+		case c != '\\':
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && !utf8.FullRuneInString(s[i:]) {
+				return sb.String()
+			}
+			sb.WriteRune(r)
+			i += size
+
+		case i+1 >= len(s):
+			return sb.String()
+
+		default:
+			switch s[i+1] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'u':
+				r, size, ok := decodeUnicodeEscape(s[i:])
+				if !ok {
+					return sb.String()
+				}
+				sb.WriteRune(r)
+				i += size
+				continue
+			default:
+				sb.WriteByte(s[i+1])
+			}
+			i += 2
+		}
+	}
+
+	return sb.String()
+}
+
+// decodeUnicodeEscape decodes a "\uXXXX" escape (or a surrogate pair of them) at the start of s.
+func decodeUnicodeEscape(s string) (rune, int, bool) {
+	const escapeLength = len(`\uXXXX`)
+
+	parse := func(s string) (rune, bool) {
+		if len(s) < escapeLength || s[0] != '\\' || s[1] != 'u' {
+			return 0, false
+		}
+
+		v, err := strconv.ParseUint(s[2:escapeLength], 16, 16)
+		if err != nil {
+			return 0, false
+		}
+
+		return rune(v), true
+	}
+
+	r, ok := parse(s)
+	if !ok {
+		return 0, 0, false
+	}
+
+	if !utf16.IsSurrogate(r) {
+		return r, escapeLength, true
+	}
+
+	r2, ok := parse(s[escapeLength:])
+	if !ok {
+		return 0, 0, false
+	}
+
+	return utf16.DecodeRune(r, r2), 2 * escapeLength, true
+}
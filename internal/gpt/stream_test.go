@@ -0,0 +1,201 @@
+package gpt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/responses"
+)
+
+func TestPartialOutputMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "empty", raw: "", want: ""},
+		{name: "plain text", raw: "Just text", want: "Just text"},
+		{name: "object without field", raw: `{"tldr": "Short`, want: ""},
+		{name: "key only", raw: `{"output_markdown"`, want: ""},
+		{name: "key and colon", raw: `{"output_markdown": `, want: ""},
+		{name: "opening quote", raw: `{"output_markdown": "`, want: ""},
+		{name: "partial value", raw: `{"output_markdown": "Hello, wor`, want: "Hello, wor"},
+		{name: "complete value", raw: `{"output_markdown": "Hello", "tags": ["a"]}`, want: "Hello"},
+		{name: "field after others", raw: `{"tldr": "x", "output_markdown":"Text"`, want: "Text"},
+		{name: "leading whitespace", raw: "\n  {\n  \"output_markdown\" :\n \"Text", want: "Text"},
+		{name: "non-string value", raw: `{"output_markdown": null}`, want: ""},
+		{name: "escapes", raw: `{"output_markdown": "a\nb\tc \"q\" \\ \/"`, want: "a\nb\tc \"q\" \\ /"},
+		{name: "dangling backslash", raw: `{"output_markdown": "line\`, want: "line"},
+		{name: "unicode escape", raw: `{"output_markdown": "\u041f\u0440\u0438\u0432\u0435\u0442"`, want: "Привет"},
+		{name: "partial unicode escape", raw: `{"output_markdown": "ok \u04`, want: "ok "},
+		{name: "surrogate pair", raw: `{"output_markdown": "\ud83d\ude00!"`, want: "😀!"},
+		{name: "partial surrogate pair", raw: `{"output_markdown": "\ud83d\ude`, want: ""},
+		{name: "invalid unicode escape", raw: `{"output_markdown": "a\uZZZZ"`, want: "a"},
+		{name: "multibyte text", raw: `{"output_markdown": "Привет"`, want: "Привет"},
+		{name: "partial multibyte character", raw: `{"output_markdown": "Пр` + "\xd0", want: "Пр"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := partialOutputMarkdown(tt.raw); got != tt.want {
+				t.Errorf("partialOutputMarkdown(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartialOutputMarkdownPrefixes(t *testing.T) {
+	const text = "# Title\n\n- \"quoted\" \\ item\n- <b>Юникод</b> 😀   text"
+
+	raw, err := json.Marshal(map[string]string{"output_markdown": text})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every prefix of the streamed output must decode to a prefix of the final text.
+	for i := range len(raw) + 1 {
+		got := partialOutputMarkdown(string(raw[:i]))
+		if !strings.HasPrefix(text, got) {
+			t.Fatalf("partialOutputMarkdown(%q) = %q, not a prefix of %q", raw[:i], got, text)
+		}
+	}
+
+	if got := partialOutputMarkdown(string(raw)); got != text {
+		t.Errorf("partialOutputMarkdown(%q) = %q, want %q", raw, got, text)
+	}
+}
+
+// testStreamEvent returns a server-sent event of the Responses API.
+func testStreamEvent(eventType, fields string) string {
+	return fmt.Sprintf("event: %s\ndata: {\"type\": %q, \"sequence_number\": 1, %s}\n\n", eventType, eventType, fields)
+}
+
+// testStreamResponse returns a response object with the given status and output text.
+func testStreamResponse(status, text string) string {
+	output, _ := json.Marshal(text)
+	incomplete := "null"
+	if status == "incomplete" {
+		incomplete = `{"reason": "max_output_tokens"}`
+	}
+
+	return fmt.Sprintf(`"response": {"id": "resp_1", "object": "response", "status": %q, "model": "gpt-4o", `+
+		`"incomplete_details": %s, "output": [{"type": "message", "id": "msg_1", "role": "assistant", "status": %q, `+
+		`"content": [{"type": "output_text", "text": %s, "annotations": []}]}], `+
+		`"usage": {"input_tokens": 10, "output_tokens": 5, "total_tokens": 15}}`, status, incomplete, status, output)
+}
+
+// testStreamGPT returns a GPT that streams the given events from a test server.
+func testStreamGPT(t *testing.T, events []string, requests *atomic.Int32) *GPT {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			_, _ = w.Write([]byte(event))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := openai.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("sk-test"), option.WithMaxRetries(0))
+	return &GPT{client: client, config: &configStore{}, cache: newResponseCache(apiResponses)}
+}
+
+func TestStreamResponse(t *testing.T) {
+	const text = `{"output_markdown": "Hello, world"}`
+	deltas := []string{
+		testStreamEvent("response.output_text.delta", `"item_id": "msg_1", "output_index": 0, "content_index": 0, "delta": "{\"output_markdown\": \"Hel"`),
+		testStreamEvent("response.output_text.delta", `"item_id": "msg_1", "output_index": 0, "content_index": 0, "delta": "lo, wor"`),
+	}
+
+	tests := []struct {
+		name        string
+		events      []string
+		wantText    string // Output text of the returned response.
+		wantErr     bool
+		wantKind    ErrorKind
+		wantUpdates []string
+	}{
+		{
+			name:        "completed",
+			events:      append(deltas, testStreamEvent("response.completed", testStreamResponse("completed", text))),
+			wantText:    text,
+			wantUpdates: []string{"Hel", "Hello, wor"},
+		},
+		{
+			name:        "incomplete",
+			events:      append(deltas, testStreamEvent("response.incomplete", testStreamResponse("incomplete", `{"output_markdown": "Hello, wor`))),
+			wantText:    `{"output_markdown": "Hello, wor`,
+			wantUpdates: []string{"Hel", "Hello, wor"},
+		},
+		{
+			name: "failed",
+			events: append(deltas, testStreamEvent("response.failed",
+				`"response": {"id": "resp_1", "object": "response", "status": "failed", "model": "gpt-4o", "output": [], `+
+					`"error": {"code": "rate_limit_exceeded", "message": "Slow down"}}`)),
+			wantErr:     true,
+			wantKind:    ErrorRateLimit,
+			wantUpdates: []string{"Hel", "Hello, wor"},
+		},
+		{
+			name:        "ended unexpectedly",
+			events:      deltas,
+			wantErr:     true,
+			wantUpdates: []string{"Hel", "Hello, wor"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			g := testStreamGPT(t, tt.events, &requests)
+
+			var updates []string
+			response, err := g.streamResponse(context.Background(), responses.ResponseNewParams{Model: "gpt-4o"}, func(text string) {
+				updates = append(updates, text)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("streamResponse() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantKind != ErrorUnknown && ErrorKindOf(err) != tt.wantKind {
+				t.Errorf("error kind = %v, want %v", ErrorKindOf(err), tt.wantKind)
+			}
+			if !tt.wantErr && response.OutputText() != tt.wantText {
+				t.Errorf("output = %q, want %q", response.OutputText(), tt.wantText)
+			}
+			if strings.Join(updates, "|") != strings.Join(tt.wantUpdates, "|") {
+				t.Errorf("updates = %q, want %q", updates, tt.wantUpdates)
+			}
+		})
+	}
+}
+
+func TestGenerateStreamIncomplete(t *testing.T) {
+	// Every attempt is cut short by the token limit, so the output never matches the schema.
+	var requests atomic.Int32
+	g := testStreamGPT(t, []string{
+		testStreamEvent("response.incomplete", testStreamResponse("incomplete", `{"output_markdown": "Partial answer, cut sh`)),
+	}, &requests)
+	g.config.config.Store(&gptConfig{Model: gptModelConfig{Name: "gpt-4o", MaxCompletionTokens: 5}, Conversation: gptConversationConfig{Mode: conversationModeLocal}})
+
+	response, err := g.GenerateStream(context.Background(), Request{Message: "hello"}, func(string) {})
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+
+	if response.Text != "Partial answer, cut sh" {
+		t.Errorf("text = %q, want the partial output", response.Text)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+	if response.Usage.TotalTokens != 2*15 {
+		t.Errorf("usage = %d tokens, want %d", response.Usage.TotalTokens, 2*15)
+	}
+}
@@ -0,0 +1,332 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	RegisterTool(Tool{
+		Name: "calculate",
+		Description: "Evaluates an arithmetic expression. Supports + - * / % ^, parentheses, constants pi and e " +
+			"and functions sqrt, abs, exp, ln, log (base 10), log2, sin, cos, tan, asin, acos, atan, floor, ceil, round, min, max, pow.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"expression": map[string]any{"type": "string", "description": "Expression to evaluate, e.g. \"2 * (3 + 4) ^ 2\"."},
+			},
+			"required": []string{"expression"},
+		},
+		Handler: calculate,
+	})
+}
+
+func calculate(_ context.Context, arguments string) (string, error) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+
+	result, err := evaluate(args.Expression)
+	if err != nil {
+		return "", err
+	}
+
+	return formatNumber(result), nil
+}
+
+// evaluate evaluates an arithmetic expression.
+func evaluate(expression string) (float64, error) {
+	p := calcParser{input: expression}
+
+	result, err := p.expression()
+	if err != nil {
+		return 0, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos:], p.pos)
+	}
+
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+
+	return result, nil
+}
+
+// calcParser is a recursive descent parser of arithmetic expressions:
+//
+//	expression = term { ("+" | "-") term }
+//	term       = unary { ("*" | "/" | "%") unary }
+//	unary      = ("+" | "-") unary | power
+//	power      = primary [ "^" unary ]
+//	primary    = number | name [ "(" expression { "," expression } ")" ] | "(" expression ")"
+type calcParser struct {
+	input string
+	pos   int
+}
+
+func (p *calcParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes c if it's the next character.
+func (p *calcParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] == c {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *calcParser) expression() (float64, error) {
+	left, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		switch {
+		case p.accept('+'):
+			right, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			left += right
+		case p.accept('-'):
+			right, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			left -= right
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *calcParser) term() (float64, error) {
+	left, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		var op byte
+		switch {
+		case p.accept('*'):
+			op = '*'
+		case p.accept('/'):
+			op = '/'
+		case p.accept('%'):
+			op = '%'
+		default:
+			return left, nil
+		}
+
+		right, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case op == '*':
+			left *= right
+		case right == 0:
+			return 0, fmt.Errorf("division by zero")
+		case op == '/':
+			left /= right
+		default:
+			left = math.Mod(left, right)
+		}
+	}
+}
+
+func (p *calcParser) unary() (float64, error) {
+	switch {
+	case p.accept('-'):
+		v, err := p.unary()
+		return -v, err
+	case p.accept('+'):
+		return p.unary()
+	default:
+		return p.power()
+	}
+}
+
+func (p *calcParser) power() (float64, error) {
+	base, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+
+	if !p.accept('^') {
+		return base, nil
+	}
+
+	// Exponentiation is right-associative and binds tighter than unary minus on the left.
+	exponent, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+
+	return math.Pow(base, exponent), nil
+}
+
+func (p *calcParser) primary() (float64, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+
+	c := p.input[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		v, err := p.expression()
+		if err != nil {
+			return 0, err
+		}
+		if !p.accept(')') {
+			return 0, fmt.Errorf("missing closing parenthesis at position %d", p.pos)
+		}
+		return v, nil
+
+	case c == '.' || (c >= '0' && c <= '9'):
+		return p.number()
+
+	case unicode.IsLetter(rune(c)):
+		return p.name()
+	}
+
+	return 0, fmt.Errorf("unexpected %q at position %d", string(c), p.pos)
+}
+
+func (p *calcParser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		exponentSign := (c == '+' || c == '-') && p.pos > start && (p.input[p.pos-1] == 'e' || p.input[p.pos-1] == 'E')
+		if !(c >= '0' && c <= '9') && c != '.' && c != 'e' && c != 'E' && c != '_' && !exponentSign {
+			break
+		}
+		p.pos++
+	}
+
+	v, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+	}
+
+	return v, nil
+}
+
+var calcConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+var calcFunctions = map[string]func(args []float64) (float64, error){
+	"sqrt":  unaryFunc(math.Sqrt),
+	"abs":   unaryFunc(math.Abs),
+	"exp":   unaryFunc(math.Exp),
+	"ln":    unaryFunc(math.Log),
+	"log":   unaryFunc(math.Log10),
+	"log2":  unaryFunc(math.Log2),
+	"sin":   unaryFunc(math.Sin),
+	"cos":   unaryFunc(math.Cos),
+	"tan":   unaryFunc(math.Tan),
+	"asin":  unaryFunc(math.Asin),
+	"acos":  unaryFunc(math.Acos),
+	"atan":  unaryFunc(math.Atan),
+	"floor": unaryFunc(math.Floor),
+	"ceil":  unaryFunc(math.Ceil),
+	"round": unaryFunc(math.Round),
+	"pow": func(args []float64) (float64, error) {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("pow takes 2 arguments, got %d", len(args))
+		}
+		return math.Pow(args[0], args[1]), nil
+	},
+	"min": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("min takes at least 1 argument")
+		}
+		result := args[0]
+		for _, v := range args[1:] {
+			result = math.Min(result, v)
+		}
+		return result, nil
+	},
+	"max": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("max takes at least 1 argument")
+		}
+		result := args[0]
+		for _, v := range args[1:] {
+			result = math.Max(result, v)
+		}
+		return result, nil
+	},
+}
+
+func unaryFunc(fn func(float64) float64) func(args []float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("function takes 1 argument, got %d", len(args))
+		}
+		return fn(args[0]), nil
+	}
+}
+
+func (p *calcParser) name() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos]))) {
+		p.pos++
+	}
+	name := strings.ToLower(p.input[start:p.pos])
+
+	if !p.accept('(') {
+		if v, exists := calcConstants[name]; exists {
+			return v, nil
+		}
+		return 0, fmt.Errorf("unknown constant %q", name)
+	}
+
+	fn, exists := calcFunctions[name]
+	if !exists {
+		return 0, fmt.Errorf("unknown function %q", name)
+	}
+
+	var args []float64
+	if !p.accept(')') {
+		for {
+			v, err := p.expression()
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, v)
+
+			if p.accept(')') {
+				break
+			}
+			if !p.accept(',') {
+				return 0, fmt.Errorf("expected \",\" or \")\" at position %d", p.pos)
+			}
+		}
+	}
+
+	return fn(args)
+}
@@ -0,0 +1,73 @@
+package gpt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+		wantErr    bool
+	}{
+		{expression: "2 + 2", want: "4"},
+		{expression: "2 * (3 + 4) ^ 2", want: "98"},
+		{expression: "1 - 2 - 3", want: "-4"},
+		{expression: "2 ^ 3 ^ 2", want: "512"},
+		{expression: "-2 ^ 2", want: "-4"},
+		{expression: "2 ^ -1", want: "0.5"},
+		{expression: "--3", want: "3"},
+		{expression: "7 % 3 + 8 / 4", want: "3"},
+		{expression: "0.1 + 0.2", want: "0.3"},
+		{expression: ".5 * 1e3 + 2.5E-1", want: "500.25"},
+		{expression: "1_000 * 3", want: "3000"},
+		{expression: "sqrt(16) + abs(-2)", want: "6"},
+		{expression: "max(1, 5, 3) - min(4, 2)", want: "3"},
+		{expression: "pow(2, 10)", want: "1024"},
+		{expression: "round(PI * 100) / 100", want: "3.14"},
+		{expression: "ln(e) + log(1000) + log2(8)", want: "7"},
+		{expression: "floor(2.7) + ceil(2.1)", want: "5"},
+		{expression: "((((1))))", want: "1"},
+		{expression: "  3\t*\n3 ", want: "9"},
+		{expression: "", wantErr: true},
+		{expression: "1 +", wantErr: true},
+		{expression: "(1 + 2", wantErr: true},
+		{expression: "1 + 2)", wantErr: true},
+		{expression: "2 3", wantErr: true},
+		{expression: "1 / 0", wantErr: true},
+		{expression: "5 % 0", wantErr: true},
+		{expression: "sqrt(-1)", wantErr: true},
+		{expression: "10 ^ 400", wantErr: true},
+		{expression: "1.2.3", wantErr: true},
+		{expression: "tau", wantErr: true},
+		{expression: "foo(1)", wantErr: true},
+		{expression: "sqrt(1, 2)", wantErr: true},
+		{expression: "pow(2)", wantErr: true},
+		{expression: "min()", wantErr: true},
+		{expression: "max(1; 2)", wantErr: true},
+		{expression: "2 $ 3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			arguments, _ := json.Marshal(map[string]string{"expression": tt.expression})
+
+			got, err := calculate(context.Background(), string(arguments))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("calculate(%q) error = %v, want error %v", tt.expression, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("calculate(%q) = %q, want %q", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateArguments(t *testing.T) {
+	_, err := calculate(context.Background(), `{"expression": 42}`)
+	if err == nil {
+		t.Error("calculate() accepts a non-string expression")
+	}
+}
@@ -0,0 +1,46 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+	"time"
+	_ "time/tzdata" // Timezone database for minimal container images.
+)
+
+func init() {
+	RegisterTool(Tool{
+		Name:        "current_datetime",
+		Description: "Returns current date, time and day of week.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"timezone": map[string]any{
+					"type":        "string",
+					"description": "IANA timezone name, e.g. \"Europe/Moscow\". Server timezone is used if omitted.",
+				},
+			},
+		},
+		Handler: currentDateTime,
+	})
+}
+
+func currentDateTime(_ context.Context, arguments string) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+
+	location := time.Local
+	if args.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(args.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("unknown timezone %q", args.Timezone)
+		}
+	}
+
+	now := time.Now().In(location)
+	return fmt.Sprintf("%s (%s, %s)", now.Format(time.RFC3339), now.Weekday(), location), nil
+}
@@ -0,0 +1,143 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterTool(Tool{
+		Name: "convert_units",
+		Description: "Converts a value between units of length, mass, volume, temperature, speed, area, time or data size. " +
+			"Units are given by their English names or abbreviations, e.g. \"km\", \"mile\", \"lb\", \"celsius\", \"mph\", \"gib\".",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"value": map[string]any{"type": "number", "description": "Value to convert."},
+				"from":  map[string]any{"type": "string", "description": "Unit to convert from."},
+				"to":    map[string]any{"type": "string", "description": "Unit to convert to."},
+			},
+			"required": []string{"value", "from", "to"},
+		},
+		Handler: convertUnits,
+	})
+}
+
+// unit is a unit of measurement.
+// Value in base units of the dimension is value*factor + offset.
+type unit struct {
+	dimension string
+	factor    float64
+	offset    float64
+}
+
+var units = make(map[string]unit)
+
+func addUnit(dimension string, factor, offset float64, names ...string) {
+	for _, name := range names {
+		units[name] = unit{dimension: dimension, factor: factor, offset: offset}
+	}
+}
+
+func init() {
+	addUnit("length", 1, 0, "m", "meter", "meters", "metre", "metres")
+	addUnit("length", 1e3, 0, "km", "kilometer", "kilometers", "kilometre", "kilometres")
+	addUnit("length", 1e-2, 0, "cm", "centimeter", "centimeters", "centimetre", "centimetres")
+	addUnit("length", 1e-3, 0, "mm", "millimeter", "millimeters", "millimetre", "millimetres")
+	addUnit("length", 1609.344, 0, "mi", "mile", "miles")
+	addUnit("length", 0.9144, 0, "yd", "yard", "yards")
+	addUnit("length", 0.3048, 0, "ft", "foot", "feet")
+	addUnit("length", 0.0254, 0, "in", "inch", "inches")
+	addUnit("length", 1852, 0, "nmi", "nautical mile", "nautical miles")
+
+	addUnit("mass", 1, 0, "kg", "kilogram", "kilograms")
+	addUnit("mass", 1e-3, 0, "g", "gram", "grams")
+	addUnit("mass", 1e-6, 0, "mg", "milligram", "milligrams")
+	addUnit("mass", 1e3, 0, "t", "tonne", "tonnes", "ton", "tons")
+	addUnit("mass", 0.45359237, 0, "lb", "lbs", "pound", "pounds")
+	addUnit("mass", 0.028349523125, 0, "oz", "ounce", "ounces")
+	addUnit("mass", 6.35029318, 0, "st", "stone", "stones")
+
+	addUnit("volume", 1, 0, "l", "liter", "liters", "litre", "litres")
+	addUnit("volume", 1e-3, 0, "ml", "milliliter", "milliliters", "millilitre", "millilitres")
+	addUnit("volume", 1e3, 0, "m3", "cubic meter", "cubic meters")
+	addUnit("volume", 3.785411784, 0, "gal", "gallon", "gallons")
+	addUnit("volume", 0.946352946, 0, "qt", "quart", "quarts")
+	addUnit("volume", 0.473176473, 0, "pt", "pint", "pints")
+	addUnit("volume", 0.2365882365, 0, "cup", "cups")
+	addUnit("volume", 0.0295735295625, 0, "fl oz", "floz", "fluid ounce", "fluid ounces")
+	addUnit("volume", 0.01478676478125, 0, "tbsp", "tablespoon", "tablespoons")
+	addUnit("volume", 0.00492892159375, 0, "tsp", "teaspoon", "teaspoons")
+
+	addUnit("temperature", 1, 0, "c", "°c", "celsius")
+	addUnit("temperature", 1, -273.15, "k", "kelvin")
+	addUnit("temperature", 5.0/9, -32*5.0/9, "f", "°f", "fahrenheit")
+
+	addUnit("speed", 1, 0, "m/s", "mps")
+	addUnit("speed", 1/3.6, 0, "km/h", "kmh", "kph")
+	addUnit("speed", 0.44704, 0, "mph", "mi/h")
+	addUnit("speed", 1852.0/3600, 0, "kn", "knot", "knots")
+	addUnit("speed", 0.3048, 0, "ft/s", "fps")
+
+	addUnit("area", 1, 0, "m2", "square meter", "square meters")
+	addUnit("area", 1e6, 0, "km2", "square kilometer", "square kilometers")
+	addUnit("area", 1e-4, 0, "cm2", "square centimeter", "square centimeters")
+	addUnit("area", 1e4, 0, "ha", "hectare", "hectares")
+	addUnit("area", 4046.8564224, 0, "ac", "acre", "acres")
+	addUnit("area", 0.09290304, 0, "ft2", "square foot", "square feet")
+	addUnit("area", 2589988.110336, 0, "mi2", "square mile", "square miles")
+
+	addUnit("time", 1, 0, "s", "sec", "second", "seconds")
+	addUnit("time", 1e-3, 0, "ms", "millisecond", "milliseconds")
+	addUnit("time", 60, 0, "min", "minute", "minutes")
+	addUnit("time", 3600, 0, "h", "hr", "hour", "hours")
+	addUnit("time", 86400, 0, "d", "day", "days")
+	addUnit("time", 604800, 0, "wk", "week", "weeks")
+	addUnit("time", 31557600, 0, "yr", "year", "years")
+
+	addUnit("data size", 1, 0, "b", "byte", "bytes")
+	addUnit("data size", 0.125, 0, "bit", "bits")
+	addUnit("data size", 1e3, 0, "kb", "kilobyte", "kilobytes")
+	addUnit("data size", 1e6, 0, "mb", "megabyte", "megabytes")
+	addUnit("data size", 1e9, 0, "gb", "gigabyte", "gigabytes")
+	addUnit("data size", 1e12, 0, "tb", "terabyte", "terabytes")
+	addUnit("data size", 1<<10, 0, "kib", "kibibyte", "kibibytes")
+	addUnit("data size", 1<<20, 0, "mib", "mebibyte", "mebibytes")
+	addUnit("data size", 1<<30, 0, "gib", "gibibyte", "gibibytes")
+	addUnit("data size", 1<<40, 0, "tib", "tebibyte", "tebibytes")
+}
+
+func convertUnits(_ context.Context, arguments string) (string, error) {
+	var args struct {
+		Value float64 `json:"value"`
+		From  string  `json:"from"`
+		To    string  `json:"to"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+
+	from, exists := units[strings.ToLower(strings.TrimSpace(args.From))]
+	if !exists {
+		return "", fmt.Errorf("unknown unit %q", args.From)
+	}
+
+	to, exists := units[strings.ToLower(strings.TrimSpace(args.To))]
+	if !exists {
+		return "", fmt.Errorf("unknown unit %q", args.To)
+	}
+
+	if from.dimension != to.dimension {
+		return "", fmt.Errorf("can't convert %s (%s) to %s (%s)", args.From, from.dimension, args.To, to.dimension)
+	}
+
+	result := (args.Value*from.factor + from.offset - to.offset) / to.factor
+	return fmt.Sprintf("%s %s = %s %s", formatNumber(args.Value), args.From, formatNumber(result), args.To), nil
+}
+
+// formatNumber formats a number without excessive digits.
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', 12, 64)
+}
@@ -0,0 +1,56 @@
+package gpt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestConvertUnits(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     string
+		wantErr  bool
+	}{
+		{value: 1, from: "km", to: "m", want: "1 km = 1000 m"},
+		{value: 26.2, from: "miles", to: "km", want: "26.2 miles = 42.1648128 km"},
+		{value: 12, from: "in", to: "ft", want: "12 in = 1 ft"},
+		{value: 1, from: "lb", to: "g", want: "1 lb = 453.59237 g"},
+		{value: 1, from: "gallon", to: "l", want: "1 gallon = 3.785411784 l"},
+		{value: 100, from: "celsius", to: "fahrenheit", want: "100 celsius = 212 fahrenheit"},
+		{value: -40, from: "F", to: "C", want: "-40 F = -40 C"},
+		{value: 0, from: "kelvin", to: "°C", want: "0 kelvin = -273.15 °C"},
+		{value: 32, from: "°f", to: "k", want: "32 °f = 273.15 k"},
+		{value: 36, from: "km/h", to: "m/s", want: "36 km/h = 10 m/s"},
+		{value: 1, from: "ha", to: "m2", want: "1 ha = 10000 m2"},
+		{value: 2, from: "hours", to: "min", want: "2 hours = 120 min"},
+		{value: 1, from: "GiB", to: "MiB", want: "1 GiB = 1024 MiB"},
+		{value: 8, from: "bits", to: "byte", want: "8 bits = 1 byte"},
+		{value: 2, from: " Fluid Ounces ", to: "tbsp", want: "2  Fluid Ounces  = 4 tbsp"},
+		{value: 1, from: "km", to: "kg", wantErr: true},
+		{value: 1, from: "parsec", to: "km", wantErr: true},
+		{value: 1, from: "km", to: "furlong", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			arguments, _ := json.Marshal(map[string]any{"value": tt.value, "from": tt.from, "to": tt.to})
+
+			got, err := convertUnits(context.Background(), string(arguments))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertUnits() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("convertUnits() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertUnitsArguments(t *testing.T) {
+	_, err := convertUnits(context.Background(), `{"value": "one", "from": "km", "to": "m"}`)
+	if err == nil {
+		t.Error("convertUnits() accepts a non-numeric value")
+	}
+}
@@ -0,0 +1,220 @@
+package gpt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/openai/openai-go/v3/responses"
+	"github.com/openai/openai-go/v3/shared"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Tool is a function the model can call.
+type Tool struct {
+	Name        string         // Function name, must be unique.
+	Description string         // What the function does, for the model to decide when to call it.
+	Parameters  map[string]any // JSON schema of function arguments.
+	Handler     ToolHandler    // Function implementation.
+}
+
+// ToolHandler executes a tool call with JSON-encoded arguments.
+// Returned text is passed back to the model.
+type ToolHandler func(ctx context.Context, arguments string) (string, error)
+
+var (
+	toolsMutex sync.RWMutex
+	tools      = make(map[string]Tool)
+)
+
+// RegisterTool makes a tool available to be enabled in gpt.yaml.
+// It panics if a tool with the same name is already registered.
+func RegisterTool(tool Tool) {
+	toolsMutex.Lock()
+	defer toolsMutex.Unlock()
+
+	if _, exists := tools[tool.Name]; exists {
+		panic(fmt.Sprintf("gpt: tool %q is already registered", tool.Name))
+	}
+
+	tools[tool.Name] = tool
+}
+
+func lookupTool(name string) (Tool, bool) {
+	toolsMutex.RLock()
+	defer toolsMutex.RUnlock()
+
+	tool, exists := tools[name]
+	return tool, exists
+}
+
+// toolNames returns names of all registered tools.
+func toolNames() []string {
+	toolsMutex.RLock()
+	defer toolsMutex.RUnlock()
+
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// maxToolRounds is a max number of consecutive tool call rounds in a single request.
+const maxToolRounds = 8
+
+// callTool executes a tool call.
+// Errors are passed back to the model rather than failing the request, so it can try to recover.
+func callTool(ctx context.Context, name, arguments string) string {
+	tool, exists := lookupTool(name)
+	if !exists {
+		log.Error().Str("tool", name).Msg("model called an unknown tool")
+		return fmt.Sprintf("error: unknown tool %q", name)
+	}
+
+	result, err := tool.Handler(ctx, arguments)
+	if err != nil {
+		log.Error().Err(err).Str("tool", name).Str("arguments", arguments).Msg("tool call failed")
+		return "error: " + err.Error()
+	}
+
+	log.Info().Str("tool", name).Str("arguments", arguments).Str("result", result).Msg("called a tool")
+	return result
+}
+
+// decodeArguments decodes JSON-encoded tool call arguments.
+func decodeArguments(arguments string, v any) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+
+	err := json.Unmarshal([]byte(arguments), v)
+	if err != nil {
+		return errors.Wrap(err, "invalid arguments")
+	}
+
+	return nil
+}
+
+// responsesTools returns enabled tools for the Responses API.
+func (c *gptConfig) responsesTools() []responses.ToolUnionParam {
+	var result []responses.ToolUnionParam
+	for _, name := range c.Tools {
+		tool, _ := lookupTool(name)
+		result = append(result, responses.ToolUnionParam{
+			OfFunction: &responses.FunctionToolParam{
+				Name:        tool.Name,
+				Description: param.Opt[string]{Value: tool.Description},
+				Parameters:  tool.Parameters,
+				Strict:      param.Opt[bool]{Value: false},
+			},
+		})
+	}
+
+	return result
+}
+
+// chatTools returns enabled tools for the Chat Completions API.
+func (c *gptConfig) chatTools() []openai.ChatCompletionToolUnionParam {
+	var result []openai.ChatCompletionToolUnionParam
+	for _, name := range c.Tools {
+		tool, _ := lookupTool(name)
+		result = append(result, openai.ChatCompletionFunctionTool(shared.FunctionDefinitionParam{
+			Name:        tool.Name,
+			Description: param.Opt[string]{Value: tool.Description},
+			Parameters:  tool.Parameters,
+		}))
+	}
+
+	return result
+}
+
+// completeWithTools sends the request and then executes tool calls the model makes, feeding results back,
+// until the model replies with a text.
+// Returned usage covers all the requests but the last one.
+func (g *GPT) completeWithTools(
+	ctx context.Context,
+	request responses.ResponseNewParams,
+	send func(request responses.ResponseNewParams) (*responses.Response, error),
+) (*responses.Response, Usage, error) {
+	var usage Usage
+	for round := 0; ; round++ {
+		response, err := withRetry(ctx, func() (*responses.Response, error) {
+			return send(request)
+		})
+		if err != nil {
+			return nil, usage, err
+		}
+
+		var outputs []responses.ResponseInputItemUnionParam
+		for _, item := range response.Output {
+			if item.Type != "function_call" {
+				continue
+			}
+
+			call := item.AsFunctionCall()
+			outputs = append(outputs, responses.ResponseInputItemParamOfFunctionCallOutput(call.CallID, callTool(ctx, call.Name, call.Arguments)))
+		}
+
+		if len(outputs) == 0 {
+			return response, usage, nil
+		}
+
+		if round == maxToolRounds {
+			return nil, usage, errors.New("too many tool calls")
+		}
+
+		usage = usage.Add(convertUsage(response.Usage))
+
+		// Tool calls are kept by OpenAI along with the response, so only their results are sent.
+		request.PreviousResponseID = param.Opt[string]{Value: response.ID}
+		request.Input = responses.ResponseNewParamsInputUnion{OfInputItemList: outputs}
+	}
+}
+
+// completeWithTools sends the request and then executes tool calls the model makes, feeding results back,
+// until the model replies with a text.
+// Returned usage covers all the requests but the last one.
+func (c *ChatCompletions) completeWithTools(
+	ctx context.Context,
+	request openai.ChatCompletionNewParams,
+	send func(request openai.ChatCompletionNewParams) (*openai.ChatCompletion, error),
+) (*openai.ChatCompletion, Usage, error) {
+	var usage Usage
+	for round := 0; ; round++ {
+		completion, err := withRetry(ctx, func() (*openai.ChatCompletion, error) {
+			return send(request)
+		})
+		if err != nil {
+			return nil, usage, err
+		}
+
+		if len(completion.Choices) == 0 {
+			return nil, usage, errors.New("chat completion has no choices")
+		}
+
+		message := completion.Choices[0].Message
+		if len(message.ToolCalls) == 0 {
+			return completion, usage, nil
+		}
+
+		if round == maxToolRounds {
+			return nil, usage, errors.New("too many tool calls")
+		}
+
+		usage = usage.Add(convertChatUsage(completion.Usage))
+
+		request.Messages = append(request.Messages, message.ToParam())
+		for _, call := range message.ToolCalls {
+			request.Messages = append(request.Messages, openai.ToolMessage(callTool(ctx, call.Function.Name, call.Function.Arguments), call.ID))
+		}
+	}
+}
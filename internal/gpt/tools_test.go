@@ -0,0 +1,149 @@
+package gpt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/responses"
+)
+
+// testRegisterTool registers a tool for the duration of the test.
+func testRegisterTool(t *testing.T, tool Tool) {
+	t.Helper()
+
+	RegisterTool(tool)
+	t.Cleanup(func() {
+		toolsMutex.Lock()
+		defer toolsMutex.Unlock()
+		delete(tools, tool.Name)
+	})
+}
+
+// testEchoTool returns a tool that echoes its arguments.
+func testEchoTool() Tool {
+	return Tool{
+		Name: "test_echo",
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			return "echo " + arguments, nil
+		},
+	}
+}
+
+func TestRegisterTool(t *testing.T) {
+	testRegisterTool(t, testEchoTool())
+
+	if _, exists := lookupTool("test_echo"); !exists {
+		t.Fatal("lookupTool() doesn't find a registered tool")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterTool() doesn't panic on a duplicate name")
+		}
+		if tool, _ := lookupTool("test_echo"); tool.Description != "" {
+			t.Error("RegisterTool() has replaced the registered tool")
+		}
+	}()
+	RegisterTool(Tool{Name: "test_echo", Description: "duplicate"})
+}
+
+func TestCompleteWithTools(t *testing.T) {
+	tests := []struct {
+		name      string
+		rounds    int    // Rounds in which the model calls a tool before replying with a text.
+		tool      string // Tool the model calls.
+		wantCalls int    // Requests sent.
+		wantUsage int64  // Tokens used by all the requests but the last one.
+		wantErr   bool
+	}{
+		{name: "no tool calls", tool: "test_echo", wantCalls: 1},
+		{name: "single round", rounds: 1, tool: "test_echo", wantCalls: 2, wantUsage: 10},
+		{name: "max rounds", rounds: maxToolRounds, tool: "test_echo", wantCalls: maxToolRounds + 1, wantUsage: 10 * maxToolRounds},
+		{name: "too many rounds", rounds: maxToolRounds + 1, tool: "test_echo", wantCalls: maxToolRounds + 1, wantUsage: 10 * maxToolRounds, wantErr: true},
+		{name: "unknown tool", rounds: 1, tool: "test_unknown", wantCalls: 2, wantUsage: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRegisterTool(t, testEchoTool())
+			wantOutput := `echo {"n":1}`
+			if tt.tool == "test_unknown" {
+				wantOutput = `error: unknown tool "test_unknown"`
+			}
+
+			t.Run("responses", func(t *testing.T) {
+				calls := 0
+				send := func(request responses.ResponseNewParams) (*responses.Response, error) {
+					calls++
+					if calls > 1 {
+						output := request.Input.OfInputItemList[0].OfFunctionCallOutput
+						if request.PreviousResponseID.Value != fmt.Sprintf("resp_%d", calls-1) || output.Output.OfString.Value != wantOutput {
+							t.Errorf("request %d continues %q with output %q, want resp_%d and %q",
+								calls, request.PreviousResponseID.Value, output.Output.OfString.Value, calls-1, wantOutput)
+						}
+					}
+
+					item := `{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "done"}]}`
+					if calls <= tt.rounds {
+						item = fmt.Sprintf(`{"type": "function_call", "call_id": "call_%d", "name": %q, "arguments": "{\"n\":1}"}`, calls, tt.tool)
+					}
+
+					var response responses.Response
+					err := json.Unmarshal(fmt.Appendf(nil, `{"id": "resp_%d", "output": [%s], "usage": {"total_tokens": 10}}`, calls, item), &response)
+					return &response, err
+				}
+
+				response, usage, err := (&GPT{}).completeWithTools(context.Background(), responses.ResponseNewParams{}, send)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("completeWithTools() error = %v, want error %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && response.OutputText() != "done" {
+					t.Errorf("completeWithTools() text = %q, want done", response.OutputText())
+				}
+				if calls != tt.wantCalls || usage.TotalTokens != tt.wantUsage {
+					t.Errorf("got %d requests using %d tokens, want %d requests using %d tokens", calls, usage.TotalTokens, tt.wantCalls, tt.wantUsage)
+				}
+			})
+
+			t.Run("chat completions", func(t *testing.T) {
+				calls := 0
+				send := func(request openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+					calls++
+					// Every round adds the assistant message with tool calls and a tool message with the result.
+					if want := 1 + 2*(calls-1); len(request.Messages) != want {
+						t.Errorf("request %d has %d messages, want %d", calls, len(request.Messages), want)
+					} else if calls > 1 {
+						result := request.Messages[len(request.Messages)-1].OfTool
+						if result == nil || result.Content.OfString.Value != wantOutput {
+							t.Errorf("request %d ends with %+v, want tool result %q", calls, request.Messages[len(request.Messages)-1], wantOutput)
+						}
+					}
+
+					message := `{"role": "assistant", "content": "done"}`
+					if calls <= tt.rounds {
+						message = fmt.Sprintf(`{"role": "assistant", "content": "", "tool_calls": [{"id": "call_%d", "type": "function", "function": {"name": %q, "arguments": "{\"n\":1}"}}]}`, calls, tt.tool)
+					}
+
+					var completion openai.ChatCompletion
+					err := json.Unmarshal(fmt.Appendf(nil, `{"id": "chat_%d", "choices": [{"index": 0, "message": %s}], "usage": {"total_tokens": 10}}`, calls, message), &completion)
+					return &completion, err
+				}
+
+				request := openai.ChatCompletionNewParams{Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hello")}}
+				completion, usage, err := (&ChatCompletions{}).completeWithTools(context.Background(), request, send)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("completeWithTools() error = %v, want error %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && completion.Choices[0].Message.Content != "done" {
+					t.Errorf("completeWithTools() text = %q, want done", completion.Choices[0].Message.Content)
+				}
+				if calls != tt.wantCalls || usage.TotalTokens != tt.wantUsage {
+					t.Errorf("got %d requests using %d tokens, want %d requests using %d tokens", calls, usage.TotalTokens, tt.wantCalls, tt.wantUsage)
+				}
+			})
+		})
+	}
+}
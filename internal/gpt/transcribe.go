@@ -0,0 +1,110 @@
+package gpt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/rs/zerolog/log"
+)
+
+// Transcriber converts speech to text.
+type Transcriber interface {
+	// Transcribe returns text of the speech recorded in audio.
+	Transcribe(ctx context.Context, audio Audio) (Transcript, error)
+}
+
+// Transcript is a text of the speech recorded in audio.
+type Transcript struct {
+	Text    string
+	Model   string  // Model that transcribed the audio.
+	Usage   Usage   // Token usage, zero for models billed by duration.
+	Seconds float64 // Duration of the audio, for models billed by duration.
+	Cost    float64 // Cost of the transcription, in USD.
+}
+
+// Audio is an audio (or video) recording.
+type Audio struct {
+	Filename string // File name, its extension defines the format.
+	MIMEType string // MIME type, e.g. "audio/ogg".
+	Data     []byte // File content.
+}
+
+var (
+	_ Transcriber = (*GPT)(nil)
+	_ Transcriber = (*ChatCompletions)(nil)
+	_ Transcriber = (*Fake)(nil)
+)
+
+// Transcribe returns text of the speech recorded in audio.
+func (g *GPT) Transcribe(ctx context.Context, audio Audio) (Transcript, error) {
+	return transcribe(ctx, g.client, g.config.Load(), audio)
+}
+
+// Transcribe returns text of the speech recorded in audio.
+func (c *ChatCompletions) Transcribe(ctx context.Context, audio Audio) (Transcript, error) {
+	return transcribe(ctx, c.client, c.config.Load(), audio)
+}
+
+// Transcribe returns a fake transcript of the audio.
+func (f *Fake) Transcribe(ctx context.Context, audio Audio) (Transcript, error) {
+	if err := ctx.Err(); err != nil {
+		return Transcript{}, err
+	}
+
+	text := fmt.Sprintf("Fake transcript of %s (%d bytes).", audio.Filename, len(audio.Data))
+	outputTokens := int64(len(strings.Fields(text)))
+
+	return Transcript{
+		Text:  text,
+		Model: "fake-transcribe",
+		Usage: Usage{
+			InputTokens:  int64(len(audio.Data)),
+			OutputTokens: outputTokens,
+			TotalTokens:  int64(len(audio.Data)) + outputTokens,
+		},
+	}, nil
+}
+
+func transcribe(ctx context.Context, client openai.Client, cfg *gptConfig, audio Audio) (Transcript, error) {
+	result, err := withRetry(ctx, func() (*openai.AudioTranscriptionNewResponseUnion, error) {
+		// File reader is consumed by the request, so params are rebuilt on every attempt.
+		params := openai.AudioTranscriptionNewParams{
+			File:  openai.File(bytes.NewReader(audio.Data), audio.Filename, audio.MIMEType),
+			Model: openai.AudioModel(cfg.Transcription.model()),
+		}
+
+		if cfg.Transcription.Language != "" {
+			params.Language = param.Opt[string]{Value: cfg.Transcription.Language}
+		}
+
+		return client.Audio.Transcriptions.New(ctx, params)
+	})
+	if err != nil {
+		return Transcript{}, err
+	}
+
+	transcript := Transcript{
+		Text:  strings.TrimSpace(result.Text),
+		Model: cfg.Transcription.model(),
+		Usage: Usage{
+			InputTokens:  result.Usage.InputTokens,
+			OutputTokens: result.Usage.OutputTokens,
+			TotalTokens:  result.Usage.TotalTokens,
+		},
+		Seconds: result.Usage.Seconds,
+	}
+	transcript.Cost = cfg.transcriptionCost(transcript.Model, transcript.Usage, transcript.Seconds)
+
+	log.Debug().
+		Str("model", transcript.Model).
+		Int("bytes", len(audio.Data)).
+		Int("length", len(transcript.Text)).
+		Int64("tokens", transcript.Usage.TotalTokens).
+		Float64("seconds", transcript.Seconds).
+		Msg("transcription stats")
+	return transcript, nil
+}
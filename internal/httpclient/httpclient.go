@@ -0,0 +1,58 @@
+// Package httpclient creates HTTP clients for external APIs.
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// FromEnv creates an HTTP client configured by <prefix>_PROXY and <prefix>_TIMEOUT env variables.
+// See New for their meaning.
+func FromEnv(prefix string, defaultTimeout time.Duration) (*http.Client, error) {
+	timeout := defaultTimeout
+	if value := os.Getenv(prefix + "_TIMEOUT"); value != "" {
+		var err error
+		timeout, err = time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("%s_TIMEOUT must be a non-negative duration (e.g. \"30s\"), got %q", prefix, value)
+		}
+	}
+
+	client, err := New(os.Getenv(prefix+"_PROXY"), timeout)
+	if err != nil {
+		return nil, fmt.Errorf("%s_PROXY: %w", prefix, err)
+	}
+
+	return client, nil
+}
+
+// New creates an HTTP client that connects through proxy ("http", "https", "socks5" or "socks5h" URL).
+// Empty proxy means "use HTTP_PROXY, HTTPS_PROXY and NO_PROXY env variables".
+// Timeout limits a request as a whole, including reading a (streamed) response; zero means no limit.
+func New(proxy string, timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, err
+		}
+
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("proxy scheme must be \"http\", \"https\", \"socks5\" or \"socks5h\", got %q", proxyURL.Scheme)
+		}
+
+		if proxyURL.Host == "" {
+			return nil, fmt.Errorf("proxy %q has no host", proxyURL.Redacted())
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
@@ -3,9 +3,12 @@ package storage
 import (
 	"os"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
 )
 
 // Storage stores conversation data.
@@ -30,21 +33,34 @@ func New(filename string) (*Storage, error) {
 	return s, nil
 }
 
-func (s *Storage) GetLastResponseID(userID int64) (string, error) {
-	var lastResponseID string
+// Conversation is a state of conversation with a user.
+type Conversation struct {
+	LastResponseID string        // ID of the last response sent by the bot.
+	History        []gpt.Message // Recent messages, if conversation history is kept locally.
+	Summary        string        // Summary of the earlier conversation.
+}
+
+// GetConversation returns conversation with a user.
+func (s *Storage) GetConversation(userID int64) (Conversation, error) {
+	var result Conversation
 	err := s.do(func(root *RootYAML, save func() error) error {
 		conversation, exists := root.Conversations[userID]
 		if !exists {
 			return nil
 		}
 
-		lastResponseID = conversation.LastResponseID
+		result.LastResponseID = conversation.LastResponseID
+		result.Summary = conversation.Summary
+		for _, message := range conversation.History {
+			result.History = append(result.History, message.toMessage())
+		}
 		return nil
 	})
-	return lastResponseID, err
+	return result, err
 }
 
-func (s *Storage) SetLastResponseID(userID int64, responseID string) error {
+// SetConversation stores conversation with a user.
+func (s *Storage) SetConversation(userID int64, value Conversation) error {
 	return s.do(func(root *RootYAML, save func() error) error {
 		conversation, exists := root.Conversations[userID]
 		if !exists {
@@ -52,11 +68,157 @@ func (s *Storage) SetLastResponseID(userID int64, responseID string) error {
 			root.Conversations[userID] = conversation
 		}
 
-		conversation.LastResponseID = responseID
+		conversation.LastResponseID = value.LastResponseID
+		conversation.Summary = value.Summary
+		conversation.History = nil
+		for _, message := range value.History {
+			conversation.History = append(conversation.History, newMessageYAML(message))
+		}
+		return save()
+	})
+}
+
+// AddCompaction records a compaction of conversation with a user.
+func (s *Storage) AddCompaction(userID int64, compaction gpt.Compaction) error {
+	return s.do(func(root *RootYAML, save func() error) error {
+		conversation, exists := root.Conversations[userID]
+		if !exists {
+			conversation = &ConversationYAML{}
+			root.Conversations[userID] = conversation
+		}
+
+		conversation.Compactions = append(conversation.Compactions, CompactionYAML{
+			Time:         time.Now().UTC(),
+			TokensBefore: compaction.TokensBefore,
+			Tokens:       compaction.Usage.TotalTokens,
+		})
+		if len(conversation.Compactions) > maxCompactions {
+			conversation.Compactions = conversation.Compactions[len(conversation.Compactions)-maxCompactions:]
+		}
+		return save()
+	})
+}
+
+// GetPersona returns name of the persona chosen by a user, empty means "the default one".
+func (s *Storage) GetPersona(userID int64) (string, error) {
+	var result string
+	err := s.do(func(root *RootYAML, save func() error) error {
+		if settings, exists := root.Settings[userID]; exists {
+			result = settings.Persona
+		}
+		return nil
+	})
+	return result, err
+}
+
+// SetPersona stores name of the persona chosen by a user.
+func (s *Storage) SetPersona(userID int64, persona string) error {
+	return s.do(func(root *RootYAML, save func() error) error {
+		settings, exists := root.Settings[userID]
+		if !exists {
+			settings = &SettingsYAML{}
+			root.Settings[userID] = settings
+		}
+
+		settings.Persona = persona
+		return save()
+	})
+}
+
+// maxCompactions limits the number of compactions recorded per conversation.
+const maxCompactions = 10
+
+// UsageRecord is a usage of a model by a user within a day.
+type UsageRecord struct {
+	UserID   int64     // Telegram user ID.
+	Username string    // Telegram username.
+	Day      string    // Date (UTC) in "2006-01-02" format.
+	Model    string    // Model name.
+	Requests int64     // Number of requests.
+	Usage    gpt.Usage // Total token usage.
+	Cost     float64   // Total cost in USD.
+}
+
+// AddUsage adds usage of a model by a user to today's totals.
+func (s *Storage) AddUsage(userID int64, username, model string, usage gpt.Usage, cost float64) error {
+	return s.do(func(root *RootYAML, save func() error) error {
+		user, exists := root.Usage[userID]
+		if !exists {
+			user = &UserUsageYAML{Days: make(map[string]map[string]*UsageYAML)}
+			root.Usage[userID] = user
+		}
+		user.Username = username
+
+		day := time.Now().UTC().Format(time.DateOnly)
+		models, exists := user.Days[day]
+		if !exists {
+			models = make(map[string]*UsageYAML)
+			user.Days[day] = models
+		}
+
+		total, exists := models[model]
+		if !exists {
+			total = &UsageYAML{}
+			models[model] = total
+		}
+
+		total.Requests++
+		total.InputTokens += usage.InputTokens
+		total.CachedInputTokens += usage.CachedInputTokens
+		total.OutputTokens += usage.OutputTokens
+		total.ReasoningTokens += usage.ReasoningTokens
+		total.TotalTokens += usage.TotalTokens
+		total.Cost += cost
 		return save()
 	})
 }
 
+// UserUsage returns usage records of a user since a day (UTC) in "2006-01-02" format, inclusive.
+func (s *Storage) UserUsage(userID int64, since string) ([]UsageRecord, error) {
+	records, err := s.Usage()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []UsageRecord
+	for _, record := range records {
+		if record.UserID == userID && record.Day >= since {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+// Usage returns all usage records.
+func (s *Storage) Usage() ([]UsageRecord, error) {
+	var result []UsageRecord
+	err := s.do(func(root *RootYAML, save func() error) error {
+		for userID, user := range root.Usage {
+			for day, models := range user.Days {
+				for model, total := range models {
+					result = append(result, UsageRecord{
+						UserID:   userID,
+						Username: user.Username,
+						Day:      day,
+						Model:    model,
+						Requests: total.Requests,
+						Usage: gpt.Usage{
+							InputTokens:       total.InputTokens,
+							CachedInputTokens: total.CachedInputTokens,
+							OutputTokens:      total.OutputTokens,
+							ReasoningTokens:   total.ReasoningTokens,
+							TotalTokens:       total.TotalTokens,
+						},
+						Cost: total.Cost,
+					})
+				}
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
 func (s *Storage) do(fn func(root *RootYAML, save func() error) error) error {
 	// A global lock is a terrible idea, but for this pet project it should be OK.00
 	s.mutex.Lock()
@@ -102,6 +264,14 @@ func (s *Storage) load() (*RootYAML, error) {
 		root.Conversations = make(map[int64]*ConversationYAML)
 	}
 
+	if root.Usage == nil {
+		root.Usage = make(map[int64]*UserUsageYAML)
+	}
+
+	if root.Settings == nil {
+		root.Settings = make(map[int64]*SettingsYAML)
+	}
+
 	return &root, nil
 }
 
@@ -121,10 +291,68 @@ func (s *Storage) store(root *RootYAML) error {
 
 // RootYAML is a YAML model for data root.
 type RootYAML struct {
-	Conversations map[int64]*ConversationYAML `yaml:"conversations"` // Conversations.
+	Conversations map[int64]*ConversationYAML `yaml:"conversations"`      // Conversations.
+	Usage         map[int64]*UserUsageYAML    `yaml:"usage,omitempty"`    // Usage totals by user.
+	Settings      map[int64]*SettingsYAML     `yaml:"settings,omitempty"` // User settings.
+}
+
+// SettingsYAML is a YAML model for user settings.
+type SettingsYAML struct {
+	Persona string `yaml:"persona,omitempty"` // Name of the chosen persona, empty means "the default one".
+}
+
+// UserUsageYAML is a YAML model for usage totals of a user.
+type UserUsageYAML struct {
+	Username string                           `yaml:"username"` // Telegram username.
+	Days     map[string]map[string]*UsageYAML `yaml:"days"`     // Usage totals by day (UTC) and model.
+}
+
+// UsageYAML is a YAML model for usage totals.
+type UsageYAML struct {
+	Requests          int64   `yaml:"requests"`            // Number of requests.
+	InputTokens       int64   `yaml:"input_tokens"`        // Input tokens, including cached ones.
+	CachedInputTokens int64   `yaml:"cached_input_tokens"` // Input tokens served from cache.
+	OutputTokens      int64   `yaml:"output_tokens"`       // Output tokens, including reasoning ones.
+	ReasoningTokens   int64   `yaml:"reasoning_tokens"`    // Reasoning tokens.
+	TotalTokens       int64   `yaml:"total_tokens"`        // Total tokens.
+	Cost              float64 `yaml:"cost"`                // Cost in USD.
 }
 
 // ConversationYAML is a YAML model for conversation.
 type ConversationYAML struct {
-	LastResponseID string `yaml:"last_response_id"` // ID of the last response sent by the bot.
+	LastResponseID string           `yaml:"last_response_id"`      // ID of the last response sent by the bot.
+	History        []MessageYAML    `yaml:"history,omitempty"`     // Recent messages, if conversation history is kept locally.
+	Summary        string           `yaml:"summary,omitempty"`     // Summary of the earlier conversation.
+	Compactions    []CompactionYAML `yaml:"compactions,omitempty"` // Recent compactions of the conversation.
+}
+
+// CompactionYAML is a YAML model for conversation compaction.
+type CompactionYAML struct {
+	Time         time.Time `yaml:"time"`          // Time of compaction.
+	TokensBefore int64     `yaml:"tokens_before"` // Conversation size that triggered the compaction.
+	Tokens       int64     `yaml:"tokens"`        // Tokens spent on the compaction.
+}
+
+// MessageYAML is a YAML model for conversation message.
+type MessageYAML struct {
+	Participant string `yaml:"participant"` // Either "user" or "bot".
+	Text        string `yaml:"text"`        // Message text.
+}
+
+func newMessageYAML(message gpt.Message) MessageYAML {
+	participant := "bot"
+	if message.Participant == gpt.ParticipantUser {
+		participant = "user"
+	}
+
+	return MessageYAML{Participant: participant, Text: message.Text}
+}
+
+func (m MessageYAML) toMessage() gpt.Message {
+	participant := gpt.ParticipantBot
+	if m.Participant == "user" {
+		participant = gpt.ParticipantUser
+	}
+
+	return gpt.Message{Participant: participant, Text: m.Text}
 }
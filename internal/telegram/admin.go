@@ -0,0 +1,65 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+	"github.com/kapitanov/gptbot/internal/telegram/texts"
+)
+
+func (tg *Telegram) onKeysCommand(ctx telebot.Context) error {
+	msg := ctx.Message()
+
+	if !tg.isAdmin(msg) {
+		return nil
+	}
+
+	text := texts.NoKeys
+	if reporter, ok := tg.gpt.(gpt.KeyStatusReporter); ok {
+		text = keysText(reporter.KeyStatus())
+	}
+
+	_, err := tg.bot.Send(msg.Sender, text)
+	if err != nil {
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to send keys status")
+		return err
+	}
+
+	return nil
+}
+
+// keysText formats API key statuses for admins.
+func keysText(statuses []gpt.KeyStatus) string {
+	var sb strings.Builder
+	sb.WriteString(texts.Keys)
+
+	for i, status := range statuses {
+		_, _ = fmt.Fprintf(&sb, "\n\n%d. %s", i+1, status.Key)
+		if status.Organization != "" {
+			_, _ = fmt.Fprintf(&sb, " (org %s)", status.Organization)
+		}
+		if status.Project != "" {
+			_, _ = fmt.Fprintf(&sb, " (project %s)", status.Project)
+		}
+
+		if status.BenchedUntil.IsZero() {
+			sb.WriteString("\nstatus: ok")
+		} else {
+			_, _ = fmt.Fprintf(&sb, "\nstatus: benched for %s (%s)",
+				time.Until(status.BenchedUntil).Round(time.Second), status.LastError)
+		}
+
+		_, _ = fmt.Fprintf(&sb, "\nrequests: %d, rate limits: %d, auth errors: %d",
+			status.Requests, status.RateLimits, status.AuthErrors)
+		if !status.LastRateLimited.IsZero() {
+			_, _ = fmt.Fprintf(&sb, "\nlast rate limited: %s", status.LastRateLimited.Format(time.DateTime))
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,160 @@
+package telegram
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// telegramConfig is a telegram bot config.
+type telegramConfig struct {
+	Quotas quotasConfig `yaml:"quotas"`
+	Reply  replyConfig  `yaml:"reply"`
+}
+
+// replyConfig defines how replies look.
+type replyConfig struct {
+	Template      string `yaml:"template"`       // Go template of a reply in Markdown, empty means "{{.Text}}".
+	ShowReasoning bool   `yaml:"show_reasoning"` // Send the model's reasoning summary (if any) after the reply.
+
+	template *template.Template // Parsed Template.
+}
+
+// quotasConfig defines how much users may consume.
+type quotasConfig struct {
+	Default quotaConfig                `yaml:"default"` // Quota of every user.
+	Users   map[string]userQuotaConfig `yaml:"users"`   // Quota overrides by username or user ID.
+}
+
+// quotaConfig is a quota of a user.
+type quotaConfig struct {
+	Daily   quotaLimits `yaml:"daily"`   // Limits per day (UTC).
+	Monthly quotaLimits `yaml:"monthly"` // Limits per month (UTC).
+}
+
+// userQuotaConfig is a quota override. Limits that are set replace the default ones as a whole.
+type userQuotaConfig struct {
+	Daily   *quotaLimits `yaml:"daily"`
+	Monthly *quotaLimits `yaml:"monthly"`
+}
+
+// quotaLimits are limits of consumption within a period. Zero values mean "unlimited".
+type quotaLimits struct {
+	Messages int64   `yaml:"messages"` // Max number of replies.
+	Tokens   int64   `yaml:"tokens"`   // Max number of tokens.
+	Cost     float64 `yaml:"cost"`     // Max cost in USD.
+}
+
+// loadConfig loads config from TELEGRAM_CONFIG_PATH (or ./conf/telegram.yaml).
+// A missing default config means "no limits".
+func loadConfig() (*telegramConfig, error) {
+	const defaultSourcePath = "./conf/telegram.yaml"
+	sourcePath := os.Getenv("TELEGRAM_CONFIG_PATH")
+	if sourcePath == "" {
+		sourcePath = defaultSourcePath
+	}
+
+	var cfg telegramConfig
+	raw, err := os.ReadFile(sourcePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && sourcePath == defaultSourcePath {
+			log.Warn().Str("path", sourcePath).Msg("telegram config not found, using defaults")
+			return &cfg, nil
+		}
+
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to parse telegram config %s: %w", sourcePath, err)
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid telegram config %s: %w", sourcePath, err)
+	}
+
+	if cfg.Reply.Template != "" {
+		cfg.Reply.template, err = parseLayout(cfg.Reply.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply.template in telegram config %s: %w", sourcePath, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+func (c *telegramConfig) validate() error {
+	err := c.Quotas.Default.Daily.validate("quotas.default.daily")
+	if err != nil {
+		return err
+	}
+
+	err = c.Quotas.Default.Monthly.validate("quotas.default.monthly")
+	if err != nil {
+		return err
+	}
+
+	for user, quota := range c.Quotas.Users {
+		if quota.Daily != nil {
+			err = quota.Daily.validate(fmt.Sprintf("quotas.users[%s].daily", user))
+			if err != nil {
+				return err
+			}
+		}
+
+		if quota.Monthly != nil {
+			err = quota.Monthly.validate(fmt.Sprintf("quotas.users[%s].monthly", user))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *quotaLimits) validate(path string) error {
+	if l.Messages < 0 || l.Tokens < 0 || l.Cost < 0 {
+		return fmt.Errorf("%s: limits must be non-negative", path)
+	}
+
+	return nil
+}
+
+// quotaFor returns quota of a user.
+func (c *quotasConfig) quotaFor(id int64, username string) quotaConfig {
+	quota := c.Default
+
+	override, exists := c.Users[strconv.FormatInt(id, 10)]
+	if !exists && username != "" {
+		for name, value := range c.Users {
+			if strings.TrimPrefix(name, "@") == username {
+				override, exists = value, true
+				break
+			}
+		}
+	}
+	if !exists {
+		return quota
+	}
+
+	if override.Daily != nil {
+		quota.Daily = *override.Daily
+	}
+	if override.Monthly != nil {
+		quota.Monthly = *override.Monthly
+	}
+	return quota
+}
@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/extract"
+)
+
+const (
+	maxDocumentPages = 50      // Max number of pages to extract from a document.
+	maxDocumentChars = 100_000 // Max number of characters to extract from a document.
+)
+
+func (tg *Telegram) onDocument(ctx telebot.Context) error {
+	msg := ctx.Message()
+
+	caption := msg.Document.Caption
+	if caption == "" {
+		caption = msg.Caption
+	}
+
+	if extract.DetectType(msg.Document.MIME, msg.Document.FileName) == "" {
+		return tg.generate(msg, caption, "")
+	}
+
+	if !tg.hasAccess(msg) || !tg.checkQuota(msg) {
+		return nil
+	}
+
+	text, err := tg.extractDocument(msg, msg.Document)
+	if err != nil {
+		log.Error().Err(err).
+			Str("username", msg.Sender.Username).
+			Int("msg", msg.ID).
+			Str("filename", msg.Document.FileName).
+			Msg("failed to extract document text")
+
+		_, err := tg.bot.Reply(msg, failureText(err))
+		if err != nil {
+			log.Error().Err(err).
+				Str("username", msg.Sender.Username).
+				Int("msg", msg.ID).
+				Msg("failed to send error message")
+		}
+		return err
+	}
+
+	return tg.transform(msg, input{Text: text, Instruction: caption})
+}
+
+// extractDocument downloads a document and extracts its text.
+func (tg *Telegram) extractDocument(msg *telebot.Message, doc *telebot.Document) (string, error) {
+	if doc.FileSize > maxDownloadSize {
+		return "", fmt.Errorf("document is too large: %d bytes", doc.FileSize)
+	}
+
+	err := tg.bot.Notify(msg.Sender, telebot.Typing)
+	if err != nil {
+		log.Error().Err(err).
+			Str("username", msg.Sender.Username).
+			Int("msg", msg.ID).
+			Msg("failed to send typing notification")
+	}
+
+	data, err := tg.downloadFile(&doc.File, maxDownloadSize)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := extract.Text(extract.Request{
+		Data:     data,
+		MIMEType: doc.MIME,
+		Filename: doc.FileName,
+		MaxPages: maxDocumentPages,
+		MaxChars: maxDocumentChars,
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to read %q: %w", doc.FileName, err)
+	}
+
+	if result.Text == "" {
+		return "", fmt.Errorf("document %q has no text", doc.FileName)
+	}
+
+	log.Info().
+		Str("username", msg.Sender.Username).
+		Int("msg", msg.ID).
+		Str("filename", doc.FileName).
+		Int("bytes", len(data)).
+		Int("chars", len([]rune(result.Text))).
+		Bool("truncated", result.Truncated).
+		Msg("extracted document text")
+
+	return extractedText("Document", doc.FileName, result), nil
+}
+
+// extractedText returns extracted text prefixed with its origin and title, so the model knows what it's looking at.
+func extractedText(kind, name string, result extract.Result) string {
+	header := fmt.Sprintf("[%s: %s]", kind, name)
+	if result.Title != "" {
+		header += fmt.Sprintf("\n[Title: %s]", result.Title)
+	}
+
+	text := header + "\n\n" + result.Text
+	if result.Truncated {
+		text += "\n\n[Text is truncated]"
+	}
+
+	return text
+}
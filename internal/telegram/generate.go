@@ -2,27 +2,47 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/kapitanov/gptbot/internal/gpt"
+	"github.com/kapitanov/gptbot/internal/storage"
 	"github.com/kapitanov/gptbot/internal/telegram/mdparser"
 	"github.com/kapitanov/gptbot/internal/telegram/texts"
 	"github.com/rs/zerolog/log"
 	"gopkg.in/telebot.v4"
-	"gopkg.in/telebot.v4/react"
 )
 
-func (tg *Telegram) generate(msg *telebot.Message, text, altText string) error {
-	if !tg.hasAccess(msg) {
-		return nil
-	}
+// input is a user input to be transformed.
+type input struct {
+	Text        string      // Message text.
+	Instruction string      // Instruction on what to do with the text, if any.
+	Images      []gpt.Image // Attached images.
+	Sources     []source    // Fetched links the text comes from.
+}
 
+func (tg *Telegram) generate(msg *telebot.Message, text, altText string) error {
 	if text == "" {
 		text = altText
 	}
 
-	if text == "" {
+	return tg.process(msg, input{Text: text})
+}
+
+// process checks access and quota, then transforms the input.
+func (tg *Telegram) process(msg *telebot.Message, in input) error {
+	if !tg.hasAccess(msg) || !tg.checkQuota(msg) {
+		return nil
+	}
+
+	return tg.transform(msg, in)
+}
+
+// transform replies to an input of a user that has already passed access and quota checks.
+// Handlers that download or fetch content check the quota before doing so and then call transform directly.
+func (tg *Telegram) transform(msg *telebot.Message, in input) error {
+	if in.Text == "" && len(in.Images) == 0 {
 		if msg.AlbumID != "" {
 			return nil
 		}
@@ -42,15 +62,16 @@ func (tg *Telegram) generate(msg *telebot.Message, text, altText string) error {
 		return err
 	}
 
-	err := tg.generateE(msg, text)
+	err := tg.generateE(msg, in)
 	if err != nil {
 		log.Error().Err(err).
 			Str("username", msg.Sender.Username).
 			Int("msg", msg.ID).
-			Str("text", text).
+			Str("text", in.Text).
+			Int("images", len(in.Images)).
 			Msg("failed to process")
 
-		_, err := tg.bot.Reply(msg, fmt.Sprintf("%s\n%s", texts.Failure, err.Error()))
+		_, err := tg.bot.Reply(msg, failureText(err))
 		if err != nil {
 			log.Error().Err(err).
 				Str("username", msg.Sender.Username).
@@ -62,33 +83,31 @@ func (tg *Telegram) generate(msg *telebot.Message, text, altText string) error {
 	return nil
 }
 
-func (tg *Telegram) generateE(msg *telebot.Message, request string) error {
-	request = normalizeText(request)
-	if request == "" {
+func (tg *Telegram) generateE(msg *telebot.Message, in input) error {
+	request := normalizeText(in.Text)
+	if request == "" && len(in.Images) == 0 {
 		return nil
 	}
 
-	lastResponseID, err := tg.storage.GetLastResponseID(msg.Sender.ID)
+	conversation, err := tg.storage.GetConversation(msg.Sender.ID)
 	if err != nil {
-		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to get last response id")
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to get conversation")
 		return err
 	}
 
-	// reply, err := tg.bot.Reply(msg, texts.Thinking, telebot.Silent)
-	// if err != nil {
-	// 	log.Error().Err(err).
-	// 		Str("username", msg.Sender.Username).
-	// 		Int("msg", msg.ID).
-	// 		Msg("failed to reply")
-	// 	return err
-	// }
+	persona, err := tg.storage.GetPersona(msg.Sender.ID)
+	if err != nil {
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to get persona")
+		return err
+	}
 
-	err = tg.bot.React(msg.Sender, msg, react.React(react.ThinkingFace))
+	placeholder, err := tg.bot.Reply(msg, texts.Thinking, telebot.Silent)
 	if err != nil {
 		log.Error().Err(err).
 			Str("username", msg.Sender.Username).
 			Int("msg", msg.ID).
-			Msg("failed to send reaction")
+			Msg("failed to reply")
+		return err
 	}
 
 	err = tg.bot.Notify(msg.Sender, telebot.Typing)
@@ -99,12 +118,37 @@ func (tg *Telegram) generateE(msg *telebot.Message, request string) error {
 			Msg("failed to send typing notification")
 	}
 
-	response, err := tg.gpt.Generate(context.Background(), gpt.Request{Message: request, PrevResponseID: lastResponseID})
+	streaming := tg.startStreamingReply(placeholder)
+	response, err := tg.gpt.GenerateStream(context.Background(), gpt.Request{
+		Message:        request,
+		Instruction:    strings.TrimSpace(in.Instruction),
+		Images:         in.Images,
+		PrevResponseID: conversation.LastResponseID,
+		History:        conversation.History,
+		Summary:        conversation.Summary,
+		Persona:        persona,
+		User: gpt.User{
+			FirstName:    msg.Sender.FirstName,
+			LanguageCode: msg.Sender.LanguageCode,
+			ChatType:     string(msg.Chat.Type),
+		},
+	}, streaming.Update)
+	streaming.Stop()
 	if err != nil {
+		tg.deletePlaceholder(msg, placeholder)
 		return err
 	}
 
-	err = tg.reply(msg, response)
+	// Tokens are spent even if the reply can't be delivered.
+	err = tg.storage.AddUsage(msg.Sender.ID, msg.Sender.Username, response.Model, response.Usage, response.Cost)
+	if err != nil {
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to store usage")
+	}
+
+	reply := response
+	reply.Text = tg.config.Reply.layout(response) + sourcesText(in.Sources)
+
+	err = tg.reply(msg, placeholder, reply)
 	if err != nil {
 		log.Error().Err(err).
 			Str("username", msg.Sender.Username).
@@ -115,31 +159,66 @@ func (tg *Telegram) generateE(msg *telebot.Message, request string) error {
 		return err
 	}
 
-	err = tg.storage.SetLastResponseID(msg.Sender.ID, response.ID)
+	if tg.config.Reply.ShowReasoning && response.Reasoning != "" {
+		tg.replyReasoning(msg, response.Reasoning)
+	}
+
+	err = tg.storage.SetConversation(msg.Sender.ID, storage.Conversation{
+		LastResponseID: response.ID,
+		History:        response.History,
+		Summary:        response.Summary,
+	})
 	if err != nil {
-		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to get last response id")
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to store conversation")
 		return err
 	}
 
+	if response.Compaction != nil {
+		err = tg.storage.AddCompaction(msg.Sender.ID, *response.Compaction)
+		if err != nil {
+			log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to store compaction")
+		}
+	}
+
 	log.Info().
 		Str("username", msg.Sender.Username).
 		Int("msg", msg.ID).
-		Str("last", lastResponseID).
+		Str("last", conversation.LastResponseID).
 		Str("request", request).
+		Str("instruction", in.Instruction).
+		Int("images", len(in.Images)).
 		Str("response", response.Text).
+		Str("persona", persona).
+		Str("model", response.Model).
 		Int64("tokens", response.Usage.TotalTokens).
+		Int64("reasoning_tokens", response.Usage.ReasoningTokens).
+		Float64("cost", response.Cost).
 		Msg("generated a reply")
 
 	return nil
 }
 
-func (tg *Telegram) reply(msg *telebot.Message, response gpt.Response) error {
+// reply replaces placeholder with the first chunk of the response and sends the rest as new messages.
+func (tg *Telegram) reply(msg, placeholder *telebot.Message, response gpt.Response) error {
 	const maxTextLength = 4096 - 1
 
 	transformResult := mdparser.Transform(mdparser.TransformRequest{Text: response.Text, MaxLength: maxTextLength})
+	if len(transformResult.Chunks) == 0 {
+		tg.deletePlaceholder(msg, placeholder)
+		return nil
+	}
 
-	for _, chunk := range transformResult.Chunks {
-		_, err := tg.bot.Reply(msg, chunk.Text, telebot.Silent, telebot.ModeMarkdownV2)
+	for i, chunk := range transformResult.Chunks {
+		var err error
+		if i == 0 {
+			_, err = tg.bot.Edit(placeholder, chunk.Text, telebot.ModeMarkdownV2)
+			if isNotModified(err) {
+				// The streamed preview already shows exactly this text.
+				err = nil
+			}
+		} else {
+			_, err = tg.bot.Reply(msg, chunk.Text, telebot.Silent, telebot.ModeMarkdownV2)
+		}
 		if err != nil {
 			log.Error().Err(err).
 				Str("username", msg.Sender.Username).
@@ -152,6 +231,40 @@ func (tg *Telegram) reply(msg *telebot.Message, response gpt.Response) error {
 	return nil
 }
 
+// isNotModified returns true if an edit was rejected because the message already has the new content.
+func isNotModified(err error) bool {
+	return errors.Is(err, telebot.ErrSameMessageContent) || errors.Is(err, telebot.ErrMessageNotModified)
+}
+
+func (tg *Telegram) deletePlaceholder(msg, placeholder *telebot.Message) {
+	err := tg.bot.Delete(placeholder)
+	if err != nil {
+		log.Error().Err(err).
+			Str("username", msg.Sender.Username).
+			Int("msg", msg.ID).
+			Msg("failed to delete placeholder")
+	}
+}
+
+// failureText returns a message explaining the error to the user.
+// Errors that users can't do anything about are reported as is.
+func failureText(err error) string {
+	switch gpt.ErrorKindOf(err) {
+	case gpt.ErrorRateLimit:
+		return texts.RateLimited
+	case gpt.ErrorQuotaExceeded:
+		return texts.QuotaExceeded
+	case gpt.ErrorContextTooLong:
+		return texts.ContextTooLong
+	case gpt.ErrorServer:
+		return texts.ServerError
+	case gpt.ErrorInvalidRequest:
+		return texts.InvalidRequest
+	default:
+		return fmt.Sprintf("%s\n%s", texts.Failure, err.Error())
+	}
+}
+
 func normalizeText(text string) string {
 	text = strings.TrimSpace(text)
 	if len(text) == 0 {
@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+	"github.com/kapitanov/gptbot/internal/telegram/texts"
+)
+
+func TestGenerate(t *testing.T) {
+	api := &botAPI{}
+	tg := newTestTelegram(t, api)
+
+	turns := []struct {
+		text    string // Message text.
+		request string // Normalized message text sent to the model.
+		reply   string // Echoed message in the reply, in MarkdownV2.
+	}{
+		{text: "first message", request: "first message.", reply: `first message\.`},
+		{text: "  Second message.\n", request: "Second message.", reply: `Second message\.`},
+	}
+
+	for turn, tt := range turns {
+		depth := turn + 1
+
+		err := tg.process(newTestMessage(depth, tt.text), input{Text: tt.text})
+		if err != nil {
+			t.Fatalf("turn %d: process() error = %v", depth, err)
+		}
+
+		sent := api.Texts("sendMessage")
+		if len(sent) != depth || sent[turn] != texts.Thinking {
+			t.Fatalf("turn %d: sent %q, want a placeholder per turn", depth, sent)
+		}
+
+		edits := api.Texts("editMessageText")
+		if len(edits) == 0 {
+			t.Fatalf("turn %d: the placeholder is never edited", depth)
+		}
+		reply := edits[len(edits)-1]
+		if !strings.Contains(reply, tt.reply) || !strings.Contains(reply, fmt.Sprintf(`_fake reply \#%d_`, depth)) {
+			t.Errorf("turn %d: reply = %q, want the echoed message and fake reply #%d", depth, reply, depth)
+		}
+
+		conversation, err := tg.storage.GetConversation(42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(conversation.LastResponseID, "fake_") {
+			t.Errorf("turn %d: last response ID = %q, want a fake one", depth, conversation.LastResponseID)
+		}
+		if len(conversation.History) != 2*depth {
+			t.Fatalf("turn %d: got %d history messages, want %d", depth, len(conversation.History), 2*depth)
+		}
+		if last := conversation.History[2*depth-2]; last.Participant != gpt.ParticipantUser || last.Text != tt.request {
+			t.Errorf("turn %d: history has %+v, want the user message", depth, last)
+		}
+
+		usage, err := tg.storage.UserUsage(42, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(usage) != 1 || usage[0].Model != "fake" || usage[0].Requests != int64(depth) || usage[0].Usage.TotalTokens == 0 {
+			t.Errorf("turn %d: usage = %+v, want %d requests of the fake model", depth, usage, depth)
+		}
+	}
+}
+
+func TestReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    string // Bot API error of the placeholder edit, empty means success.
+		wantErr bool
+	}{
+		{name: "edited"},
+		{
+			name: "same content",
+			edit: "Bad Request: message is not modified: specified new message content and reply markup are exactly the same as a current content and reply markup of the message",
+		},
+		{name: "not modified", edit: "Bad Request: message is not modified"},
+		{name: "other error", edit: "Bad Request: message to edit not found", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &botAPI{handle: func(call botCall) (int, any) {
+				if call.Method == "editMessageText" && tt.edit != "" {
+					return botError(tt.edit)
+				}
+				return 0, nil
+			}}
+			tg := newTestTelegram(t, api)
+
+			msg := newTestMessage(1, "hello")
+			placeholder := &telebot.Message{ID: 2, Chat: msg.Chat}
+
+			err := tg.reply(msg, placeholder, gpt.Response{Text: "hello"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reply() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if edits := api.Texts("editMessageText"); len(edits) != 1 {
+				t.Fatalf("got %d edits, want 1", len(edits))
+			}
+		})
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"github.com/rs/zerolog/log"
 	"gopkg.in/telebot.v4"
 
+	"github.com/kapitanov/gptbot/internal/storage"
 	"github.com/kapitanov/gptbot/internal/telegram/texts"
 )
 
 func (tg *Telegram) setupHandlers() {
 	tg.bot.Handle("/start", tg.onStartCommand)
 	tg.bot.Handle("/reset", tg.onResetCommand)
+	tg.bot.Handle("/usage", tg.onUsageCommand)
+	tg.bot.Handle("/persona", tg.onPersonaCommand)
+	tg.bot.Handle(personaButton, tg.onPersonaButton)
+	tg.bot.Handle("/keys", tg.onKeysCommand)
 	tg.bot.Handle(telebot.OnText, tg.onText)
 	tg.bot.Handle(telebot.OnPhoto, tg.onPhoto)
 	tg.bot.Handle(telebot.OnVideo, tg.onVideo)
@@ -17,6 +22,7 @@ func (tg *Telegram) setupHandlers() {
 	tg.bot.Handle(telebot.OnAnimation, tg.onAnimation)
 	tg.bot.Handle(telebot.OnDocument, tg.onDocument)
 	tg.bot.Handle(telebot.OnVoice, tg.onVoice)
+	tg.bot.Handle(telebot.OnVideoNote, tg.onVideoNote)
 }
 
 func (tg *Telegram) onStartCommand(ctx telebot.Context) error {
@@ -42,7 +48,7 @@ func (tg *Telegram) onResetCommand(ctx telebot.Context) error {
 		return nil
 	}
 
-	err := tg.storage.SetLastResponseID(msg.Sender.ID, "")
+	err := tg.storage.SetConversation(msg.Sender.ID, storage.Conversation{})
 	if err != nil {
 		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to reset conversation")
 		return err
@@ -60,13 +66,11 @@ func (tg *Telegram) onResetCommand(ctx telebot.Context) error {
 func (tg *Telegram) onText(ctx telebot.Context) error {
 	msg := ctx.Message()
 
-	return tg.generate(msg, msg.Text, "")
-}
-
-func (tg *Telegram) onPhoto(ctx telebot.Context) error {
-	msg := ctx.Message()
+	if links := messageLinks(msg); len(links) > 0 {
+		return tg.generateLinks(msg, links)
+	}
 
-	return tg.generate(msg, msg.Photo.Caption, msg.Caption)
+	return tg.generate(msg, msg.Text, "")
 }
 
 func (tg *Telegram) onVideo(ctx telebot.Context) error {
@@ -75,26 +79,8 @@ func (tg *Telegram) onVideo(ctx telebot.Context) error {
 	return tg.generate(msg, msg.Video.Caption, msg.Caption)
 }
 
-func (tg *Telegram) onAudio(ctx telebot.Context) error {
-	msg := ctx.Message()
-
-	return tg.generate(msg, msg.Audio.Caption, msg.Caption)
-}
-
 func (tg *Telegram) onAnimation(ctx telebot.Context) error {
 	msg := ctx.Message()
 
 	return tg.generate(msg, msg.Animation.Caption, msg.Caption)
 }
-
-func (tg *Telegram) onDocument(ctx telebot.Context) error {
-	msg := ctx.Message()
-
-	return tg.generate(msg, msg.Document.Caption, msg.Caption)
-}
-
-func (tg *Telegram) onVoice(ctx telebot.Context) error {
-	msg := ctx.Message()
-
-	return tg.generate(msg, msg.Voice.Caption, msg.Caption)
-}
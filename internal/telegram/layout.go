@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+)
+
+// layoutFuncs are functions available to reply templates.
+var layoutFuncs = template.FuncMap{
+	"hashtag": hashtag,
+	"join":    strings.Join,
+}
+
+// parseLayout parses a reply template and checks that it can be rendered.
+func parseLayout(text string) (*template.Template, error) {
+	tmpl, err := template.New("reply").Funcs(layoutFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := gpt.Response{
+		Text:       "text",
+		TLDR:       "tl;dr",
+		KeyPoints:  []string{"point"},
+		Tags:       []string{"tag"},
+		Language:   "en",
+		Confidence: 1,
+	}
+	err = tmpl.Execute(&strings.Builder{}, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// layout renders a reply in Markdown.
+func (c *replyConfig) layout(response gpt.Response) string {
+	if c.template == nil {
+		return response.Text
+	}
+
+	var text strings.Builder
+	err := c.template.Execute(&text, response)
+	if err != nil {
+		// Real responses may trip the template where the sample one didn't (e.g. index of an empty list),
+		// and the user should get the reply anyway.
+		log.Error().Err(err).Msg("failed to render reply, sending it as is")
+		return response.Text
+	}
+
+	return strings.TrimSpace(text.String())
+}
+
+// hashtag turns a tag into a Telegram hashtag: "machine learning" becomes "#machine_learning".
+func hashtag(tag string) string {
+	tag = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(strings.TrimPrefix(tag, "#")))
+
+	return "#" + strings.Trim(tag, "_")
+}
@@ -0,0 +1,260 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/extract"
+	"github.com/kapitanov/gptbot/internal/telegram/texts"
+)
+
+const (
+	maxLinks         = 3                // Max number of links to fetch from a single message.
+	maxPageSize      = 5 << 20          // Max size of a fetched page, in bytes.
+	maxPageChars     = 50_000           // Max number of characters to extract from a single page.
+	linkFetchTimeout = 15 * time.Second // Timeout of fetching a single page.
+	linkUserAgent    = "Mozilla/5.0 (compatible; gptbot/1.0)"
+)
+
+// source is a fetched link.
+type source struct {
+	URL   string
+	Title string
+}
+
+// messageLinks returns http(s) links of a message, including hidden links of formatted text.
+func messageLinks(msg *telebot.Message) []string {
+	entities := msg.Entities
+	if msg.Text == "" {
+		entities = msg.CaptionEntities
+	}
+
+	var (
+		links []string
+		seen  = make(map[string]bool)
+	)
+	for _, entity := range entities {
+		var link string
+		switch entity.Type {
+		case telebot.EntityURL:
+			link = msg.EntityText(entity)
+		case telebot.EntityTextLink:
+			link = entity.URL
+		default:
+			continue
+		}
+
+		if !strings.Contains(link, "://") {
+			link = "https://" + link
+		}
+
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+
+		if !seen[u.String()] {
+			seen[u.String()] = true
+			links = append(links, u.String())
+		}
+	}
+
+	return links
+}
+
+// instructionText returns message text without bare links, or an empty string if the message is just links.
+func instructionText(msg *telebot.Message) string {
+	text := msg.Text
+	for _, entity := range msg.Entities {
+		if entity.Type == telebot.EntityURL {
+			text = strings.ReplaceAll(text, msg.EntityText(entity), "")
+		}
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+
+	return msg.Text
+}
+
+// generateLinks fetches pages a message links to and transforms their text.
+// The message itself is used as an instruction unless it's just links.
+// If no page can be fetched, message text is transformed as is.
+func (tg *Telegram) generateLinks(msg *telebot.Message, links []string) error {
+	if !tg.hasAccess(msg) || !tg.checkQuota(msg) {
+		return nil
+	}
+
+	if len(links) > maxLinks {
+		links = links[:maxLinks]
+	}
+
+	err := tg.bot.Notify(msg.Sender, telebot.Typing)
+	if err != nil {
+		log.Error().Err(err).
+			Str("username", msg.Sender.Username).
+			Int("msg", msg.ID).
+			Msg("failed to send typing notification")
+	}
+
+	var (
+		pages   []string
+		sources []source
+	)
+	for _, link := range links {
+		result, err := tg.fetchLink(link)
+		if err != nil {
+			log.Error().Err(err).
+				Str("username", msg.Sender.Username).
+				Int("msg", msg.ID).
+				Str("url", link).
+				Msg("failed to fetch link")
+			continue
+		}
+
+		log.Info().
+			Str("username", msg.Sender.Username).
+			Int("msg", msg.ID).
+			Str("url", link).
+			Str("title", result.Title).
+			Int("chars", len([]rune(result.Text))).
+			Bool("truncated", result.Truncated).
+			Msg("fetched link")
+
+		pages = append(pages, extractedText("Link", link, result))
+		sources = append(sources, source{URL: link, Title: result.Title})
+	}
+
+	if len(pages) == 0 {
+		return tg.transform(msg, input{Text: msg.Text})
+	}
+
+	return tg.transform(msg, input{
+		Text:        strings.Join(pages, "\n\n---\n\n"),
+		Instruction: instructionText(msg),
+		Sources:     sources,
+	})
+}
+
+// fetchLink downloads a page and extracts its readable text.
+func (tg *Telegram) fetchLink(link string) (extract.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), linkFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return extract.Result{}, err
+	}
+
+	req.Header.Set("User-Agent", linkUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain,application/pdf;q=0.9,*/*;q=0.8")
+
+	resp, err := tg.linkClient.Do(req)
+	if err != nil {
+		return extract.Result{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return extract.Result{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	if resp.ContentLength > maxPageSize {
+		return extract.Result{}, fmt.Errorf("page is too large: %d bytes", resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize+1))
+	if err != nil {
+		return extract.Result{}, err
+	}
+
+	if len(data) > maxPageSize {
+		return extract.Result{}, fmt.Errorf("page is larger than %d bytes", maxPageSize)
+	}
+
+	result, err := extract.Text(extract.Request{
+		Data:     data,
+		MIMEType: resp.Header.Get("Content-Type"),
+		Filename: path.Base(resp.Request.URL.Path),
+		MaxPages: maxDocumentPages,
+		MaxChars: maxPageChars,
+	})
+	if err != nil {
+		return extract.Result{}, err
+	}
+
+	if result.Text == "" {
+		return extract.Result{}, fmt.Errorf("page has no text")
+	}
+
+	return result, nil
+}
+
+// newLinkClient creates an HTTP client to fetch links with.
+// Links come from users, so the client refuses to connect to loopback and private networks.
+// Links are fetched directly, bypassing proxies.
+func newLinkClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: linkFetchTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+				return fmt.Errorf("address %s is not allowed", address)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// Through a proxy, the dialer would check the proxy address instead of the link one.
+	transport.Proxy = nil
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   linkFetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// sourcesText returns a markdown list of sources to be appended to a reply.
+func sourcesText(sources []source) string {
+	var sb strings.Builder
+	for _, s := range sources {
+		title := s.Title
+		if title == "" {
+			title = s.URL
+		}
+
+		// Brackets would break markdown link syntax.
+		title = strings.NewReplacer("[", "(", "]", ")").Replace(title)
+		link := strings.NewReplacer("(", "%28", ")", "%29", " ", "%20").Replace(s.URL)
+
+		_, _ = fmt.Fprintf(&sb, "\n\n%s [%s](%s)", texts.Source, title, link)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,188 @@
+package telegram
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/telegram/texts"
+)
+
+// newTestLinkMessage returns a message whose entities mark the given substrings of text as bare links.
+func newTestLinkMessage(text string, links ...string) *telebot.Message {
+	msg := newTestMessage(1, text)
+	for _, link := range links {
+		offset := strings.Index(text, link)
+		msg.Entities = append(msg.Entities, telebot.MessageEntity{Type: telebot.EntityURL, Offset: offset, Length: len(link)})
+	}
+	return msg
+}
+
+func TestMessageLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *telebot.Message
+		want []string
+	}{
+		{name: "no links", msg: newTestMessage(1, "hello")},
+		{name: "bare link", msg: newTestLinkMessage("see https://example.com/a", "https://example.com/a"), want: []string{"https://example.com/a"}},
+		{name: "no scheme", msg: newTestLinkMessage("see example.com", "example.com"), want: []string{"https://example.com"}},
+		{name: "other scheme", msg: newTestLinkMessage("see ftp://example.com", "ftp://example.com")},
+		{
+			name: "duplicates",
+			msg:  newTestLinkMessage("http://a.com and b.com, http://a.com", "http://a.com", "b.com"),
+			want: []string{"http://a.com", "https://b.com"},
+		},
+		{
+			name: "hidden link",
+			msg: &telebot.Message{Text: "read this", Entities: telebot.Entities{
+				{Type: telebot.EntityTextLink, Offset: 0, Length: 4, URL: "https://example.com/post"},
+				{Type: telebot.EntityBold, Offset: 5, Length: 4},
+			}},
+			want: []string{"https://example.com/post"},
+		},
+		{
+			name: "caption",
+			msg: &telebot.Message{Caption: "see example.com", CaptionEntities: telebot.Entities{
+				{Type: telebot.EntityURL, Offset: 4, Length: 11},
+			}},
+			want: []string{"https://example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := messageLinks(tt.msg)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("messageLinks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstructionText(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *telebot.Message
+		want string
+	}{
+		{name: "just a link", msg: newTestLinkMessage(" https://example.com \n", "https://example.com"), want: ""},
+		{name: "just links", msg: newTestLinkMessage("a.com b.com", "a.com", "b.com"), want: ""},
+		{name: "instruction", msg: newTestLinkMessage("translate https://example.com", "https://example.com"), want: "translate https://example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := instructionText(tt.msg); got != tt.want {
+				t.Errorf("instructionText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourcesText(t *testing.T) {
+	got := sourcesText([]source{
+		{URL: "https://example.com/a", Title: "Page [draft]"},
+		{URL: "https://example.com/b (1)"},
+	})
+
+	want := "\n\n" + texts.Source + " [Page (draft)](https://example.com/a)" +
+		"\n\n" + texts.Source + " [https://example.com/b (1)](https://example.com/b%20%281%29)"
+	if got != want {
+		t.Errorf("sourcesText() = %q, want %q", got, want)
+	}
+}
+
+func TestLinkClientRefusesLocalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secret"))
+	}))
+	t.Cleanup(server.Close)
+
+	for _, link := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		resp, err := newLinkClient().Get(link)
+		if err == nil {
+			_ = resp.Body.Close()
+			t.Errorf("link client has fetched %s, want refused", link)
+		} else if !strings.Contains(err.Error(), "is not allowed") {
+			t.Errorf("link client error = %v, want the address refused", err)
+		}
+	}
+}
+
+func TestGenerateLinks(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		status      int
+		wantRequest []string // Parts of the request kept in history.
+		wantSource  bool
+	}{
+		{
+			name:        "page",
+			text:        "$URL/page",
+			wantRequest: []string{"[Link: $URL/page]", "[Title: Test page]", "Page text."},
+			wantSource:  true,
+		},
+		{
+			name:        "page with instruction",
+			text:        "translate $URL/page",
+			wantRequest: []string{"translate $URL/page", "[Link: $URL/page]", "Page text."},
+			wantSource:  true,
+		},
+		{name: "broken link", text: "what is $URL/page", status: http.StatusNotFound, wantRequest: []string{"what is $URL/page"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				_, _ = w.Write([]byte("<html><head><title>Test page</title></head><body><p>Page text.</p></body></html>"))
+			}))
+			t.Cleanup(server.Close)
+
+			api := &botAPI{}
+			tg := newTestTelegram(t, api)
+			// The test server is local, which the link client refuses to connect to.
+			tg.linkClient = server.Client()
+
+			link := server.URL + "/page"
+			text := strings.ReplaceAll(tt.text, "$URL", server.URL)
+			msg := newTestLinkMessage(text, link)
+
+			err := tg.generateLinks(msg, messageLinks(msg))
+			if err != nil {
+				t.Fatalf("generateLinks() error = %v", err)
+			}
+
+			conversation, err := tg.storage.GetConversation(42)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(conversation.History) != 2 {
+				t.Fatalf("history = %+v, want a single turn", conversation.History)
+			}
+			for _, want := range tt.wantRequest {
+				want = strings.ReplaceAll(want, "$URL", server.URL)
+				if !strings.Contains(conversation.History[0].Text, want) {
+					t.Errorf("request = %q, want %q in it", conversation.History[0].Text, want)
+				}
+			}
+
+			edits := api.Texts("editMessageText")
+			if len(edits) == 0 {
+				t.Fatal("the placeholder is never edited")
+			}
+			if hasSource := strings.Contains(edits[len(edits)-1], texts.Source); hasSource != tt.wantSource {
+				t.Errorf("reply = %q, want the source %v", edits[len(edits)-1], tt.wantSource)
+			}
+		})
+	}
+}
@@ -25,6 +25,11 @@ func Transform(req TransformRequest) TransformResult {
 	return TransformResult{Chunks: chunks}
 }
 
+// Split splits plain text into chunks of at most maxLength characters.
+func Split(text string, maxLength int) []Chunk {
+	return splitIntoChunks(text, maxLength)
+}
+
 func renderTgMarkdown(text string) string {
 	md := tgmd.TGMD()
 
@@ -0,0 +1,144 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+	"github.com/kapitanov/gptbot/internal/storage"
+	"github.com/kapitanov/gptbot/internal/telegram/texts"
+)
+
+// personaButton is an endpoint of persona keyboard buttons.
+var personaButton = &telebot.InlineButton{Unique: "persona"}
+
+// personas returns personas of the provider, or nil if it has no personas to choose from.
+func (tg *Telegram) personas() []gpt.Persona {
+	lister, ok := tg.gpt.(gpt.PersonaLister)
+	if !ok {
+		return nil
+	}
+
+	personas := lister.Personas()
+	if len(personas) < 2 {
+		return nil
+	}
+	return personas
+}
+
+func (tg *Telegram) onPersonaCommand(ctx telebot.Context) error {
+	msg := ctx.Message()
+
+	if !tg.hasAccess(msg) {
+		return nil
+	}
+
+	personas := tg.personas()
+	if personas == nil {
+		_, err := tg.bot.Send(msg.Sender, texts.NoPersonas)
+		if err != nil {
+			log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to send personas")
+		}
+		return err
+	}
+
+	current, err := tg.storage.GetPersona(msg.Sender.ID)
+	if err != nil {
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to get persona")
+		return err
+	}
+
+	var text strings.Builder
+	text.WriteString(texts.ChoosePersona)
+
+	markup := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	for _, persona := range personas {
+		label := personaLabel(persona.Name)
+		if persona.Description != "" {
+			_, _ = fmt.Fprintf(&text, "\n\n%s: %s", label, persona.Description)
+		}
+
+		if persona.Name == current {
+			label = "✓ " + label
+		}
+		rows = append(rows, markup.Row(markup.Data(label, personaButton.Unique, persona.Name)))
+	}
+	markup.Inline(rows...)
+
+	_, err = tg.bot.Send(msg.Sender, text.String(), markup)
+	if err != nil {
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to send personas")
+		return err
+	}
+
+	return nil
+}
+
+func (tg *Telegram) onPersonaButton(ctx telebot.Context) error {
+	callback := ctx.Callback()
+	sender := callback.Sender
+	name := callback.Data
+
+	if !tg.accessChecker.CheckAccess(sender.ID, sender.Username) {
+		log.Error().Str("username", sender.Username).Msg("access denied")
+		return ctx.Respond(&telebot.CallbackResponse{Text: texts.AccessDenied})
+	}
+
+	known := false
+	for _, persona := range tg.personas() {
+		known = known || persona.Name == name
+	}
+	if !known {
+		log.Warn().Str("username", sender.Username).Str("persona", name).Msg("unknown persona")
+		return ctx.Respond(&telebot.CallbackResponse{Text: texts.NoPersonas})
+	}
+
+	current, err := tg.storage.GetPersona(sender.ID)
+	if err != nil {
+		log.Error().Err(err).Str("username", sender.Username).Msg("failed to get persona")
+		return err
+	}
+
+	if name != current {
+		err = tg.storage.SetPersona(sender.ID, name)
+		if err != nil {
+			log.Error().Err(err).Str("username", sender.Username).Msg("failed to store persona")
+			return err
+		}
+
+		// Replies of the previous persona would confuse the new one.
+		err = tg.storage.SetConversation(sender.ID, storage.Conversation{})
+		if err != nil {
+			log.Error().Err(err).Str("username", sender.Username).Msg("failed to reset conversation")
+			return err
+		}
+
+		log.Info().Str("username", sender.Username).Str("persona", name).Msg("persona changed")
+	}
+
+	err = ctx.Respond()
+	if err != nil {
+		log.Error().Err(err).Str("username", sender.Username).Msg("failed to answer callback")
+	}
+
+	_, err = tg.bot.Edit(callback.Message, fmt.Sprintf(texts.PersonaChanged, personaLabel(name)))
+	if err != nil {
+		log.Error().Err(err).Str("username", sender.Username).Msg("failed to send persona")
+		return err
+	}
+
+	return nil
+}
+
+// personaLabel returns a name of a persona to show to users.
+func personaLabel(name string) string {
+	if name == "" {
+		return texts.DefaultPersona
+	}
+
+	return name
+}
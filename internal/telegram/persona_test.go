@@ -0,0 +1,166 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+	"github.com/kapitanov/gptbot/internal/storage"
+	"github.com/kapitanov/gptbot/internal/telegram/texts"
+)
+
+// personaProvider is a fake provider with personas.
+type personaProvider struct {
+	gpt.Provider
+}
+
+func (personaProvider) Personas() []gpt.Persona {
+	return []gpt.Persona{{Description: "Default"}, {Name: "formal", Description: "Formal"}}
+}
+
+func TestPersonaCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		personas  bool   // Provider has personas.
+		current   string // Persona chosen by the user.
+		wantText  []string
+		wantCheck string // Button marked as the current persona.
+	}{
+		{name: "no personas", wantText: []string{texts.NoPersonas}},
+		{
+			name:      "default",
+			personas:  true,
+			wantText:  []string{texts.ChoosePersona, texts.DefaultPersona + ": Default", "formal: Formal"},
+			wantCheck: "✓ " + texts.DefaultPersona,
+		},
+		{
+			name:      "chosen",
+			personas:  true,
+			current:   "formal",
+			wantText:  []string{texts.ChoosePersona, "formal: Formal"},
+			wantCheck: "✓ formal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &botAPI{}
+			tg := newTestTelegram(t, api)
+			if tt.personas {
+				tg.gpt = personaProvider{tg.gpt}
+			}
+
+			err := tg.storage.SetPersona(42, tt.current)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = tg.onPersonaCommand(tg.bot.NewContext(telebot.Update{Message: newTestMessage(1, "/persona")}))
+			if err != nil {
+				t.Fatalf("onPersonaCommand() error = %v", err)
+			}
+
+			var sent []botCall
+			for _, call := range api.Calls() {
+				if call.Method == "sendMessage" {
+					sent = append(sent, call)
+				}
+			}
+			if len(sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(sent))
+			}
+
+			text, _ := sent[0].Params["text"].(string)
+			for _, want := range tt.wantText {
+				if !strings.Contains(text, want) {
+					t.Errorf("text = %q, want %q in it", text, want)
+				}
+			}
+
+			markup := fmt.Sprint(sent[0].Params["reply_markup"])
+			if tt.wantCheck != "" && (strings.Count(markup, "✓") != 1 || !strings.Contains(markup, tt.wantCheck)) {
+				t.Errorf("keyboard = %s, want the only check mark at %q", markup, tt.wantCheck)
+			}
+		})
+	}
+}
+
+func TestPersonaButton(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     string // Persona chosen by the user.
+		pressed     string // Persona of the pressed button.
+		wantPersona string
+		wantReset   bool   // Conversation starts over.
+		wantAnswer  string // Text of the callback answer.
+	}{
+		{name: "switch", pressed: "formal", wantPersona: "formal", wantReset: true},
+		{name: "back to default", current: "formal", pressed: "", wantPersona: "", wantReset: true},
+		{name: "same persona", current: "formal", pressed: "formal", wantPersona: "formal"},
+		{name: "unknown persona", current: "formal", pressed: "pirate", wantPersona: "formal", wantAnswer: texts.NoPersonas},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &botAPI{}
+			tg := newTestTelegram(t, api)
+			tg.gpt = personaProvider{tg.gpt}
+
+			conversation := storage.Conversation{
+				LastResponseID: "resp_1",
+				History:        []gpt.Message{{Participant: gpt.ParticipantUser, Text: "hi"}, {Participant: gpt.ParticipantBot, Text: "hello"}},
+			}
+			err := tg.storage.SetPersona(42, tt.current)
+			if err == nil {
+				err = tg.storage.SetConversation(42, conversation)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user := newTestMessage(1, "").Sender
+			err = tg.onPersonaButton(tg.bot.NewContext(telebot.Update{Callback: &telebot.Callback{
+				ID:      "1",
+				Sender:  user,
+				Data:    tt.pressed,
+				Message: &telebot.Message{ID: 10, Chat: &telebot.Chat{ID: user.ID, Type: telebot.ChatPrivate}},
+			}}))
+			if err != nil {
+				t.Fatalf("onPersonaButton() error = %v", err)
+			}
+
+			persona, err := tg.storage.GetPersona(42)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if persona != tt.wantPersona {
+				t.Errorf("persona = %q, want %q", persona, tt.wantPersona)
+			}
+
+			got, err := tg.storage.GetConversation(42)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reset := got.LastResponseID == "" && len(got.History) == 0; reset != tt.wantReset {
+				t.Errorf("conversation = %+v, want reset %v", got, tt.wantReset)
+			}
+
+			answers := api.Texts("answerCallbackQuery")
+			if len(answers) != 1 || answers[0] != tt.wantAnswer {
+				t.Errorf("callback answers = %q, want %q", answers, tt.wantAnswer)
+			}
+
+			// The keyboard is replaced with the chosen persona.
+			edits := api.Texts("editMessageText")
+			if tt.wantAnswer == "" && (len(edits) != 1 || edits[0] != fmt.Sprintf(texts.PersonaChanged, personaLabel(tt.pressed))) {
+				t.Errorf("edits = %q, want the chosen persona", edits)
+			}
+			if tt.wantAnswer != "" && len(edits) != 0 {
+				t.Errorf("edits = %q, want none", edits)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package telegram
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+)
+
+const (
+	maxPhotoSize = 10 << 20                // Max size of a single photo, in bytes.
+	maxPhotos    = 10                      // Max number of photos in a single request.
+	albumDelay   = 1500 * time.Millisecond // Time to wait for the rest of the album to arrive.
+)
+
+func (tg *Telegram) onPhoto(ctx telebot.Context) error {
+	msg := ctx.Message()
+
+	if !tg.hasAccess(msg) {
+		return nil
+	}
+
+	if msg.AlbumID == "" {
+		return tg.generatePhotos([]*telebot.Message{msg})
+	}
+
+	tg.albums.Add(msg, func(msgs []*telebot.Message) {
+		_ = tg.generatePhotos(msgs)
+	})
+	return nil
+}
+
+// generatePhotos processes photos from one or more messages of the same album as a single request.
+// Access to every message is checked on arrival.
+func (tg *Telegram) generatePhotos(msgs []*telebot.Message) error {
+	msg := msgs[0]
+
+	if !tg.checkQuota(msg) {
+		return nil
+	}
+
+	var in input
+	for _, m := range msgs {
+		if in.Text == "" {
+			in.Text = m.Caption
+		}
+
+		if m.Photo == nil {
+			continue
+		}
+
+		if len(in.Images) >= maxPhotos {
+			log.Warn().Str("username", msg.Sender.Username).Int("msg", m.ID).Msg("too many photos, skipping the rest")
+			break
+		}
+
+		image, err := tg.downloadPhoto(m.Photo)
+		if err != nil {
+			log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", m.ID).Msg("failed to download photo")
+			continue
+		}
+
+		in.Images = append(in.Images, image)
+	}
+
+	return tg.transform(msg, in)
+}
+
+func (tg *Telegram) downloadPhoto(photo *telebot.Photo) (gpt.Image, error) {
+	if photo.FileSize > maxPhotoSize {
+		return gpt.Image{}, fmt.Errorf("photo is too large: %d bytes", photo.FileSize)
+	}
+
+	data, err := tg.downloadFile(&photo.File, maxPhotoSize)
+	if err != nil {
+		return gpt.Image{}, err
+	}
+
+	return gpt.Image{MIMEType: http.DetectContentType(data), Data: data}, nil
+}
+
+// maxDownloadSize is the largest file the Bot API lets bots download, in bytes.
+const maxDownloadSize = 20 << 20
+
+// downloadFile downloads a file via Bot API, refusing files larger than maxSize bytes.
+func (tg *Telegram) downloadFile(file *telebot.File, maxSize int64) ([]byte, error) {
+	r, err := tg.bot.File(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxSize)
+	}
+
+	return data, nil
+}
+
+// albumCollector groups messages of the same album.
+// Telegram sends every album item as a separate message, so they are collected until no new items arrive for a while.
+type albumCollector struct {
+	mutex  sync.Mutex
+	albums map[string]*album
+}
+
+type album struct {
+	msgs  []*telebot.Message
+	timer *time.Timer
+}
+
+func newAlbumCollector() *albumCollector {
+	return &albumCollector{albums: make(map[string]*album)}
+}
+
+// Add adds a message to its album. Once the album is complete, fn is called with all its messages.
+func (c *albumCollector) Add(msg *telebot.Message, fn func(msgs []*telebot.Message)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	a, exists := c.albums[msg.AlbumID]
+	if !exists {
+		a = &album{}
+		c.albums[msg.AlbumID] = a
+
+		a.timer = time.AfterFunc(albumDelay, func() {
+			c.mutex.Lock()
+			msgs := a.msgs
+			delete(c.albums, msg.AlbumID)
+			c.mutex.Unlock()
+
+			sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+
+			fn(msgs)
+		})
+	} else if a.timer.Stop() {
+		// If the timer has already fired, the pending callback will pick this message up.
+		a.timer.Reset(albumDelay)
+	}
+
+	a.msgs = append(a.msgs, msg)
+}
@@ -0,0 +1,148 @@
+package telegram
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/telebot.v4"
+)
+
+// newTestPhoto returns a message of an album with a photo of the given size.
+func newTestPhoto(id int, album, caption string, size int64) *telebot.Message {
+	msg := newTestMessage(id, "")
+	msg.AlbumID = album
+	msg.Caption = caption
+	msg.Photo = &telebot.Photo{File: telebot.File{FileID: "photo", FileSize: size}}
+	return msg
+}
+
+func TestGeneratePhotos(t *testing.T) {
+	tests := []struct {
+		name       string
+		msgs       []*telebot.Message
+		wantText   string // User message kept in history.
+		wantMarker string // Attached images noted in history, empty means none.
+	}{
+		{
+			name:       "single photo",
+			msgs:       []*telebot.Message{newTestPhoto(1, "", "What is it?", 100)},
+			wantText:   "What is it?",
+			wantMarker: "[1 image(s) attached]",
+		},
+		{
+			name: "album with caption on a later item",
+			msgs: []*telebot.Message{
+				newTestPhoto(1, "a", "", 100),
+				newTestPhoto(2, "a", "Compare these", 100),
+				newTestPhoto(3, "a", "", 100),
+			},
+			wantText:   "Compare these",
+			wantMarker: "[3 image(s) attached]",
+		},
+		{
+			name: "too large photo",
+			msgs: []*telebot.Message{
+				newTestPhoto(1, "a", "Compare these", 100),
+				newTestPhoto(2, "a", "", maxPhotoSize+1),
+			},
+			wantText:   "Compare these",
+			wantMarker: "[1 image(s) attached]",
+		},
+		{
+			name: "too many photos",
+			msgs: func() []*telebot.Message {
+				var msgs []*telebot.Message
+				for i := range maxPhotos + 2 {
+					msgs = append(msgs, newTestPhoto(i+1, "a", "", 100))
+				}
+				return msgs
+			}(),
+			wantMarker: "[10 image(s) attached]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &botAPI{handle: func(call botCall) (int, any) {
+				if call.Method == "getFile" {
+					return http.StatusOK, map[string]any{"ok": true, "result": map[string]any{"file_id": "photo", "file_path": "photo.jpg"}}
+				}
+				return 0, nil
+			}}
+			tg := newTestTelegram(t, api)
+
+			err := tg.generatePhotos(tt.msgs)
+			if err != nil {
+				t.Fatalf("generatePhotos() error = %v", err)
+			}
+
+			// An album is a single request.
+			if sent := api.Texts("sendMessage"); len(sent) != 1 {
+				t.Errorf("sent %q, want a single reply", sent)
+			}
+
+			conversation, err := tg.storage.GetConversation(42)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(conversation.History) != 2 {
+				t.Fatalf("history = %+v, want a single turn", conversation.History)
+			}
+			request := conversation.History[0].Text
+			if !strings.HasPrefix(request, tt.wantText) || !strings.HasSuffix(request, tt.wantMarker) {
+				t.Errorf("request = %q, want %q with %q", request, tt.wantText, tt.wantMarker)
+			}
+		})
+	}
+}
+
+func TestAlbumCollector(t *testing.T) {
+	c := newAlbumCollector()
+
+	var (
+		mutex  sync.Mutex
+		albums [][]int
+		done   = make(chan struct{}, 2)
+	)
+	collect := func(msgs []*telebot.Message) {
+		var ids []int
+		for _, msg := range msgs {
+			ids = append(ids, msg.ID)
+		}
+
+		mutex.Lock()
+		albums = append(albums, ids)
+		mutex.Unlock()
+		done <- struct{}{}
+	}
+
+	// Items may arrive out of order and interleaved with other albums.
+	for _, msg := range []*telebot.Message{
+		newTestPhoto(2, "a", "", 100),
+		newTestPhoto(5, "b", "", 100),
+		newTestPhoto(1, "a", "", 100),
+		newTestPhoto(3, "a", "", 100),
+	} {
+		c.Add(msg, collect)
+	}
+
+	for range 2 {
+		select {
+		case <-done:
+		case <-time.After(3 * albumDelay):
+			t.Fatal("albums are never completed")
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	sort.Slice(albums, func(i, j int) bool { return albums[i][0] < albums[j][0] })
+	if len(albums) != 2 || len(albums[0]) != 3 || albums[0][0] != 1 || albums[0][2] != 3 || len(albums[1]) != 1 || albums[1][0] != 5 {
+		t.Errorf("albums = %v, want [[1 2 3] [5]]", albums)
+	}
+}
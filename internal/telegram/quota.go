@@ -0,0 +1,165 @@
+package telegram
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/storage"
+	"github.com/kapitanov/gptbot/internal/telegram/texts"
+)
+
+// resetTimeFormat is a format of quota reset time.
+const resetTimeFormat = "02.01.2006 15:04 MST"
+
+// quotaUsage is a consumption of a user within a quota period.
+type quotaUsage struct {
+	Messages int64
+	Tokens   int64
+	Cost     float64
+}
+
+// userUsage returns daily and monthly consumption of a user, and models used this month.
+func (tg *Telegram) userUsage(userID int64, now time.Time) (daily, monthly quotaUsage, models []string, err error) {
+	today := now.Format(time.DateOnly)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Format(time.DateOnly)
+
+	records, err := tg.storage.UserUsage(userID, monthStart)
+	if err != nil {
+		return quotaUsage{}, quotaUsage{}, nil, err
+	}
+
+	for _, record := range records {
+		monthly.add(record)
+		if record.Day == today {
+			daily.add(record)
+		}
+		if !slices.Contains(models, record.Model) {
+			models = append(models, record.Model)
+		}
+	}
+
+	slices.Sort(models)
+	return daily, monthly, models, nil
+}
+
+func (u *quotaUsage) add(record storage.UsageRecord) {
+	u.Messages += record.Requests
+	u.Tokens += record.Usage.TotalTokens
+	u.Cost += record.Cost
+}
+
+// exceeded returns a description of the limit that usage has reached, if any.
+func (l quotaLimits) exceeded(usage quotaUsage) (string, bool) {
+	switch {
+	case l.Messages > 0 && usage.Messages >= l.Messages:
+		return fmt.Sprintf(texts.LimitMessages, l.Messages), true
+	case l.Tokens > 0 && usage.Tokens >= l.Tokens:
+		return fmt.Sprintf(texts.LimitTokens, l.Tokens), true
+	case l.Cost > 0 && usage.Cost >= l.Cost:
+		return fmt.Sprintf(texts.LimitCost, l.Cost), true
+	default:
+		return "", false
+	}
+}
+
+// checkQuota returns true if the user may send one more request.
+// Otherwise the user is told when the quota resets.
+func (tg *Telegram) checkQuota(msg *telebot.Message) bool {
+	quota := tg.config.Quotas.quotaFor(msg.Sender.ID, msg.Sender.Username)
+	if quota == (quotaConfig{}) {
+		return true
+	}
+
+	now := time.Now().UTC()
+	daily, monthly, _, err := tg.userUsage(msg.Sender.ID, now)
+	if err != nil {
+		// Usage accounting failure shouldn't lock users out.
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to check quota")
+		return true
+	}
+
+	text, exceeded := quotaExceeded(quota, daily, monthly, now)
+	if !exceeded {
+		return true
+	}
+
+	log.Warn().Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("quota exceeded")
+
+	_, err = tg.bot.Reply(msg, text)
+	if err != nil {
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to send quota message")
+	}
+	return false
+}
+
+// quotaExceeded returns a message telling which limit of the quota is reached and when it resets, if any.
+// The monthly quota is reported first as it takes longer to reset.
+func quotaExceeded(quota quotaConfig, daily, monthly quotaUsage, now time.Time) (string, bool) {
+	if limit, exceeded := quota.Monthly.exceeded(monthly); exceeded {
+		reset := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		return fmt.Sprintf(texts.MonthlyLimitReached, limit, reset.Format(resetTimeFormat)), true
+	}
+
+	if limit, exceeded := quota.Daily.exceeded(daily); exceeded {
+		reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		return fmt.Sprintf(texts.DailyLimitReached, limit, reset.Format(resetTimeFormat)), true
+	}
+
+	return "", false
+}
+
+func (tg *Telegram) onUsageCommand(ctx telebot.Context) error {
+	msg := ctx.Message()
+
+	if !tg.hasAccess(msg) {
+		return nil
+	}
+
+	daily, monthly, models, err := tg.userUsage(msg.Sender.ID, time.Now().UTC())
+	if err != nil {
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to get usage")
+		return err
+	}
+
+	quota := tg.config.Quotas.quotaFor(msg.Sender.ID, msg.Sender.Username)
+
+	modelsText := texts.UsageNoModels
+	if len(models) > 0 {
+		modelsText = strings.Join(models, ", ")
+	}
+
+	text := fmt.Sprintf(texts.Usage, usageText(daily, quota.Daily), usageText(monthly, quota.Monthly), modelsText)
+	_, err = tg.bot.Send(msg.Sender, text)
+	if err != nil {
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to send usage")
+		return err
+	}
+
+	return nil
+}
+
+// usageText formats usage along with its limits.
+func usageText(usage quotaUsage, limits quotaLimits) string {
+	messages := strconv.FormatInt(usage.Messages, 10)
+	if limits.Messages > 0 {
+		messages += "/" + strconv.FormatInt(limits.Messages, 10)
+	}
+
+	tokens := strconv.FormatInt(usage.Tokens, 10)
+	if limits.Tokens > 0 {
+		tokens += "/" + strconv.FormatInt(limits.Tokens, 10)
+	}
+
+	cost := fmt.Sprintf("$%.4f", usage.Cost)
+	if limits.Cost > 0 {
+		cost += fmt.Sprintf("/$%.2f", limits.Cost)
+	}
+
+	return fmt.Sprintf(texts.UsageLine, messages, tokens, cost)
+}
@@ -0,0 +1,263 @@
+package telegram
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+	"github.com/kapitanov/gptbot/internal/storage"
+	"github.com/kapitanov/gptbot/internal/telegram/texts"
+)
+
+func TestQuotaLimitsExceeded(t *testing.T) {
+	limits := quotaLimits{Messages: 10, Tokens: 1000, Cost: 0.5}
+
+	tests := []struct {
+		name   string
+		limits quotaLimits
+		usage  quotaUsage
+		want   string
+	}{
+		{name: "unlimited", limits: quotaLimits{}, usage: quotaUsage{Messages: 1e6, Tokens: 1e9, Cost: 1e3}},
+		{name: "within limits", limits: limits, usage: quotaUsage{Messages: 9, Tokens: 999, Cost: 0.49}},
+		{name: "messages", limits: limits, usage: quotaUsage{Messages: 10}, want: fmt.Sprintf(texts.LimitMessages, 10)},
+		{name: "tokens", limits: limits, usage: quotaUsage{Tokens: 1500}, want: fmt.Sprintf(texts.LimitTokens, 1000)},
+		{name: "cost", limits: limits, usage: quotaUsage{Cost: 0.5}, want: fmt.Sprintf(texts.LimitCost, 0.5)},
+		{name: "messages first", limits: limits, usage: quotaUsage{Messages: 10, Tokens: 1000, Cost: 1}, want: fmt.Sprintf(texts.LimitMessages, 10)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, exceeded := tt.limits.exceeded(tt.usage)
+			if got != tt.want || exceeded != (tt.want != "") {
+				t.Errorf("exceeded() = %q, %v, want %q", got, exceeded, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	quota := quotaConfig{Daily: quotaLimits{Messages: 5}, Monthly: quotaLimits{Messages: 50}}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		daily   int64
+		monthly int64
+		want    string
+	}{
+		{name: "within quota", now: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), daily: 4, monthly: 49},
+		{
+			name:    "daily",
+			now:     time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+			daily:   5,
+			monthly: 20,
+			want:    fmt.Sprintf(texts.DailyLimitReached, fmt.Sprintf(texts.LimitMessages, 5), "17.10.2026 00:00 UTC"),
+		},
+		{
+			name:    "monthly first",
+			now:     time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+			daily:   5,
+			monthly: 50,
+			want:    fmt.Sprintf(texts.MonthlyLimitReached, fmt.Sprintf(texts.LimitMessages, 50), "01.11.2026 00:00 UTC"),
+		},
+		{
+			name:  "end of month",
+			now:   time.Date(2028, 2, 28, 23, 59, 0, 0, time.UTC),
+			daily: 5,
+			want:  fmt.Sprintf(texts.DailyLimitReached, fmt.Sprintf(texts.LimitMessages, 5), "29.02.2028 00:00 UTC"),
+		},
+		{
+			name:    "end of year",
+			now:     time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC),
+			monthly: 50,
+			want:    fmt.Sprintf(texts.MonthlyLimitReached, fmt.Sprintf(texts.LimitMessages, 50), "01.01.2027 00:00 UTC"),
+		},
+		{
+			name:  "daily at new year",
+			now:   time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+			daily: 5,
+			want:  fmt.Sprintf(texts.DailyLimitReached, fmt.Sprintf(texts.LimitMessages, 5), "01.01.2027 00:00 UTC"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, exceeded := quotaExceeded(quota, quotaUsage{Messages: tt.daily}, quotaUsage{Messages: tt.monthly}, tt.now)
+			if got != tt.want || exceeded != (tt.want != "") {
+				t.Errorf("quotaExceeded() = %q, %v, want %q", got, exceeded, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaFor(t *testing.T) {
+	defaultLimits := quotaLimits{Messages: 10}
+	vipLimits := quotaLimits{Messages: 100}
+
+	quotas := &quotasConfig{
+		Default: quotaConfig{Daily: defaultLimits, Monthly: defaultLimits},
+		Users: map[string]userQuotaConfig{
+			"42":     {Daily: &vipLimits},
+			"@alice": {Monthly: &vipLimits},
+			"bob":    {Daily: &quotaLimits{}, Monthly: &quotaLimits{}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		id       int64
+		username string
+		want     quotaConfig
+	}{
+		{name: "default", id: 1, username: "carol", want: quotaConfig{Daily: defaultLimits, Monthly: defaultLimits}},
+		{name: "by id", id: 42, username: "alice", want: quotaConfig{Daily: vipLimits, Monthly: defaultLimits}},
+		{name: "by username with at", id: 2, username: "alice", want: quotaConfig{Daily: defaultLimits, Monthly: vipLimits}},
+		{name: "unlimited", id: 3, username: "bob", want: quotaConfig{}},
+		{name: "no username", id: 4, want: quotaConfig{Daily: defaultLimits, Monthly: defaultLimits}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quotas.quotaFor(tt.id, tt.username); got != tt.want {
+				t.Errorf("quotaFor(%d, %q) = %+v, want %+v", tt.id, tt.username, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserUsage(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	path := filepath.Join(t.TempDir(), "data.yaml")
+	err := os.WriteFile(path, []byte(strings.Join([]string{
+		"usage:",
+		"  42:",
+		"    username: alice",
+		"    days:",
+		"      \"2026-10-16\":",
+		"        gpt-4o: {requests: 2, total_tokens: 100, cost: 0.25}",
+		"        o3: {requests: 1, total_tokens: 50, cost: 0.5}",
+		"      \"2026-10-15\":",
+		"        gpt-4o: {requests: 3, total_tokens: 300, cost: 1}",
+		"      \"2026-10-01\":",
+		"        gpt-4o-mini: {requests: 4, total_tokens: 400, cost: 0.125}",
+		"      \"2026-09-30\":",
+		"        gpt-5: {requests: 100, total_tokens: 10000, cost: 10}",
+		"  7:",
+		"    username: bob",
+		"    days:",
+		"      \"2026-10-16\":",
+		"        gpt-4o: {requests: 100, total_tokens: 10000, cost: 10}",
+	}, "\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := storage.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tg := &Telegram{storage: s}
+
+	daily, monthly, models, err := tg.userUsage(42, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (quotaUsage{Messages: 3, Tokens: 150, Cost: 0.75}); daily != want {
+		t.Errorf("daily usage = %+v, want %+v", daily, want)
+	}
+	if want := (quotaUsage{Messages: 10, Tokens: 850, Cost: 1.875}); monthly != want {
+		t.Errorf("monthly usage = %+v, want %+v", monthly, want)
+	}
+	if want := "gpt-4o,gpt-4o-mini,o3"; strings.Join(models, ",") != want {
+		t.Errorf("models = %v, want %s", models, want)
+	}
+}
+
+func TestQuotaCheckedBeforeDownload(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(tg *Telegram, msg *telebot.Message, link string) error
+	}{
+		{
+			name: "album",
+			run: func(tg *Telegram, msg *telebot.Message, _ string) error {
+				second := newTestMessage(msg.ID+1, "")
+				for i, m := range []*telebot.Message{msg, second} {
+					m.AlbumID = "album"
+					m.Photo = &telebot.Photo{File: telebot.File{FileID: string(rune('a' + i))}}
+				}
+				return tg.generatePhotos([]*telebot.Message{msg, second})
+			},
+		},
+		{
+			name: "document",
+			run: func(tg *Telegram, msg *telebot.Message, _ string) error {
+				msg.Document = &telebot.Document{File: telebot.File{FileID: "doc"}, FileName: "doc.pdf", MIME: "application/pdf"}
+				return tg.onDocument(tg.bot.NewContext(telebot.Update{Message: msg}))
+			},
+		},
+		{
+			name: "link",
+			run: func(tg *Telegram, msg *telebot.Message, link string) error {
+				return tg.generateLinks(msg, []string{link})
+			},
+		},
+		{
+			name: "voice",
+			run: func(tg *Telegram, msg *telebot.Message, _ string) error {
+				tg.transcriber = gpt.NewFake()
+				return tg.transcribeAndGenerate(msg, &telebot.File{FileID: "voice"}, "voice.ogg", "audio/ogg", "")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches atomic.Int32
+			page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetches.Add(1)
+				_, _ = w.Write([]byte("<p>Page</p>"))
+			}))
+			t.Cleanup(page.Close)
+
+			api := &botAPI{}
+			tg := newTestTelegram(t, api)
+			tg.linkClient = page.Client()
+			tg.config.Quotas.Default.Daily.Messages = 1
+
+			err := tg.storage.AddUsage(42, "alice", "fake", gpt.Usage{}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = tt.run(tg, newTestMessage(1, ""), page.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, call := range api.Calls() {
+				if call.Method == "getFile" {
+					t.Errorf("file %v is downloaded despite exceeded quota", call.Params["file_id"])
+				}
+			}
+			if n := fetches.Load(); n > 0 {
+				t.Errorf("link is fetched %d times despite exceeded quota", n)
+			}
+			if replies := api.Texts("sendMessage"); len(replies) != 1 {
+				t.Errorf("got replies %q, want a single quota message", replies)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package telegram
+
+import (
+	"html"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/telegram/texts"
+)
+
+// maxReasoningLength limits the reasoning summary so that it fits into a single message.
+const maxReasoningLength = 3800
+
+// replyReasoning sends the summary of the model's reasoning as an expandable blockquote.
+// The reasoning is auxiliary, so failures are logged only.
+func (tg *Telegram) replyReasoning(msg *telebot.Message, reasoning string) {
+	if runes := []rune(reasoning); len(runes) > maxReasoningLength {
+		reasoning = string(runes[:maxReasoningLength]) + "…"
+	}
+
+	text := "<b>" + html.EscapeString(texts.Reasoning) + "</b>\n" +
+		"<blockquote expandable>" + html.EscapeString(reasoning) + "</blockquote>"
+
+	_, err := tg.bot.Reply(msg, text, telebot.Silent, telebot.ModeHTML)
+	if err != nil {
+		log.Error().Err(err).
+			Str("username", msg.Sender.Username).
+			Int("msg", msg.ID).
+			Msg("failed to send reasoning")
+	}
+}
@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+	"github.com/kapitanov/gptbot/internal/telegram/texts"
+)
+
+// reasoningProvider is a fake provider that reports a reasoning summary.
+type reasoningProvider struct {
+	gpt.Provider
+	reasoning string
+}
+
+func (p reasoningProvider) GenerateStream(ctx context.Context, req gpt.Request, onUpdate gpt.StreamFunc) (gpt.Response, error) {
+	response, err := p.Provider.GenerateStream(ctx, req, onUpdate)
+	response.Reasoning = p.reasoning
+	return response, err
+}
+
+func TestReplyReasoning(t *testing.T) {
+	header := "<b>" + texts.Reasoning + "</b>\n<blockquote expandable>"
+
+	tests := []struct {
+		name      string
+		show      bool
+		reasoning string
+		want      string // Sent reasoning message, if any.
+	}{
+		{name: "hidden", reasoning: "thinking"},
+		{name: "no reasoning", show: true},
+		{name: "shown", show: true, reasoning: "thinking", want: header + "thinking</blockquote>"},
+		{name: "escaped", show: true, reasoning: "a < b & c", want: header + "a &lt; b &amp; c</blockquote>"},
+		{
+			name:      "truncated",
+			show:      true,
+			reasoning: strings.Repeat("й", maxReasoningLength+10),
+			want:      header + strings.Repeat("й", maxReasoningLength) + "…</blockquote>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &botAPI{}
+			tg := newTestTelegram(t, api)
+			tg.gpt = reasoningProvider{Provider: tg.gpt, reasoning: tt.reasoning}
+			tg.config.Reply.ShowReasoning = tt.show
+
+			err := tg.process(newTestMessage(1, "hello"), input{Text: "hello"})
+			if err != nil {
+				t.Fatalf("process() error = %v", err)
+			}
+
+			// The placeholder is followed by the reasoning, if it's shown.
+			var got []string
+			for _, call := range api.Calls() {
+				if call.Method != "sendMessage" || call.Params["text"] == texts.Thinking {
+					continue
+				}
+				got = append(got, call.Params["text"].(string))
+				if call.Params["parse_mode"] != "HTML" {
+					t.Errorf("reasoning is sent with parse mode %v, want HTML", call.Params["parse_mode"])
+				}
+			}
+
+			if tt.want == "" && len(got) != 0 {
+				t.Errorf("sent %q, want no reasoning", got)
+			}
+			if tt.want != "" && (len(got) != 1 || got[0] != tt.want) {
+				t.Errorf("sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+	"github.com/kapitanov/gptbot/internal/telegram/mdparser"
+	"github.com/kapitanov/gptbot/internal/telegram/texts"
+)
+
+func (tg *Telegram) onVoice(ctx telebot.Context) error {
+	msg := ctx.Message()
+
+	return tg.transcribeAndGenerate(msg, &msg.Voice.File, "voice.ogg", msg.Voice.MIME, msg.Voice.Caption)
+}
+
+func (tg *Telegram) onAudio(ctx telebot.Context) error {
+	msg := ctx.Message()
+
+	filename := msg.Audio.FileName
+	if filename == "" {
+		filename = "audio.mp3"
+	}
+
+	return tg.transcribeAndGenerate(msg, &msg.Audio.File, filename, msg.Audio.MIME, msg.Audio.Caption)
+}
+
+func (tg *Telegram) onVideoNote(ctx telebot.Context) error {
+	msg := ctx.Message()
+
+	return tg.transcribeAndGenerate(msg, &msg.VideoNote.File, "video_note.mp4", "video/mp4", "")
+}
+
+// transcribeAndGenerate transcribes a recording and transforms its transcript.
+// Caption is used instead if transcription isn't available.
+func (tg *Telegram) transcribeAndGenerate(msg *telebot.Message, file *telebot.File, filename, mimeType, caption string) error {
+	if caption == "" {
+		caption = msg.Caption
+	}
+
+	if tg.transcriber == nil {
+		return tg.generate(msg, caption, "")
+	}
+
+	if !tg.hasAccess(msg) || !tg.checkQuota(msg) {
+		return nil
+	}
+
+	transcript, err := tg.transcribe(msg, file, filename, mimeType)
+	if err != nil {
+		log.Error().Err(err).
+			Str("username", msg.Sender.Username).
+			Int("msg", msg.ID).
+			Msg("failed to transcribe")
+
+		_, err := tg.bot.Reply(msg, failureText(err))
+		if err != nil {
+			log.Error().Err(err).
+				Str("username", msg.Sender.Username).
+				Int("msg", msg.ID).
+				Msg("failed to send error message")
+		}
+		return err
+	}
+
+	if tg.showTranscript && transcript != "" {
+		tg.sendTranscript(msg, transcript)
+	}
+
+	text := transcript
+	if caption != "" {
+		text = caption + "\n\n" + transcript
+	}
+
+	return tg.transform(msg, input{Text: text})
+}
+
+func (tg *Telegram) transcribe(msg *telebot.Message, file *telebot.File, filename, mimeType string) (string, error) {
+	if file.FileSize > maxDownloadSize {
+		return "", fmt.Errorf("recording is too large: %d bytes", file.FileSize)
+	}
+
+	err := tg.bot.Notify(msg.Sender, telebot.Typing)
+	if err != nil {
+		log.Error().Err(err).
+			Str("username", msg.Sender.Username).
+			Int("msg", msg.ID).
+			Msg("failed to send typing notification")
+	}
+
+	data, err := tg.downloadFile(file, maxDownloadSize)
+	if err != nil {
+		return "", err
+	}
+
+	transcript, err := tg.transcriber.Transcribe(context.Background(), gpt.Audio{
+		Filename: filename,
+		MIMEType: mimeType,
+		Data:     data,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Transcription is billed on its own, so it counts towards the quota like a reply does.
+	err = tg.storage.AddUsage(msg.Sender.ID, msg.Sender.Username, transcript.Model, transcript.Usage, transcript.Cost)
+	if err != nil {
+		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to store usage")
+	}
+
+	log.Info().
+		Str("username", msg.Sender.Username).
+		Int("msg", msg.ID).
+		Int("bytes", len(data)).
+		Float64("cost", transcript.Cost).
+		Str("transcript", transcript.Text).
+		Msg("transcribed a recording")
+
+	return transcript.Text, nil
+}
+
+func (tg *Telegram) sendTranscript(msg *telebot.Message, transcript string) {
+	const maxTextLength = 4096 - 1
+
+	for _, chunk := range mdparser.Split(texts.Transcript+"\n\n"+transcript, maxTextLength) {
+		_, err := tg.bot.Reply(msg, chunk.Text, telebot.Silent)
+		if err != nil {
+			log.Error().Err(err).
+				Str("username", msg.Sender.Username).
+				Int("msg", msg.ID).
+				Msg("failed to send transcript")
+			return
+		}
+	}
+}
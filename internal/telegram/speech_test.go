@@ -0,0 +1,65 @@
+package telegram
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+)
+
+func TestTranscribeAndGenerateUsage(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcribe bool // Transcriber is configured.
+		wantModels []string
+	}{
+		{name: "transcribed", transcribe: true, wantModels: []string{"fake", "fake-transcribe"}},
+		{name: "caption only", wantModels: []string{"fake"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &botAPI{handle: func(call botCall) (int, any) {
+				if call.Method == "getFile" {
+					return http.StatusOK, map[string]any{"ok": true, "result": map[string]any{"file_id": "voice", "file_path": "voice.ogg"}}
+				}
+				return 0, nil
+			}}
+			tg := newTestTelegram(t, api)
+			if tt.transcribe {
+				tg.transcriber = gpt.NewFake()
+			}
+
+			err := tg.transcribeAndGenerate(newTestMessage(1, ""), &telebot.File{FileID: "voice"}, "voice.ogg", "audio/ogg", "Caption")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			usage, err := tg.storage.UserUsage(42, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var models []string
+			for _, record := range usage {
+				models = append(models, record.Model)
+				if record.Requests != 1 || record.Usage.TotalTokens == 0 {
+					t.Errorf("usage of %s = %+v, want a request with tokens", record.Model, record)
+				}
+			}
+			// Records come in no particular order.
+			slices.Sort(models)
+			if len(models) != len(tt.wantModels) {
+				t.Fatalf("usage is recorded for %v, want %v", models, tt.wantModels)
+			}
+			for i := range models {
+				if models[i] != tt.wantModels[i] {
+					t.Errorf("usage is recorded for %v, want %v", models, tt.wantModels)
+				}
+			}
+		})
+	}
+}
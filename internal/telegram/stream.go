@@ -0,0 +1,91 @@
+package telegram
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/telebot.v4"
+)
+
+// streamingReply progressively updates a placeholder message with partially generated text.
+// Edits are throttled to stay within Bot API limits.
+type streamingReply struct {
+	bot         *telebot.Bot
+	placeholder *telebot.Message
+	mutex       sync.Mutex
+	text        string
+	sentText    string
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+func (tg *Telegram) startStreamingReply(placeholder *telebot.Message) *streamingReply {
+	sr := &streamingReply{
+		bot:         tg.bot,
+		placeholder: placeholder,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	go sr.run()
+	return sr
+}
+
+// Update sets text generated so far.
+func (sr *streamingReply) Update(text string) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	sr.text = text
+}
+
+// Stop stops updating the placeholder message and waits for the pending edit to complete.
+func (sr *streamingReply) Stop() {
+	close(sr.stop)
+	<-sr.done
+}
+
+func (sr *streamingReply) run() {
+	const editInterval = 1500 * time.Millisecond
+
+	defer close(sr.done)
+
+	ticker := time.NewTicker(editInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sr.stop:
+			return
+		case <-ticker.C:
+			sr.flush()
+		}
+	}
+}
+
+func (sr *streamingReply) flush() {
+	const maxTextLength = 4096 - 1
+
+	sr.mutex.Lock()
+	text := sr.text
+	sr.mutex.Unlock()
+
+	if text == "" || text == sr.sentText {
+		return
+	}
+
+	// Partial markdown can't be rendered reliably, so it's shown as plain text until generation is completed.
+	preview := text
+	if runes := []rune(preview); len(runes) > maxTextLength {
+		preview = string(runes[:maxTextLength-1]) + "…"
+	}
+
+	_, err := sr.bot.Edit(sr.placeholder, preview)
+	if err != nil {
+		log.Error().Err(err).Int("msg", sr.placeholder.ID).Msg("failed to update reply")
+		return
+	}
+
+	sr.sentText = text
+}
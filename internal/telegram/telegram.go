@@ -2,6 +2,10 @@ package telegram
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -14,18 +18,36 @@ import (
 
 // Telegram is a telegram bot.
 type Telegram struct {
-	bot           *telebot.Bot
-	storage       *storage.Storage
-	gpt           *gpt.GPT
-	accessChecker AccessChecker
+	bot            *telebot.Bot
+	config         *telegramConfig
+	storage        *storage.Storage
+	gpt            gpt.Provider
+	transcriber    gpt.Transcriber
+	showTranscript bool
+	accessChecker  AccessChecker
+	adminChecker   AccessChecker
+	albums         *albumCollector
+	linkClient     *http.Client
 }
 
+// Delays between attempts to connect to Telegram.
+const (
+	connectRetryDelay    = time.Second
+	maxConnectRetryDelay = 5 * time.Minute
+)
+
 // Options is a telegram bot options.
 type Options struct {
 	Token         string           // Telegram bot token.
-	GPT           *gpt.GPT         // GPT text transformer.
+	APIURL        string           // Bot API endpoint, empty means "https://api.telegram.org".
+	HTTPClient    *http.Client     // HTTP client to call Bot API with, optional.
+	GPT           gpt.Provider     // GPT text transformer.
 	AccessChecker AccessChecker    // Access checker.
+	AdminChecker  AccessChecker    // Access checker for admin commands, optional.
 	Storage       *storage.Storage // Storage.
+
+	Transcriber    gpt.Transcriber // Speech-to-text converter, optional.
+	ShowTranscript bool            // Reply with transcripts of recordings.
 }
 
 // AccessChecker checks access to telegram chats.
@@ -36,39 +58,122 @@ type AccessChecker interface {
 
 // New creates a new telegram bot.
 func New(options Options) (*Telegram, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	const pollTimeout = 10 * time.Second
+	if options.HTTPClient != nil && options.HTTPClient.Timeout != 0 && options.HTTPClient.Timeout <= pollTimeout {
+		return nil, fmt.Errorf("telegram request timeout must exceed long polling timeout (%s), got %s", pollTimeout, options.HTTPClient.Timeout)
+	}
+
+	// Bot API is called by Run, so that the bot starts even if Telegram is unreachable.
 	bot, err := telebot.NewBot(telebot.Settings{
-		Token:  options.Token,
-		Poller: &telebot.LongPoller{Timeout: 10 * time.Second},
+		URL:     options.APIURL,
+		Token:   options.Token,
+		Poller:  &telebot.LongPoller{Timeout: pollTimeout},
+		Client:  options.HTTPClient,
+		Offline: true,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	log.Info().Int64("id", bot.Me.ID).Str("username", bot.Me.Username).Msg("connected to telegram")
-
 	tg := &Telegram{
-		bot:           bot,
-		accessChecker: options.AccessChecker,
-		gpt:           options.GPT,
-		storage:       options.Storage,
+		bot:            bot,
+		config:         config,
+		accessChecker:  options.AccessChecker,
+		adminChecker:   options.AdminChecker,
+		gpt:            options.GPT,
+		transcriber:    options.Transcriber,
+		showTranscript: options.ShowTranscript,
+		storage:        options.Storage,
+		albums:         newAlbumCollector(),
+		linkClient:     newLinkClient(),
 	}
 
 	tg.setupHandlers()
 
-	tg.bot.SetCommands([]telebot.Command{
-		{Text: "start", Description: "Start the bot"},
-		{Text: "reset", Description: "Reset the conversation"},
-	})
-
 	return tg, nil
 }
 
 // Run runs telegram bot in foreground until context is canceled.
-func (tg *Telegram) Run(ctx context.Context) {
+// Until Telegram is reachable, the bot keeps trying to connect.
+// An error is returned if Telegram rejects the bot token.
+func (tg *Telegram) Run(ctx context.Context) error {
+	connected, err := tg.connect(ctx)
+	if !connected {
+		return err
+	}
+
 	go tg.bot.Start()
 	defer tg.bot.Stop()
 
 	<-ctx.Done()
+	return nil
+}
+
+// connect fetches the bot identity and registers bot commands, retrying until it succeeds.
+// It returns false if context is canceled first or the bot token is rejected.
+func (tg *Telegram) connect(ctx context.Context) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		me, err := tg.getMe()
+		if err == nil {
+			// Handlers aren't running yet, so the identity is replaced safely.
+			tg.bot.Me = me
+			break
+		}
+
+		if errors.Is(err, telebot.ErrUnauthorized) || errors.Is(err, telebot.ErrNotFound) {
+			return false, fmt.Errorf("telegram rejected the bot token: %w", err)
+		}
+
+		delay := min(connectRetryDelay<<min(attempt-1, 10), maxConnectRetryDelay)
+		log.Error().Err(err).Int("attempt", attempt).Dur("delay", delay).Msg("telegram is unreachable, retrying")
+
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-time.After(delay):
+		}
+	}
+
+	log.Info().Int64("id", tg.bot.Me.ID).Str("username", tg.bot.Me.Username).Msg("connected to telegram")
+
+	err := tg.bot.SetCommands([]telebot.Command{
+		{Text: "start", Description: "Start the bot"},
+		{Text: "reset", Description: "Reset the conversation"},
+		{Text: "persona", Description: "Choose who replies"},
+		{Text: "usage", Description: "Show your usage"},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to set bot commands")
+	}
+
+	return true, nil
+}
+
+// getMe returns the bot identity.
+func (tg *Telegram) getMe() (*telebot.User, error) {
+	data, err := tg.bot.Raw("getMe", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Result *telebot.User `json:"result"`
+	}
+	err = json.Unmarshal(data, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Result == nil {
+		return nil, errors.New("getMe returned no user")
+	}
+
+	return resp.Result, nil
 }
 
 // Close shuts telegram bot down.
@@ -88,11 +193,24 @@ func (tg *Telegram) hasAccess(msg *telebot.Message) bool {
 		return true
 	}
 
+	tg.denyAccess(msg)
+	return false
+}
+
+func (tg *Telegram) isAdmin(msg *telebot.Message) bool {
+	if tg.adminChecker != nil && tg.adminChecker.CheckAccess(msg.Sender.ID, msg.Sender.Username) {
+		return true
+	}
+
+	tg.denyAccess(msg)
+	return false
+}
+
+func (tg *Telegram) denyAccess(msg *telebot.Message) {
 	log.Error().Str("username", msg.Sender.Username).Msg("access denied")
 
 	_, err := tg.bot.Reply(msg, texts.AccessDenied)
 	if err != nil {
 		log.Error().Err(err).Str("username", msg.Sender.Username).Int("msg", msg.ID).Msg("failed to send access denied message")
 	}
-	return false
 }
@@ -0,0 +1,131 @@
+package telegram
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/telebot.v4"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+	"github.com/kapitanov/gptbot/internal/storage"
+)
+
+// botCall is a Bot API call received by botAPI.
+type botCall struct {
+	Method string
+	Params map[string]any
+}
+
+// botAPI is a fake Bot API server.
+// Calls are answered by handle, or with a generic successful result if handle returns nil.
+type botAPI struct {
+	mutex  sync.Mutex
+	calls  []botCall
+	handle func(call botCall) (status int, body any)
+}
+
+func (a *botAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	call := botCall{Method: r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]}
+	_ = json.NewDecoder(r.Body).Decode(&call.Params)
+
+	a.mutex.Lock()
+	a.calls = append(a.calls, call)
+	handle := a.handle
+	a.mutex.Unlock()
+
+	status, body := http.StatusOK, any(nil)
+	if handle != nil {
+		status, body = handle(call)
+	}
+
+	if body == nil {
+		status = http.StatusOK
+
+		var result any = true
+		switch call.Method {
+		case "sendMessage", "editMessageText":
+			result = map[string]any{
+				"message_id": 100 + len(a.Calls()),
+				"chat":       map[string]any{"id": 1, "type": "private"},
+				"text":       call.Params["text"],
+			}
+		}
+		body = map[string]any{"ok": true, "result": result}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// Calls returns calls received so far.
+func (a *botAPI) Calls() []botCall {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return append([]botCall(nil), a.calls...)
+}
+
+// Texts returns texts sent or edited by the given method.
+func (a *botAPI) Texts(method string) []string {
+	var texts []string
+	for _, call := range a.Calls() {
+		if call.Method == method {
+			text, _ := call.Params["text"].(string)
+			texts = append(texts, text)
+		}
+	}
+
+	return texts
+}
+
+// botError returns a Bot API error response.
+func botError(description string) (int, any) {
+	return http.StatusBadRequest, map[string]any{"ok": false, "error_code": 400, "description": description}
+}
+
+type allowAll struct{}
+
+func (allowAll) CheckAccess(int64, string) bool { return true }
+
+// newTestTelegram creates a bot that talks to a fake Bot API and uses the fake GPT provider.
+func newTestTelegram(t *testing.T, api *botAPI) *Telegram {
+	t.Helper()
+
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	bot, err := telebot.NewBot(telebot.Settings{URL: server.URL, Token: "token", Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := storage.New(filepath.Join(t.TempDir(), "data.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Telegram{
+		bot:           bot,
+		config:        &telegramConfig{},
+		storage:       s,
+		gpt:           gpt.NewFake(),
+		accessChecker: allowAll{},
+		albums:        newAlbumCollector(),
+	}
+}
+
+// newTestMessage returns a private message from a user.
+func newTestMessage(id int, text string) *telebot.Message {
+	return &telebot.Message{
+		ID:     id,
+		Text:   text,
+		Sender: &telebot.User{ID: 42, Username: "alice", FirstName: "Alice"},
+		Chat:   &telebot.Chat{ID: 42, Type: telebot.ChatPrivate},
+	}
+}
@@ -10,8 +10,55 @@ const Reset = "Все, забыли, давай по новой"
 
 const MissingText = "Такое сообщение мне не по силам"
 
+const Transcript = "Расшифровка:"
+
+const Reasoning = "Ход мыслей:"
+
+const Source = "Источник:"
+
 const Thinking = "Ща прочитаю и отпишусь"
 
 const Failure = "Простите, что-то пошло не так. Я не смог :("
 
+const RateLimited = "Меня сейчас слишком дергают, не успеваю. Попробуй еще раз через минутку"
+
+const QuotaExceeded = "У меня закончились деньги на нейросеть. Передай админу, пусть пополнит"
+
+const ContextTooLong = "Слишком длинно, я столько не осилю. Пришли покороче или сделай /reset"
+
+const ServerError = "Нейросеть сейчас лежит. Попробуй попозже"
+
+const InvalidRequest = "Нейросеть отказалась это обрабатывать. Попробуй переформулировать"
+
+const DailyLimitReached = "Ты исчерпал дневной лимит %s. Он обнулится %s"
+
+const MonthlyLimitReached = "Ты исчерпал месячный лимит %s. Он обнулится %s"
+
+const LimitMessages = "сообщений (%d)"
+
+const LimitTokens = "токенов (%d)"
+
+const LimitCost = "расходов ($%.2f)"
+
+const Usage = `Твой расход.
+Сегодня: %s.
+В этом месяце: %s.
+Модели: %s.`
+
+const UsageLine = "сообщений %s, токенов %s, потрачено %s"
+
+const UsageNoModels = "пока никаких"
+
+const DefaultPersona = "Петрович"
+
+const ChoosePersona = "Кем мне быть?"
+
+const PersonaChanged = "Теперь я %s. Начинаем разговор заново"
+
+const NoPersonas = "Я могу быть только собой"
+
+const Keys = "Ключи API:"
+
+const NoKeys = "Этот провайдер не использует ключи API"
+
 const AccessDenied = "Этот бот доступен только для определенных пользователей."
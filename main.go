@@ -19,6 +19,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/kapitanov/gptbot/internal/gpt"
+	"github.com/kapitanov/gptbot/internal/httpclient"
 	"github.com/kapitanov/gptbot/internal/storage"
 	"github.com/kapitanov/gptbot/internal/telegram"
 )
@@ -50,6 +51,7 @@ func main() {
 
 	rootCmd.AddCommand(runCommand())
 	rootCmd.AddCommand(chatCommand())
+	rootCmd.AddCommand(usageCommand())
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Error().Msg(err.Error())
@@ -67,18 +69,30 @@ func runCommand() *cobra.Command {
 				return err
 			}
 
-			g, err := gpt.New(os.Getenv("OPENAI_TOKEN"))
+			g, err := newProvider()
 			if err != nil {
 				return err
 			}
 
 			accessProvider := NewAccessProvider(os.Getenv("TELEGRAM_BOT_ACCESS"))
 
+			transcriber, _ := g.(gpt.Transcriber)
+
+			telegramClient, err := httpclient.FromEnv("TELEGRAM", time.Minute)
+			if err != nil {
+				return err
+			}
+
 			tg, err := telegram.New(telegram.Options{
-				Token:         os.Getenv("TELEGRAM_BOT_TOKEN"),
-				AccessChecker: accessProvider,
-				GPT:           g,
-				Storage:       s,
+				Token:          os.Getenv("TELEGRAM_BOT_TOKEN"),
+				APIURL:         os.Getenv("TELEGRAM_API_URL"),
+				HTTPClient:     telegramClient,
+				AccessChecker:  accessProvider,
+				AdminChecker:   NewAccessProvider(os.Getenv("TELEGRAM_BOT_ADMINS")),
+				GPT:            g,
+				Storage:        s,
+				Transcriber:    transcriber,
+				ShowTranscript: os.Getenv("TELEGRAM_SHOW_TRANSCRIPT") == "true",
 			})
 			if err != nil {
 				return err
@@ -94,14 +108,41 @@ func runCommand() *cobra.Command {
 				cancel()
 			}()
 
+			if w, ok := g.(interface{ Watch(context.Context) }); ok {
+				go w.Watch(ctx)
+			}
+
+			if os.Getenv("STARTUP_CHECKS") != "false" {
+				go checkProvider(ctx, g)
+			}
+
 			log.Info().Msg("press <ctrl+c> to exit")
-			tg.Run(ctx)
+			err = tg.Run(ctx)
+			if err != nil {
+				return err
+			}
+
 			log.Info().Msg("good bye")
 			return nil
 		},
 	}
 }
 
+// newProvider creates an LLM provider selected by GPT_PROVIDER env variable.
+func newProvider() (gpt.Provider, error) {
+	switch kind := os.Getenv("GPT_PROVIDER"); kind {
+	case "", "openai":
+		return gpt.New(os.Getenv("OPENAI_TOKEN"))
+	case "chat":
+		return gpt.NewChatCompletions(os.Getenv("OPENAI_TOKEN"))
+	case "fake":
+		log.Warn().Msg("using fake gpt provider")
+		return gpt.NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown gpt provider %q", kind)
+	}
+}
+
 // AccessProvider checks access to telegram chats.
 type AccessProvider struct {
 	ids       map[int64]struct{}
@@ -153,7 +194,7 @@ func chatCommand() *cobra.Command {
 		Use:   "chat",
 		Short: "Run the GPT bot in terminal chat mode",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			g, err := gpt.New(os.Getenv("OPENAI_TOKEN"))
+			g, err := newProvider()
 			if err != nil {
 				return err
 			}
@@ -167,9 +208,17 @@ func chatCommand() *cobra.Command {
 				cancel()
 			}()
 
+			if w, ok := g.(interface{ Watch(context.Context) }); ok {
+				go w.Watch(ctx)
+			}
+
 			_, _ = fmt.Fprintf(os.Stderr, "(type \"/q\" to quit)\n")
 
-			var lastResponseID string
+			var (
+				lastResponseID string
+				history        []gpt.Message
+				summary        string
+			)
 			for {
 				line, err := readLine()
 				if err != nil {
@@ -192,7 +241,12 @@ func chatCommand() *cobra.Command {
 				}
 
 				_, _ = fmt.Fprintf(os.Stderr, "... ")
-				response, err := g.Generate(ctx, gpt.Request{Message: line, PrevResponseID: lastResponseID})
+				response, err := g.Generate(ctx, gpt.Request{
+					Message:        line,
+					PrevResponseID: lastResponseID,
+					History:        history,
+					Summary:        summary,
+				})
 				if err != nil {
 					if errors.Is(err, context.Canceled) {
 						return nil
@@ -202,19 +256,23 @@ func chatCommand() *cobra.Command {
 				}
 
 				_, _ = fmt.Fprintf(os.Stderr, "\r< %s\n\n", response.Text)
-				_, _ = fmt.Fprintf(os.Stderr, "# %d tokens\n\n", response.Usage.TotalTokens)
+				_, _ = fmt.Fprintf(os.Stderr, "# %s, %d tokens, $%.4f\n\n", response.Model, response.Usage.TotalTokens, response.Cost)
 
 				lastResponseID = response.ID
+				history = response.History
+				summary = response.Summary
 			}
 			return nil
 		},
 	}
 }
 
+// stdinReader is shared between readLine calls so that buffered input isn't lost.
+var stdinReader = bufio.NewReader(os.Stdin)
+
 func readLine() (string, error) {
 	_, _ = fmt.Fprintf(os.Stderr, "> ")
-	reader := bufio.NewReader(os.Stdin)
-	line, err := reader.ReadString('\n')
+	line, err := stdinReader.ReadString('\n')
 	if err != nil {
 		return "", err
 	}
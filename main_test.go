@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		config   string
+		want     string // Provider type.
+		wantErr  bool
+	}{
+		{name: "default", config: "model:\n  name: gpt-4o\n", want: "*gpt.GPT"},
+		{name: "openai", provider: "openai", config: "model:\n  name: gpt-4o\n", want: "*gpt.GPT"},
+		{name: "chat", provider: "chat", config: "model:\n  name: llama3\n", want: "*gpt.ChatCompletions"},
+		{name: "fake", provider: "fake", want: "*gpt.Fake"},
+		{name: "unknown", provider: "anthropic", config: "model:\n  name: gpt-4o\n", wantErr: true},
+		{name: "chat with chain conversation", provider: "chat", config: "model:\n  name: llama3\nconversation:\n  mode: chain\n", wantErr: true},
+		{name: "chat with responses parameters", provider: "chat", config: "model:\n  name: llama3\n  seed: 1\n", want: "*gpt.ChatCompletions"},
+		{name: "openai with chat completions parameters", provider: "openai", config: "model:\n  name: gpt-4o\n  seed: 1\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range map[string]string{"gpt.yaml": tt.config, "PROMPT.md": "Be brief."} {
+				err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			t.Setenv("CONFIG_PATH", filepath.Join(dir, "gpt.yaml"))
+			t.Setenv("GPT_PROVIDER", tt.provider)
+			t.Setenv("OPENAI_TOKEN", "sk-aaaa1111")
+			// Providers are created without calling the API, so an unreachable endpoint is fine.
+			t.Setenv("OPENAI_BASE_URL", "http://127.0.0.1:1/v1/")
+			for _, name := range []string{"AZURE_OPENAI_ENDPOINT", "OPENAI_HEADERS", "OPENAI_PROXY"} {
+				t.Setenv(name, "")
+			}
+
+			g, err := newProvider()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newProvider() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := fmt.Sprintf("%T", g); got != tt.want {
+				t.Errorf("newProvider() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+)
+
+// Delays between provider checks, variables for tests.
+var (
+	checkRetryDelay    = 5 * time.Second
+	maxCheckRetryDelay = 5 * time.Minute
+)
+
+// checkProvider checks that the provider is reachable and configured models exist, and logs the result.
+// Network and server failures are retried until the check passes or context is canceled.
+// The bot keeps running either way: failed requests are reported to users.
+func checkProvider(ctx context.Context, g gpt.Provider) {
+	checker, ok := g.(gpt.HealthChecker)
+	if !ok {
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := checker.Check(ctx)
+		if err == nil {
+			log.Info().Msg("llm provider is reachable, configured models are available")
+			return
+		}
+
+		kind := gpt.ErrorKindOf(err)
+		if !kind.Retryable() {
+			if ctx.Err() == nil {
+				log.Error().Err(err).Str("kind", kind.String()).Msg("llm provider check failed, replies may fail until config is fixed")
+			}
+			return
+		}
+
+		delay := min(checkRetryDelay<<min(attempt-1, 10), maxCheckRetryDelay)
+		log.Error().Err(err).Int("attempt", attempt).Dur("delay", delay).Msg("llm provider is unreachable, retrying")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
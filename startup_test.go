@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kapitanov/gptbot/internal/gpt"
+)
+
+// testChecker is a provider whose checks fail with errs in order and then pass.
+type testChecker struct {
+	gpt.Provider
+	errs  []error
+	calls int
+}
+
+func (c *testChecker) Check(ctx context.Context) error {
+	c.calls++
+	if c.calls > len(c.errs) {
+		return nil
+	}
+	return c.errs[c.calls-1]
+}
+
+func TestCheckProvider(t *testing.T) {
+	delay, maxDelay := checkRetryDelay, maxCheckRetryDelay
+	checkRetryDelay, maxCheckRetryDelay = time.Millisecond, 2*time.Millisecond
+	t.Cleanup(func() { checkRetryDelay, maxCheckRetryDelay = delay, maxDelay })
+
+	var (
+		unreachable = &gpt.Error{Kind: gpt.ErrorServer}
+		rateLimit   = &gpt.Error{Kind: gpt.ErrorRateLimit}
+	)
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+	}{
+		{name: "reachable", wantCalls: 1},
+		{name: "invalid key", errs: []error{&gpt.Error{Kind: gpt.ErrorAuth}}, wantCalls: 1},
+		{name: "missing model", errs: []error{&gpt.Error{Kind: gpt.ErrorModelNotFound}}, wantCalls: 1},
+		{name: "unreachable at first", errs: []error{unreachable, rateLimit, unreachable, unreachable}, wantCalls: 5},
+		{name: "invalid key once reachable", errs: []error{unreachable, &gpt.Error{Kind: gpt.ErrorAuth}}, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &testChecker{Provider: gpt.NewFake(), errs: tt.errs}
+			ctx := context.Background()
+
+			done := make(chan struct{})
+			go func() {
+				checkProvider(ctx, checker)
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("checkProvider() doesn't return")
+			}
+
+			if checker.calls != tt.wantCalls {
+				t.Errorf("got %d checks, want %d", checker.calls, tt.wantCalls)
+			}
+		})
+	}
+
+	// Checks stop on shutdown.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	checker := &testChecker{Provider: gpt.NewFake(), errs: []error{unreachable, unreachable}}
+	checkProvider(ctx, checker)
+	if checker.calls != 1 {
+		t.Errorf("got %d checks after shutdown, want 1", checker.calls)
+	}
+
+	// Providers without checks, like the fake one, are skipped.
+	checkProvider(context.Background(), gpt.NewFake())
+}
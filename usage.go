@@ -0,0 +1,190 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kapitanov/gptbot/internal/storage"
+)
+
+// Columns a usage report can be grouped by.
+const (
+	usageByUser  = "user"
+	usageByModel = "model"
+	usageByDay   = "day"
+)
+
+func usageCommand() *cobra.Command {
+	var (
+		days int
+		by   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Print token usage and cost report",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			columns, err := parseUsageColumns(by)
+			if err != nil {
+				return err
+			}
+
+			s, err := storage.New(os.Getenv("STORAGE_PATH"))
+			if err != nil {
+				return err
+			}
+
+			records, err := s.Usage()
+			if err != nil {
+				return err
+			}
+
+			if days > 0 {
+				since := time.Now().UTC().AddDate(0, 0, 1-days).Format(time.DateOnly)
+				records = slices.DeleteFunc(records, func(record storage.UsageRecord) bool {
+					return record.Day < since
+				})
+			}
+
+			return printUsageReport(os.Stdout, columns, groupUsage(records, columns))
+		},
+	}
+
+	cmd.Flags().IntVar(&days, "days", 0, "report the last N days only, 0 means all time")
+	cmd.Flags().StringVar(&by, "by", "user,model,day", "comma separated columns to group by: user, model, day")
+	return cmd
+}
+
+// parseUsageColumns parses a list of columns a usage report is grouped by.
+func parseUsageColumns(s string) ([]string, error) {
+	var columns []string
+	for _, column := range strings.Split(s, ",") {
+		column = strings.TrimSpace(column)
+		switch column {
+		case "":
+			continue
+		case usageByUser, usageByModel, usageByDay:
+		default:
+			return nil, fmt.Errorf("unknown usage column %q, must be one of %q, %q or %q", column, usageByUser, usageByModel, usageByDay)
+		}
+
+		if slices.Contains(columns, column) {
+			return nil, fmt.Errorf("duplicate usage column %q", column)
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+// usageRow is a row of a usage report.
+type usageRow struct {
+	keys   map[string]string // Values of grouping columns.
+	record storage.UsageRecord
+}
+
+// groupUsage sums up usage records by columns, rows are sorted by columns.
+func groupUsage(records []storage.UsageRecord, columns []string) []usageRow {
+	rows := make(map[string]*usageRow)
+	for _, record := range records {
+		keys := make(map[string]string, len(columns))
+		var id strings.Builder
+		for _, column := range columns {
+			keys[column] = usageKey(record, column)
+			id.WriteString(keys[column] + "\x00")
+		}
+
+		row, exists := rows[id.String()]
+		if !exists {
+			row = &usageRow{keys: keys}
+			rows[id.String()] = row
+		}
+
+		row.record.Requests += record.Requests
+		row.record.Usage = row.record.Usage.Add(record.Usage)
+		row.record.Cost += record.Cost
+	}
+
+	result := make([]usageRow, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, *row)
+	}
+
+	slices.SortFunc(result, func(a, b usageRow) int {
+		for _, column := range columns {
+			if c := cmp.Compare(a.keys[column], b.keys[column]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	return result
+}
+
+func usageKey(record storage.UsageRecord, column string) string {
+	switch column {
+	case usageByUser:
+		if record.Username == "" {
+			return strconv.FormatInt(record.UserID, 10)
+		}
+		return fmt.Sprintf("@%s (%d)", record.Username, record.UserID)
+	case usageByModel:
+		return record.Model
+	default:
+		return record.Day
+	}
+}
+
+func printUsageReport(w io.Writer, columns []string, rows []usageRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	var header []string
+	for _, column := range columns {
+		header = append(header, strings.ToUpper(column))
+	}
+	header = append(header, "REQUESTS", "INPUT", "CACHED", "OUTPUT", "REASONING", "TOTAL", "COST")
+	_, _ = fmt.Fprintln(tw, strings.Join(header, "\t")+"\t")
+
+	var total storage.UsageRecord
+	for _, row := range rows {
+		var cells []string
+		for _, column := range columns {
+			cells = append(cells, row.keys[column])
+		}
+		_, _ = fmt.Fprintln(tw, strings.Join(append(cells, usageCells(row.record)...), "\t")+"\t")
+
+		total.Requests += row.record.Requests
+		total.Usage = total.Usage.Add(row.record.Usage)
+		total.Cost += row.record.Cost
+	}
+
+	cells := make([]string, len(columns))
+	if len(cells) > 0 {
+		cells[0] = "TOTAL"
+	}
+	_, _ = fmt.Fprintln(tw, strings.Join(append(cells, usageCells(total)...), "\t")+"\t")
+
+	return tw.Flush()
+}
+
+func usageCells(record storage.UsageRecord) []string {
+	return []string{
+		strconv.FormatInt(record.Requests, 10),
+		strconv.FormatInt(record.Usage.InputTokens, 10),
+		strconv.FormatInt(record.Usage.CachedInputTokens, 10),
+		strconv.FormatInt(record.Usage.OutputTokens, 10),
+		strconv.FormatInt(record.Usage.ReasoningTokens, 10),
+		strconv.FormatInt(record.Usage.TotalTokens, 10),
+		fmt.Sprintf("$%.4f", record.Cost),
+	}
+}